@@ -0,0 +1,301 @@
+// Package advisory provides helpers for advisory locks within [generic.Transacter.Transact]
+// blocks, eg for singleton jobs or to serialize work per entity.
+//
+// Transaction scoped locks are acquired with an [XactLocker] and released together with the
+// transaction of the session, they use pg_advisory_xact_lock and are only supported on Postgres.
+// MySQL has no transaction scoped advisory locks and releasing a GET_LOCK lock before the commit
+// would not serialize the transactions.
+// Session scoped locks are acquired with a [Locker] and held by its connection until they are
+// released with [Locker.Unlock]. They use pg_advisory_lock on Postgres and GET_LOCK on MySQL.
+package advisory
+
+import (
+	"context"
+	"database/sql"
+	"hash/fnv"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/beeemT/go-atomic"
+	"github.com/beeemT/go-atomic/generic"
+	"github.com/beeemT/go-atomic/generic/adapter"
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+)
+
+const defaultPollInterval = 50 * time.Millisecond
+
+var (
+	// ErrUnsupportedDialect is returned if the dialect of the connection has no advisory locks or
+	// no transaction scoped advisory locks.
+	ErrUnsupportedDialect = errors.New("dialect does not support advisory locks")
+	// ErrLockTimeout is returned if a lock could not be acquired within the timeout.
+	ErrLockTimeout = errors.New("advisory lock timeout")
+	// ErrNotHeld is returned by [Locker.Unlock] if the lock was not held by the connection.
+	ErrNotHeld = errors.New("advisory lock not held")
+)
+
+type (
+	// Key identifies an advisory lock.
+	Key int64
+
+	// Locker acquires session scoped advisory locks on a connection.
+	Locker struct {
+		locker
+	}
+
+	// XactLocker acquires transaction scoped advisory locks on the transaction of a session.
+	XactLocker struct {
+		locker
+	}
+
+	// Option configures the [Locker] and [XactLocker] instances.
+	Option func(*locker)
+
+	// locker holds the connection and configuration shared by [Locker] and [XactLocker].
+	locker struct {
+		conn         adapter.Conn
+		pollInterval time.Duration
+		clock        atomic.Clock
+	}
+)
+
+// StringKey hashes name to a Key using 64 bit FNV-1a.
+func StringKey(name string) Key {
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(name))
+
+	return Key(hash.Sum64()) //nolint:gosec // overflow is intended
+}
+
+// WithPollInterval sets the interval in which locks are retried while waiting for a lock with a
+// timeout on Postgres.
+func WithPollInterval(interval time.Duration) Option {
+	return func(l *locker) {
+		l.pollInterval = interval
+	}
+}
+
+// WithClock sets the clock used for the timeouts of waiting for a lock on Postgres.
+func WithClock(clock atomic.Clock) Option {
+	return func(l *locker) {
+		l.clock = clock
+	}
+}
+
+// New creates a new Locker acquiring session scoped locks on conn, which speaks dialect.
+// Session scoped locks are held by a single connection, so they can not be acquired and released
+// on a pooled *sql.DB.
+func New(conn *sql.Conn, dialect adapter.Dialect, opts ...Option) Locker {
+	return Locker{locker: newLocker(adapter.SQL(conn, dialect), opts...)}
+}
+
+func newLocker(conn adapter.Conn, opts ...Option) locker {
+	l := locker{
+		conn:         conn,
+		pollInterval: defaultPollInterval,
+		clock:        atomic.SystemClock{},
+	}
+
+	for _, opt := range opts {
+		opt(&l)
+	}
+
+	return l
+}
+
+// FromSession creates a new XactLocker acquiring transaction scoped locks on the transaction of
+// the session in ctx. conn creates the connection from the Remote of the session, eg with
+// [adapter.SQL]. Session scoped locks can not be acquired on the transaction of a session, as
+// its connection is returned to the pool after the transaction ended.
+func FromSession[Remote any](
+	ctx context.Context,
+	conn func(Remote) adapter.Conn,
+	opts ...Option,
+) (XactLocker, error) {
+	session, err := generic.RequireSession[Remote](ctx)
+	if err != nil {
+		return XactLocker{}, err //nolint:wrapcheck // sentinel
+	}
+
+	return XactLocker{locker: newLocker(conn(session.Tx), opts...)}, nil
+}
+
+// XactLock acquires the transaction scoped lock key, waiting until it is available.
+func (l XactLocker) XactLock(ctx context.Context, key Key) error {
+	return l.exec(ctx, "SELECT pg_advisory_xact_lock(?)", key)
+}
+
+// TryXactLock acquires the transaction scoped lock key if it is available.
+// It returns false if the lock is held by another transaction.
+func (l XactLocker) TryXactLock(ctx context.Context, key Key) (bool, error) {
+	if l.conn.Dialect() != adapter.Postgres {
+		return false, ErrUnsupportedDialect
+	}
+
+	return l.queryBool(ctx, "SELECT pg_try_advisory_xact_lock(?)", key)
+}
+
+// XactLockTimeout acquires the transaction scoped lock key, waiting at most timeout until it is
+// available. It returns [ErrLockTimeout] if the lock could not be acquired in time.
+func (l XactLocker) XactLockTimeout(ctx context.Context, key Key, timeout time.Duration) error {
+	if l.conn.Dialect() != adapter.Postgres {
+		return ErrUnsupportedDialect
+	}
+
+	return l.poll(ctx, timeout, func() (bool, error) {
+		return l.TryXactLock(ctx, key)
+	})
+}
+
+// Lock acquires the session scoped lock key, waiting until it is available.
+func (l Locker) Lock(ctx context.Context, key Key) error {
+	if l.conn.Dialect() == adapter.MySQL {
+		return l.mysqlLock(ctx, key, -1)
+	}
+
+	return l.exec(ctx, "SELECT pg_advisory_lock(?)", key)
+}
+
+// TryLock acquires the session scoped lock key if it is available.
+// It returns false if the lock is held by another session.
+func (l Locker) TryLock(ctx context.Context, key Key) (bool, error) {
+	if l.conn.Dialect() == adapter.MySQL {
+		err := l.mysqlLock(ctx, key, 0)
+		if errors.Is(err, ErrLockTimeout) {
+			return false, nil
+		}
+
+		return err == nil, err
+	}
+
+	return l.queryBool(ctx, "SELECT pg_try_advisory_lock(?)", key)
+}
+
+// LockTimeout acquires the session scoped lock key, waiting at most timeout until it is
+// available. It returns [ErrLockTimeout] if the lock could not be acquired in time.
+func (l Locker) LockTimeout(ctx context.Context, key Key, timeout time.Duration) error {
+	if l.conn.Dialect() == adapter.MySQL {
+		return l.mysqlLock(ctx, key, timeout)
+	}
+
+	return l.poll(ctx, timeout, func() (bool, error) {
+		return l.TryLock(ctx, key)
+	})
+}
+
+// Unlock releases the session scoped lock key.
+// It returns [ErrNotHeld] if the lock was not held by the connection.
+func (l Locker) Unlock(ctx context.Context, key Key) error {
+	var (
+		released bool
+		err      error
+	)
+
+	switch l.conn.Dialect() {
+	case adapter.MySQL:
+		released, err = l.queryBool(ctx, "SELECT RELEASE_LOCK(?)", mysqlName(key))
+	case adapter.Postgres:
+		released, err = l.queryBool(ctx, "SELECT pg_advisory_unlock(?)", key)
+	default:
+		return ErrUnsupportedDialect
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if !released {
+		return errors.Wrapf(ErrNotHeld, "lock %d", key)
+	}
+
+	return nil
+}
+
+// mysqlLock acquires a lock with GET_LOCK, a negative timeout waits forever.
+func (l locker) mysqlLock(ctx context.Context, key Key, timeout time.Duration) error {
+	seconds := int64(-1)
+	if timeout >= 0 {
+		seconds = int64(math.Ceil(timeout.Seconds()))
+	}
+
+	acquired, err := l.queryBool(ctx, "SELECT GET_LOCK(?, ?)", mysqlName(key), seconds)
+	if err != nil {
+		return err
+	}
+
+	if !acquired {
+		return errors.Wrapf(ErrLockTimeout, "lock %d", key)
+	}
+
+	return nil
+}
+
+// poll calls try until it acquired the lock or timeout elapsed.
+func (l locker) poll(ctx context.Context, timeout time.Duration, try func() (bool, error)) error {
+	deadline := l.clock.Now().Add(timeout)
+
+	for {
+		acquired, err := try()
+		if err != nil || acquired {
+			return err
+		}
+
+		if !l.clock.Now().Before(deadline) {
+			return ErrLockTimeout
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "waiting for advisory lock")
+		case <-l.clock.After(l.pollInterval):
+		}
+	}
+}
+
+func (l locker) exec(ctx context.Context, query string, key Key) error {
+	if l.conn.Dialect() != adapter.Postgres {
+		return ErrUnsupportedDialect
+	}
+
+	_, err := l.conn.Exec(ctx, query, int64(key))
+
+	return errors.Wrapf(err, "acquiring advisory lock %d", key)
+}
+
+// queryBool runs query returning a single boolean or integer column, NULL is returned as false.
+func (l locker) queryBool(ctx context.Context, query string, args ...any) (_ bool, err error) {
+	if l.conn.Dialect() != adapter.Postgres && l.conn.Dialect() != adapter.MySQL {
+		return false, ErrUnsupportedDialect
+	}
+
+	for i, arg := range args {
+		if key, ok := arg.(Key); ok {
+			args[i] = int64(key)
+		}
+	}
+
+	rows, err := l.conn.Query(ctx, query, args...)
+	if err != nil {
+		return false, errors.Wrapf(err, "querying advisory lock")
+	}
+	defer func() {
+		err = multierr.Append(err, errors.Wrap(rows.Close(), "closing rows"))
+	}()
+
+	var result sql.NullBool
+
+	if rows.Next() {
+		err = rows.Scan(&result)
+		if err != nil {
+			return false, errors.Wrap(err, "scanning advisory lock result")
+		}
+	}
+
+	return result.Valid && result.Bool, errors.Wrap(rows.Err(), "querying advisory lock")
+}
+
+func mysqlName(key Key) string {
+	return "atomic_advisory_" + strconv.FormatInt(int64(key), 10)
+}
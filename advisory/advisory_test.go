@@ -0,0 +1,109 @@
+package advisory_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/beeemT/go-atomic/advisory"
+	"github.com/beeemT/go-atomic/generic"
+	"github.com/beeemT/go-atomic/generic/adapter"
+	gsql "github.com/beeemT/go-atomic/generic/sql"
+	"github.com/beeemT/go-atomic/internal/sqlitetest"
+)
+
+func TestStringKey(t *testing.T) {
+	for name, hash := range map[string]uint64{
+		// FNV-1a test vectors
+		"":       0xcbf29ce484222325,
+		"a":      0xaf63dc4c8601ec8c,
+		"foobar": 0x85944171f73967e8,
+	} {
+		if key := advisory.StringKey(name); key != advisory.Key(hash) {
+			t.Errorf("expected key of %q to be %d, got %d", name, advisory.Key(hash), key)
+		}
+	}
+}
+
+func TestXactLockMySQL(t *testing.T) {
+	transacter := generic.NewTransacter[generic.SQLRemote, struct{}](
+		gsql.NewExecuter(sqlitetest.Open(t)),
+		func(
+			context.Context,
+			*generic.Transacter[generic.SQLRemote, struct{}],
+			generic.SQLRemote,
+		) (struct{}, error) {
+			return struct{}{}, nil
+		},
+	)
+
+	err := transacter.Transact(context.Background(), func(ctx context.Context, _ struct{}) error {
+		locker, err := advisory.FromSession(ctx, func(tx generic.SQLRemote) adapter.Conn {
+			return adapter.SQL(tx, adapter.MySQL)
+		})
+		if err != nil {
+			return err
+		}
+
+		key := advisory.StringKey("job")
+
+		err = locker.XactLock(ctx, key)
+		if !errors.Is(err, advisory.ErrUnsupportedDialect) {
+			t.Errorf("expected XactLock to fail with ErrUnsupportedDialect, got %v", err)
+		}
+
+		_, err = locker.TryXactLock(ctx, key)
+		if !errors.Is(err, advisory.ErrUnsupportedDialect) {
+			t.Errorf("expected TryXactLock to fail with ErrUnsupportedDialect, got %v", err)
+		}
+
+		err = locker.XactLockTimeout(ctx, key, time.Second)
+		if !errors.Is(err, advisory.ErrUnsupportedDialect) {
+			t.Errorf("expected XactLockTimeout to fail with ErrUnsupportedDialect, got %v", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("transacting: %v", err)
+	}
+}
+
+func TestFromSessionWithoutSession(t *testing.T) {
+	_, err := advisory.FromSession(
+		context.Background(),
+		func(tx generic.SQLRemote) adapter.Conn {
+			return adapter.SQL(tx, adapter.Postgres)
+		},
+	)
+	if !errors.Is(err, generic.ErrNoSession) {
+		t.Fatalf("expected ErrNoSession, got %v", err)
+	}
+}
+
+func TestLockUnsupportedDialect(t *testing.T) {
+	ctx := context.Background()
+
+	conn, err := sqlitetest.Open(t).Conn(ctx)
+	if err != nil {
+		t.Fatalf("acquiring conn: %v", err)
+	}
+	defer conn.Close()
+
+	locker := advisory.New(conn, adapter.SQLite)
+	key := advisory.StringKey("job")
+
+	_, err = locker.TryLock(ctx, key)
+
+	for name, err := range map[string]error{
+		"Lock":        locker.Lock(ctx, key),
+		"TryLock":     err,
+		"LockTimeout": locker.LockTimeout(ctx, key, time.Second),
+		"Unlock":      locker.Unlock(ctx, key),
+	} {
+		if !errors.Is(err, advisory.ErrUnsupportedDialect) {
+			t.Errorf("expected %s to fail with ErrUnsupportedDialect, got %v", name, err)
+		}
+	}
+}
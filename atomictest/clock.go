@@ -0,0 +1,144 @@
+package atomictest
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/beeemT/go-atomic"
+	"github.com/pkg/errors"
+)
+
+var _ atomic.Clock = (*Clock)(nil)
+
+type (
+	// Clock is a fake [atomic.Clock] whose time only moves when it is advanced by the test, or
+	// when it is waited on if it was created with [WithAutoAdvance]. Clock is safe for concurrent
+	// use.
+	Clock struct {
+		mu          sync.Mutex
+		now         time.Time
+		autoAdvance bool
+		timers      []timer
+		// changed is closed and replaced whenever a timer is added.
+		changed chan struct{}
+	}
+
+	// ClockOption configures the [Clock] instance.
+	ClockOption func(*Clock)
+
+	timer struct {
+		at time.Time
+		c  chan time.Time
+	}
+)
+
+// WithAutoAdvance makes the clock advance to the end of every wait right away, so code waiting on
+// the clock, eg the backoffs of retries, runs without delay and without coordination by the test.
+func WithAutoAdvance() ClockOption {
+	return func(c *Clock) {
+		c.autoAdvance = true
+	}
+}
+
+// NewClock creates a new Clock set to now.
+func NewClock(now time.Time, opts ...ClockOption) *Clock {
+	c := &Clock{
+		now:     now,
+		changed: make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// Now returns the current time of the clock.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// After returns a channel which receives the time of the clock once it was advanced by d.
+func (c *Clock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+
+	if c.autoAdvance && d > 0 {
+		c.now = c.now.Add(d)
+	}
+
+	if d <= 0 || c.autoAdvance {
+		ch <- c.now
+
+		return ch
+	}
+
+	c.timers = append(c.timers, timer{at: c.now.Add(d), c: ch})
+
+	close(c.changed)
+	c.changed = make(chan struct{})
+
+	return ch
+}
+
+// Advance moves the clock forward by d and fires the waits which elapsed, in order of their end.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+
+	sort.SliceStable(c.timers, func(i, j int) bool {
+		return c.timers[i].at.Before(c.timers[j].at)
+	})
+
+	pending := c.timers[:0]
+
+	for _, t := range c.timers {
+		if t.at.After(c.now) {
+			pending = append(pending, t)
+
+			continue
+		}
+
+		t.c <- c.now
+	}
+
+	c.timers = pending
+}
+
+// Waiters returns the number of pending waits on the clock.
+func (c *Clock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.timers)
+}
+
+// BlockUntil blocks until at least n waits are pending on the clock, so the test can advance the
+// clock after the code under test started waiting.
+func (c *Clock) BlockUntil(ctx context.Context, n int) error {
+	for {
+		c.mu.Lock()
+		pending, changed := len(c.timers), c.changed
+		c.mu.Unlock()
+
+		if pending >= n {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "waiting for %d waiters, got %d", n, pending)
+		case <-changed:
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package atomictest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/beeemT/go-atomic/generic"
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+)
+
+var _ generic.Executer[struct{}] = (*Executer[struct{}])(nil)
+
+type (
+	// Executer is a fake [generic.Executer] which passes a fixed Remote to the executed functions
+	// and records every execution. Combined with zero backoffs it exercises the retry logic of
+	// [generic.Transacter] deterministically. Executer is safe for concurrent use.
+	Executer[Remote any] struct {
+		remote Remote
+		faults faults
+
+		mu         sync.Mutex
+		executions []Execution
+	}
+
+	// Execution is a recorded call of [Executer.Execute].
+	Execution struct {
+		// Err is the error returned by the execution.
+		Err error
+		// Committed reports whether the transaction was committed.
+		Committed bool
+		// RolledBack reports whether the transaction was rolled back.
+		RolledBack bool
+	}
+)
+
+// NewExecuter creates a new Executer passing remote to the executed functions.
+func NewExecuter[Remote any](remote Remote) *Executer[Remote] {
+	return &Executer[Remote]{
+		remote: remote,
+	}
+}
+
+// FailBegin queues errors returned instead of opening the transaction of the next executions,
+// one error per execution. A nil error lets the execution succeed.
+func (e *Executer[Remote]) FailBegin(errs ...error) {
+	e.faults.push(&e.faults.begin, errs)
+}
+
+// FailRun queues errors failing the next executions after run returned, one error per execution.
+// The transaction is rolled back. A nil error lets the execution succeed.
+func (e *Executer[Remote]) FailRun(errs ...error) {
+	e.faults.push(&e.faults.run, errs)
+}
+
+// FailCommit queues errors returned by the commit of the next executions, one error per
+// execution. Passing an [atomic.CommitUnknownError] simulates an ambiguous commit.
+// A nil error lets the commit succeed.
+func (e *Executer[Remote]) FailCommit(errs ...error) {
+	e.faults.push(&e.faults.commit, errs)
+}
+
+// Execute calls run with the remote of the executer and records the execution.
+func (e *Executer[Remote]) Execute(_ context.Context, run func(Remote) error) error {
+	execution := e.execute(run)
+
+	e.mu.Lock()
+	e.executions = append(e.executions, execution)
+	e.mu.Unlock()
+
+	return execution.Err
+}
+
+// execute calls run, injecting the queued errors of every phase it reaches.
+func (e *Executer[Remote]) execute(run func(Remote) error) Execution {
+	err := e.faults.next(&e.faults.begin)
+	if err != nil {
+		return Execution{Err: errors.Wrap(err, "beginning transaction")}
+	}
+
+	err = multierr.Append(run(e.remote), e.faults.next(&e.faults.run))
+	if err != nil {
+		return Execution{Err: err, RolledBack: true}
+	}
+
+	err = e.faults.next(&e.faults.commit)
+	if err != nil {
+		return Execution{Err: err}
+	}
+
+	return Execution{Committed: true}
+}
+
+// Executions returns the recorded executions in the order they returned.
+func (e *Executer[Remote]) Executions() []Execution {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return append([]Execution(nil), e.executions...)
+}
+
+// Commits returns the number of committed transactions.
+func (e *Executer[Remote]) Commits() int {
+	return e.count(func(execution Execution) bool {
+		return execution.Committed
+	})
+}
+
+// Rollbacks returns the number of rolled back transactions.
+func (e *Executer[Remote]) Rollbacks() int {
+	return e.count(func(execution Execution) bool {
+		return execution.RolledBack
+	})
+}
+
+// Reset clears the recorded executions and the queued errors.
+func (e *Executer[Remote]) Reset() {
+	e.mu.Lock()
+	e.executions = nil
+	e.mu.Unlock()
+
+	e.faults.reset()
+}
+
+func (e *Executer[Remote]) count(match func(Execution) bool) int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	count := 0
+
+	for _, execution := range e.executions {
+		if match(execution) {
+			count++
+		}
+	}
+
+	return count
+}
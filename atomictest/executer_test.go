@@ -0,0 +1,73 @@
+package atomictest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/beeemT/go-atomic/atomictest"
+)
+
+var (
+	errBegin  = errors.New("begin failed")
+	errCommit = errors.New("commit failed")
+)
+
+func TestExecuterFaultPhases(t *testing.T) {
+	executer := atomictest.NewExecuter(struct{}{})
+	executer.FailBegin(errBegin)
+	executer.FailCommit(errCommit)
+
+	runs := 0
+	run := func(struct{}) error {
+		runs++
+
+		return nil
+	}
+
+	err := executer.Execute(context.Background(), run)
+	if !errors.Is(err, errBegin) {
+		t.Fatalf("expected begin error, got %v", err)
+	}
+
+	// the commit error is kept for the execution reaching the commit
+	err = executer.Execute(context.Background(), run)
+	if !errors.Is(err, errCommit) {
+		t.Fatalf("expected commit error, got %v", err)
+	}
+
+	err = executer.Execute(context.Background(), run)
+	if err != nil {
+		t.Fatalf("executing: %v", err)
+	}
+
+	if runs != 2 || executer.Commits() != 1 || executer.Rollbacks() != 0 {
+		t.Fatalf("unexpected executions %+v", executer.Executions())
+	}
+}
+
+func TestTransacterFaultPhases(t *testing.T) {
+	transacter := atomictest.NewTransacter(struct{}{})
+	transacter.FailBegin(errBegin)
+	transacter.FailCommit(errCommit)
+
+	run := func(context.Context, struct{}) error {
+		return nil
+	}
+
+	if err := transacter.Transact(context.Background(), run); !errors.Is(err, errBegin) {
+		t.Fatalf("expected begin error, got %v", err)
+	}
+
+	if err := transacter.Transact(context.Background(), run); !errors.Is(err, errCommit) {
+		t.Fatalf("expected commit error, got %v", err)
+	}
+
+	if err := transacter.Transact(context.Background(), run); err != nil {
+		t.Fatalf("transacting: %v", err)
+	}
+
+	if transacter.Commits() != 1 {
+		t.Fatalf("unexpected calls %+v", transacter.Calls())
+	}
+}
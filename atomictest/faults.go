@@ -0,0 +1,53 @@
+// Package atomictest provides fakes for testing code built on go-atomic without a database.
+//
+// [Transacter] implements [atomic.Transacter] by running the unit of work with fixed Resources and
+// records every call. [Executer] implements [generic.Executer] with a fixed Remote and can be used
+// to exercise the retry logic of [generic.Transacter] deterministically.
+// Both fakes allow injecting errors at the beginning of a transaction, after run and at commit.
+package atomictest
+
+import "sync"
+
+// faults holds the queued errors injected into the next transactions.
+type faults struct {
+	mu     sync.Mutex
+	begin  []error
+	run    []error
+	commit []error
+}
+
+// next pops the error injected into the next transaction at the phase of queue. Every queue is
+// only popped when its phase is reached, so an error queued for a later phase is kept for the
+// next transaction if an earlier phase failed.
+func (f *faults) next(queue *[]error) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return pop(queue)
+}
+
+func (f *faults) push(queue *[]error, errs []error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	*queue = append(*queue, errs...)
+}
+
+func pop(queue *[]error) error {
+	if len(*queue) == 0 {
+		return nil
+	}
+
+	err := (*queue)[0]
+	*queue = (*queue)[1:]
+
+	return err
+}
+
+// reset clears the queued errors.
+func (f *faults) reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.begin, f.run, f.commit = nil, nil, nil
+}
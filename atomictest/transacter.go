@@ -0,0 +1,167 @@
+package atomictest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/beeemT/go-atomic"
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+)
+
+var _ atomic.Transacter[struct{}] = (*Transacter[struct{}])(nil)
+
+type (
+	// Transacter is a fake [atomic.Transacter] which runs the unit of work with fixed Resources.
+	// Nested calls reuse the transaction of the outermost call, which is committed or rolled back
+	// when the outermost call returns. Transacter is safe for concurrent use.
+	Transacter[Resources any] struct {
+		resources Resources
+		faults    faults
+
+		mu    sync.Mutex
+		calls []Call
+	}
+
+	// Call is a recorded call of [Transacter.Transact].
+	Call struct {
+		// Depth is the nesting depth of the call, 0 for calls opening a new transaction.
+		Depth int
+		// Err is the error returned by the call.
+		Err error
+		// Committed reports whether the call committed its transaction.
+		Committed bool
+		// RolledBack reports whether the call rolled back its transaction.
+		RolledBack bool
+	}
+
+	// depthContextKey is the context key of the nesting depth of calls of a transacter.
+	depthContextKey struct {
+		transacter any
+	}
+)
+
+// NewTransacter creates a new Transacter running units of work with resources.
+func NewTransacter[Resources any](resources Resources) *Transacter[Resources] {
+	return &Transacter[Resources]{
+		resources: resources,
+	}
+}
+
+// FailBegin queues errors returned instead of opening the transaction of the next calls, one
+// error per call opening a transaction. A nil error lets the call succeed.
+func (t *Transacter[Resources]) FailBegin(errs ...error) {
+	t.faults.push(&t.faults.begin, errs)
+}
+
+// FailRun queues errors failing the unit of work after run returned, one error per call opening
+// a transaction. The transaction is rolled back. A nil error lets the call succeed.
+func (t *Transacter[Resources]) FailRun(errs ...error) {
+	t.faults.push(&t.faults.run, errs)
+}
+
+// FailCommit queues errors returned by the commit of the next transactions, one error per call
+// opening a transaction. A nil error lets the commit succeed.
+func (t *Transacter[Resources]) FailCommit(errs ...error) {
+	t.faults.push(&t.faults.commit, errs)
+}
+
+// Transact runs run with the resources of the transacter and records the call.
+func (t *Transacter[Resources]) Transact(
+	ctx context.Context,
+	run func(context.Context, Resources) error,
+) error {
+	depth, nested := ctx.Value(depthContextKey{transacter: t}).(int)
+	if nested {
+		depth++
+
+		err := run(context.WithValue(ctx, depthContextKey{transacter: t}, depth), t.resources)
+		t.record(Call{Depth: depth, Err: err})
+
+		return err
+	}
+
+	call := t.transact(ctx, run)
+	t.record(call)
+
+	return call.Err
+}
+
+// transact runs run in a new transaction, injecting the queued errors of every phase it reaches.
+func (t *Transacter[Resources]) transact(
+	ctx context.Context,
+	run func(context.Context, Resources) error,
+) Call {
+	err := t.faults.next(&t.faults.begin)
+	if err != nil {
+		return Call{Err: errors.Wrap(err, "beginning transaction")}
+	}
+
+	err = multierr.Append(
+		run(context.WithValue(ctx, depthContextKey{transacter: t}, 0), t.resources),
+		t.faults.next(&t.faults.run),
+	)
+	if err != nil {
+		return Call{Err: err, RolledBack: true}
+	}
+
+	err = t.faults.next(&t.faults.commit)
+	if err != nil {
+		return Call{Err: errors.Wrap(err, "committing transaction")}
+	}
+
+	return Call{Committed: true}
+}
+
+// Calls returns the recorded calls in the order they returned.
+func (t *Transacter[Resources]) Calls() []Call {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return append([]Call(nil), t.calls...)
+}
+
+// Commits returns the number of committed transactions.
+func (t *Transacter[Resources]) Commits() int {
+	return t.count(func(call Call) bool {
+		return call.Committed
+	})
+}
+
+// Rollbacks returns the number of rolled back transactions.
+func (t *Transacter[Resources]) Rollbacks() int {
+	return t.count(func(call Call) bool {
+		return call.RolledBack
+	})
+}
+
+// Reset clears the recorded calls and the queued errors.
+func (t *Transacter[Resources]) Reset() {
+	t.mu.Lock()
+	t.calls = nil
+	t.mu.Unlock()
+
+	t.faults.reset()
+}
+
+func (t *Transacter[Resources]) record(call Call) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.calls = append(t.calls, call)
+}
+
+func (t *Transacter[Resources]) count(match func(Call) bool) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	count := 0
+
+	for _, call := range t.calls {
+		if match(call) {
+			count++
+		}
+	}
+
+	return count
+}
@@ -0,0 +1,315 @@
+// Package atomicvet provides an analyzer reporting misuse of the run functions passed to
+// Transact, ie to implementations of [atomic.Transacter]:
+//   - contexts captured from outside of run, as statements using them do not run in the session
+//     of the transaction;
+//   - database handles captured from outside of run (*sql.DB, *sqlx.DB, *gorm.DB, pgx pools and
+//     connections) and repositories built from them, as their statements bypass the transaction;
+//   - goroutines started inside run which use its context or resources, as they may outlive the
+//     transaction.
+//
+// The analyzer is run with go vet through the atomicvet command:
+//
+//	go install github.com/beeemT/go-atomic/cmd/atomicvet@latest
+//	go vet -vettool=$(which atomicvet) ./...
+package atomicvet
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+const modulePath = "github.com/beeemT/go-atomic"
+
+// Analyzer reports captured contexts, captured database handles and repositories and goroutines
+// using the transaction inside the run functions passed to Transact.
+var Analyzer = &analysis.Analyzer{
+	Name:     "atomicvet",
+	Doc:      "report misuse of the context and remotes inside Transact run functions",
+	URL:      "https://pkg.go.dev/" + modulePath + "/atomicvet",
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+// handleTypes are the database handles which do not run statements in a transaction, by package
+// path and type name.
+var handleTypes = map[string][]string{
+	"database/sql":                    {"DB", "Conn"},
+	"github.com/jmoiron/sqlx":         {"DB", "Conn"},
+	"gorm.io/gorm":                    {"DB"},
+	"github.com/jackc/pgx/v5":         {"Conn"},
+	"github.com/jackc/pgx/v5/pgxpool": {"Pool", "Conn"},
+	"github.com/jackc/pgx/v4":         {"Conn"},
+	"github.com/jackc/pgx/v4/pgxpool": {"Pool", "Conn"},
+}
+
+type checker struct {
+	pass *analysis.Pass
+	// assignments holds the expressions assigned to the variables of the package.
+	assignments map[*types.Var][]ast.Expr
+	reported    map[token.Pos]bool
+}
+
+func run(pass *analysis.Pass) (any, error) {
+	inspect, _ := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+
+	c := checker{
+		pass:        pass,
+		assignments: assignments(pass, inspect),
+		reported:    map[token.Pos]bool{},
+	}
+
+	inspect.Preorder([]ast.Node{(*ast.CallExpr)(nil)}, func(node ast.Node) {
+		call, _ := node.(*ast.CallExpr)
+		if !isTransact(pass, call) {
+			return
+		}
+
+		lit, ok := astutil.Unparen(call.Args[1]).(*ast.FuncLit)
+		if !ok {
+			return
+		}
+
+		c.checkRun(lit)
+	})
+
+	return nil, nil //nolint:nilnil // the analyzer has no result
+}
+
+// checkRun reports misuse inside the run function lit.
+func (c checker) checkRun(lit *ast.FuncLit) {
+	params := map[types.Object]bool{}
+
+	for _, field := range lit.Type.Params.List {
+		for _, name := range field.Names {
+			if obj := c.pass.TypesInfo.Defs[name]; obj != nil {
+				params[obj] = true
+			}
+		}
+	}
+
+	ast.Inspect(lit.Body, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.GoStmt:
+			if uses(c.pass, node.Call, params) {
+				c.report(
+					node.Pos(),
+					"goroutine started inside Transact uses the transaction, "+
+						"it may outlive the transaction",
+				)
+			}
+		case *ast.Ident:
+			c.checkCaptured(lit, node)
+		}
+
+		return true
+	})
+}
+
+// checkCaptured reports ident if it refers to a context, database handle or repository declared
+// outside of lit.
+func (c checker) checkCaptured(lit *ast.FuncLit, ident *ast.Ident) {
+	v, ok := c.pass.TypesInfo.Uses[ident].(*types.Var)
+	if !ok || v.IsField() || v.Pkg() != c.pass.Pkg {
+		return
+	}
+
+	if v.Pos() >= lit.Pos() && v.Pos() < lit.End() {
+		// declared inside of run
+		return
+	}
+
+	switch {
+	case isContext(v.Type()):
+		c.report(
+			ident.Pos(),
+			"context %s captured by Transact run function, use the context passed to run",
+			v.Name(),
+		)
+	case isHandle(v.Type()):
+		c.report(
+			ident.Pos(),
+			"database handle %s captured by Transact run function, "+
+				"its statements bypass the transaction",
+			v.Name(),
+		)
+	case !isExempt(v.Type()) && c.builtFromHandle(v):
+		c.report(
+			ident.Pos(),
+			"%s built from a database handle captured by Transact run function, "+
+				"use the resources passed to run",
+			v.Name(),
+		)
+	}
+}
+
+func (c checker) report(pos token.Pos, format string, args ...any) {
+	if c.reported[pos] {
+		return
+	}
+
+	c.reported[pos] = true
+	c.pass.Reportf(pos, format, args...)
+}
+
+// builtFromHandle reports whether an expression assigned to v uses a database handle.
+func (c checker) builtFromHandle(v *types.Var) bool {
+	for _, expr := range c.assignments[v] {
+		found := false
+
+		ast.Inspect(expr, func(node ast.Node) bool {
+			e, ok := node.(ast.Expr)
+			if found || !ok {
+				return !found
+			}
+
+			found = isHandle(c.pass.TypesInfo.TypeOf(e))
+
+			return !found
+		})
+
+		if found {
+			return true
+		}
+	}
+
+	return false
+}
+
+// assignments collects the expressions assigned to the variables of the package.
+func assignments(pass *analysis.Pass, inspect *inspector.Inspector) map[*types.Var][]ast.Expr {
+	result := map[*types.Var][]ast.Expr{}
+
+	add := func(lhs []*ast.Ident, rhs []ast.Expr) {
+		for i, ident := range lhs {
+			v, ok := pass.TypesInfo.ObjectOf(ident).(*types.Var)
+			if !ok {
+				continue
+			}
+
+			switch {
+			case len(lhs) == len(rhs):
+				result[v] = append(result[v], rhs[i])
+			case len(rhs) == 1:
+				// multi-value call
+				result[v] = append(result[v], rhs[0])
+			}
+		}
+	}
+
+	nodes := []ast.Node{(*ast.AssignStmt)(nil), (*ast.ValueSpec)(nil)}
+
+	inspect.Preorder(nodes, func(node ast.Node) {
+		switch node := node.(type) {
+		case *ast.AssignStmt:
+			lhs := make([]*ast.Ident, 0, len(node.Lhs))
+
+			for _, expr := range node.Lhs {
+				if ident, ok := expr.(*ast.Ident); ok {
+					lhs = append(lhs, ident)
+				}
+			}
+
+			if len(lhs) == len(node.Lhs) {
+				add(lhs, node.Rhs)
+			}
+		case *ast.ValueSpec:
+			add(node.Names, node.Values)
+		}
+	})
+
+	return result
+}
+
+// isTransact reports whether call calls a method Transact(ctx, func(ctx, Resources) error) error.
+func isTransact(pass *analysis.Pass, call *ast.CallExpr) bool {
+	fn, ok := typeutil.Callee(pass.TypesInfo, call).(*types.Func)
+	if !ok || fn.Name() != "Transact" || len(call.Args) != 2 {
+		return false
+	}
+
+	sig, _ := fn.Type().(*types.Signature)
+	if sig == nil || sig.Recv() == nil || sig.Params().Len() != 2 ||
+		!isContext(sig.Params().At(0).Type()) {
+		return false
+	}
+
+	run, ok := sig.Params().At(1).Type().Underlying().(*types.Signature)
+
+	return ok && run.Params().Len() == 2 && isContext(run.Params().At(0).Type())
+}
+
+// uses reports whether node refers to one of objs.
+func uses(pass *analysis.Pass, node ast.Node, objs map[types.Object]bool) bool {
+	found := false
+
+	ast.Inspect(node, func(node ast.Node) bool {
+		if ident, ok := node.(*ast.Ident); ok && objs[pass.TypesInfo.Uses[ident]] {
+			found = true
+		}
+
+		return !found
+	})
+
+	return found
+}
+
+func isContext(t types.Type) bool {
+	return isNamed(t, "context", "Context")
+}
+
+func isHandle(t types.Type) bool {
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+
+	for path, names := range handleTypes {
+		for _, name := range names {
+			if isNamed(t, path, name) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// isExempt reports whether values of t are built from database handles by design, ie the
+// transacters and executers of go-atomic and other types providing a Transact method.
+func isExempt(t types.Type) bool {
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+
+	if named, ok := t.(*types.Named); ok && named.Obj().Pkg() != nil &&
+		strings.HasPrefix(named.Obj().Pkg().Path(), modulePath) {
+		return true
+	}
+
+	obj, _, _ := types.LookupFieldOrMethod(t, true, nil, "Transact")
+	_, isMethod := obj.(*types.Func)
+
+	return isMethod
+}
+
+func isNamed(t types.Type, path string, name string) bool {
+	if t == nil {
+		return false
+	}
+
+	named, ok := t.(*types.Named)
+	if !ok {
+		return false
+	}
+
+	obj := named.Obj()
+
+	return obj.Pkg() != nil && obj.Pkg().Path() == path && obj.Name() == name
+}
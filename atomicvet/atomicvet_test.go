@@ -0,0 +1,196 @@
+package atomicvet_test
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/beeemT/go-atomic/atomicvet"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+// wantPattern matches the expectations of the testdata, which follow the conventions of
+// analysistest: a comment `// want "regexp"` on the line of every expected diagnostic.
+var wantPattern = regexp.MustCompile("// want (\"(?:[^\"\\\\]|\\\\.)*\"|`[^`]*`)")
+
+func TestAnalyzer(t *testing.T) {
+	run(t, "captured")
+}
+
+// run runs the analyzer on the package path in testdata/src and checks its diagnostics against
+// the want comments. Imports are resolved from testdata/src before the standard library.
+func run(t *testing.T, path string) {
+	t.Helper()
+
+	fset := token.NewFileSet()
+	imp := &testdataImporter{
+		fset:     fset,
+		root:     filepath.Join("testdata", "src"),
+		packages: map[string]*types.Package{},
+		fallback: importer.Default(),
+	}
+
+	files, pkg, info, err := imp.check(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var diagnostics []analysis.Diagnostic
+
+	pass := &analysis.Pass{
+		Analyzer:   atomicvet.Analyzer,
+		Fset:       fset,
+		Files:      files,
+		Pkg:        pkg,
+		TypesInfo:  info,
+		TypesSizes: types.SizesFor("gc", "amd64"),
+		ResultOf:   map[*analysis.Analyzer]any{inspect.Analyzer: inspector.New(files)},
+		Report: func(d analysis.Diagnostic) {
+			diagnostics = append(diagnostics, d)
+		},
+	}
+
+	_, err = atomicvet.Analyzer.Run(pass)
+	if err != nil {
+		t.Fatalf("running analyzer: %v", err)
+	}
+
+	wants := expectations(t, fset, files)
+
+	for _, d := range diagnostics {
+		position := fset.Position(d.Pos)
+		line := position.Filename + ":" + strconv.Itoa(position.Line)
+
+		matched := false
+
+		for i, want := range wants[line] {
+			if want.MatchString(d.Message) {
+				wants[line] = append(wants[line][:i], wants[line][i+1:]...)
+				matched = true
+
+				break
+			}
+		}
+
+		if !matched {
+			t.Errorf("%s: unexpected diagnostic: %s", position, d.Message)
+		}
+	}
+
+	for line, patterns := range wants {
+		for _, want := range patterns {
+			t.Errorf("%s: no diagnostic matching %q", line, want)
+		}
+	}
+}
+
+func expectations(
+	t *testing.T,
+	fset *token.FileSet,
+	files []*ast.File,
+) map[string][]*regexp.Regexp {
+	t.Helper()
+
+	wants := map[string][]*regexp.Regexp{}
+
+	for _, file := range files {
+		for _, group := range file.Comments {
+			for _, comment := range group.List {
+				match := wantPattern.FindStringSubmatch(comment.Text)
+				if match == nil {
+					continue
+				}
+
+				pattern, err := strconv.Unquote(match[1])
+				if err != nil {
+					t.Fatalf("unquoting %s: %v", match[1], err)
+				}
+
+				position := fset.Position(comment.Pos())
+				line := position.Filename + ":" + strconv.Itoa(position.Line)
+				wants[line] = append(wants[line], regexp.MustCompile(pattern))
+			}
+		}
+	}
+
+	return wants
+}
+
+// testdataImporter type checks the packages in root and imports all other packages with
+// fallback.
+type testdataImporter struct {
+	fset     *token.FileSet
+	root     string
+	packages map[string]*types.Package
+	fallback types.Importer
+}
+
+func (imp *testdataImporter) Import(path string) (*types.Package, error) {
+	if pkg, ok := imp.packages[path]; ok {
+		return pkg, nil
+	}
+
+	if _, err := os.Stat(filepath.Join(imp.root, path)); err != nil {
+		return imp.fallback.Import(path) //nolint:wrapcheck // passed through to the type checker
+	}
+
+	_, pkg, _, err := imp.check(path)
+
+	return pkg, err
+}
+
+func (imp *testdataImporter) check(
+	path string,
+) ([]*ast.File, *types.Package, *types.Info, error) {
+	dir := filepath.Join(imp.root, path)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, nil, err //nolint:wrapcheck // test helper
+	}
+
+	var files []*ast.File
+
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".go") {
+			continue
+		}
+
+		file, err := parser.ParseFile(
+			imp.fset, filepath.Join(dir, entry.Name()), nil, parser.ParseComments,
+		)
+		if err != nil {
+			return nil, nil, nil, err //nolint:wrapcheck // test helper
+		}
+
+		files = append(files, file)
+	}
+
+	info := &types.Info{
+		Types:      map[ast.Expr]types.TypeAndValue{},
+		Defs:       map[*ast.Ident]types.Object{},
+		Uses:       map[*ast.Ident]types.Object{},
+		Implicits:  map[ast.Node]types.Object{},
+		Selections: map[*ast.SelectorExpr]*types.Selection{},
+		Scopes:     map[ast.Node]*types.Scope{},
+	}
+
+	pkg, err := (&types.Config{Importer: imp}).Check(path, imp.fset, files, info)
+	if err != nil {
+		return nil, nil, nil, err //nolint:wrapcheck // test helper
+	}
+
+	imp.packages[path] = pkg
+
+	return files, pkg, info, nil
+}
@@ -0,0 +1,103 @@
+package captured
+
+import (
+	"context"
+	"database/sql"
+
+	"gorm.io/gorm"
+)
+
+type (
+	transacter struct {
+		db *sql.DB
+	}
+
+	resources struct {
+		tx *sql.Tx
+	}
+
+	repository struct {
+		db *sql.DB
+	}
+)
+
+func (transacter) Transact(
+	ctx context.Context,
+	run func(context.Context, resources) error,
+) error {
+	return run(ctx, resources{})
+}
+
+func (r repository) save(context.Context) error {
+	return nil
+}
+
+func newRepository(db *sql.DB) repository {
+	return repository{db: db}
+}
+
+func contexts(ctx context.Context, t transacter) error {
+	_ = ctx
+
+	return t.Transact(ctx, func(txCtx context.Context, res resources) error {
+		_, err := res.tx.ExecContext(ctx, "DELETE FROM users") // want `context ctx captured`
+		if err != nil {
+			return err
+		}
+
+		inner, cancel := context.WithCancel(txCtx)
+		defer cancel()
+
+		_, err = res.tx.ExecContext(inner, "DELETE FROM users")
+
+		return err
+	})
+}
+
+func handles(ctx context.Context, t transacter, db *sql.DB, gdb *gorm.DB) error {
+	_, _ = db.ExecContext(ctx, "DELETE FROM users")
+
+	return t.Transact(ctx, func(ctx context.Context, res resources) error {
+		_, err := db.ExecContext(ctx, "DELETE FROM users") // want `database handle db captured`
+		if err != nil {
+			return err
+		}
+
+		gdb.Exec("DELETE FROM users") // want `database handle gdb captured`
+
+		_, err = res.tx.ExecContext(ctx, "DELETE FROM users")
+
+		return err
+	})
+}
+
+func repositories(ctx context.Context, db *sql.DB) error {
+	repo := newRepository(db)
+	t := transacter{db: db}
+
+	return t.Transact(ctx, func(ctx context.Context, res resources) error {
+		err := repo.save(ctx) // want `repo built from a database handle captured`
+		if err != nil {
+			return err
+		}
+
+		// transacters are built from database handles by design
+		return t.Transact(ctx, func(ctx context.Context, _ resources) error {
+			return repository{}.save(ctx)
+		})
+	})
+}
+
+func goroutines(ctx context.Context, t transacter, done chan struct{}) error {
+	return t.Transact(ctx, func(ctx context.Context, res resources) error {
+		go func() { // want `goroutine started inside Transact uses the transaction`
+			_, _ = res.tx.ExecContext(ctx, "DELETE FROM users")
+		}()
+
+		go func() {
+			close(done)
+		}()
+
+		return nil
+	})
+}
@@ -0,0 +1,8 @@
+// Package gorm is a stub of gorm.io/gorm for the tests of atomicvet.
+package gorm
+
+type DB struct{}
+
+func (db *DB) Exec(sql string, values ...any) *DB {
+	return db
+}
@@ -0,0 +1,29 @@
+package atomic
+
+import "time"
+
+var _ Clock = SystemClock{}
+
+type (
+	// Clock provides the current time and timers. It is injected into the retry function and
+	// other time based components, so tests can replace the system clock with a fake one.
+	Clock interface {
+		// Now returns the current time.
+		Now() time.Time
+		// After returns a channel which receives the current time after d elapsed.
+		After(d time.Duration) <-chan time.Time
+	}
+
+	// SystemClock is the [Clock] of the system, ie of the time package.
+	SystemClock struct{}
+)
+
+// Now returns time.Now.
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// After returns time.After.
+func (SystemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
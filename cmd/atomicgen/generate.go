@@ -0,0 +1,711 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/beeemT/go-atomic/internal/depsort"
+	"github.com/pkg/errors"
+)
+
+const (
+	directive = "//atomicgen:resources"
+	// buildTag excludes previously generated files while loading the package, so they do not
+	// interfere with the generation.
+	buildTag = "atomicgen"
+
+	genericPath = "github.com/beeemT/go-atomic/generic"
+)
+
+type (
+	// pkg is the type checked package scanned for annotated structs.
+	pkg struct {
+		dir   string
+		fset  *token.FileSet
+		files []*ast.File
+		types *types.Package
+		info  *types.Info
+	}
+
+	// resources is a struct annotated with the directive.
+	resources struct {
+		named    *types.Named
+		remote   types.Type
+		funcName string
+		fields   []*field
+	}
+
+	// field is a field of a resources struct and the constructor creating its value.
+	field struct {
+		name        string
+		typ         types.Type
+		constructor *types.Func
+		args        []argument
+		fails       bool
+	}
+
+	argumentKind int
+
+	// argument is an argument passed to a constructor.
+	argument struct {
+		kind argumentKind
+		// dependency is the field passed for arguments of kind dependencyArgument.
+		dependency *field
+	}
+
+	// imports tracks the packages used by the generated code.
+	imports struct {
+		pkg   *types.Package
+		names map[string]string
+		used  map[string]bool
+	}
+
+	// locals are the names of the parameters and variables of a factory function. They are
+	// renamed if they collide with identifiers of the package, which they would shadow.
+	locals struct {
+		ctx        string
+		transacter string
+		tx         string
+		resources  string
+		err        string
+	}
+)
+
+const (
+	contextArgument argumentKind = iota + 1
+	remoteArgument
+	transacterArgument
+	dependencyArgument
+)
+
+func run(dir string, output string) error {
+	source, err := generateDir(dir)
+	if err != nil {
+		return err
+	}
+
+	//nolint:gosec // generated go files are not secret
+	err = os.WriteFile(filepath.Join(dir, output), source, 0o644)
+
+	return errors.Wrap(err, "writing generated file")
+}
+
+// generateDir returns the generated source for the package in dir.
+func generateDir(dir string) ([]byte, error) {
+	p, err := load(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	all, err := find(p)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(all) == 0 {
+		return nil, errors.Errorf("no struct annotated with %s in %s", directive, dir)
+	}
+
+	for _, r := range all {
+		err = r.resolve()
+		if err != nil {
+			return nil, errors.Wrapf(err, "resolving constructors of %s", r.named.Obj().Name())
+		}
+	}
+
+	return generate(p.types, all)
+}
+
+// load parses and type checks the package in dir. Files generated before are excluded by the
+// build tag. Type errors are ignored, as the package may refer to the excluded files.
+func load(dir string) (*pkg, error) {
+	buildContext := build.Default
+	buildContext.BuildTags = append(buildContext.BuildTags, buildTag)
+
+	buildPkg, err := buildContext.ImportDir(dir, 0)
+	if err != nil {
+		return nil, errors.Wrapf(err, "finding package in %s", dir)
+	}
+
+	p := &pkg{
+		dir:  dir,
+		fset: token.NewFileSet(),
+		info: &types.Info{
+			Types: map[ast.Expr]types.TypeAndValue{},
+			Defs:  map[*ast.Ident]types.Object{},
+			Uses:  map[*ast.Ident]types.Object{},
+		},
+	}
+
+	for _, name := range buildPkg.GoFiles {
+		file, err := parser.ParseFile(p.fset, filepath.Join(dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing %s", name)
+		}
+
+		p.files = append(p.files, file)
+	}
+
+	config := types.Config{
+		Importer: importer.ForCompiler(p.fset, "source", nil),
+		// the package may use the functions of files generated before, which are excluded
+		Error: func(error) {},
+	}
+
+	p.types, _ = config.Check(buildPkg.ImportPath, p.fset, p.files, p.info)
+
+	return p, nil
+}
+
+// find returns the structs of p annotated with the directive.
+func find(p *pkg) ([]*resources, error) {
+	var all []*resources
+
+	for _, file := range p.files {
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+
+			for _, spec := range gen.Specs {
+				typeSpec, _ := spec.(*ast.TypeSpec)
+
+				doc := typeSpec.Doc
+				if doc == nil && len(gen.Specs) == 1 {
+					doc = gen.Doc
+				}
+
+				args, ok := parseDirective(doc)
+				if !ok {
+					continue
+				}
+
+				r, err := newResources(p, typeSpec, args)
+				if err != nil {
+					return nil, errors.Wrapf(err, "%s", p.fset.Position(typeSpec.Pos()))
+				}
+
+				all = append(all, r)
+			}
+		}
+	}
+
+	return all, nil
+}
+
+// parseDirective returns the key value arguments of the directive in doc.
+func parseDirective(doc *ast.CommentGroup) (map[string]string, bool) {
+	if doc == nil {
+		return nil, false
+	}
+
+	for _, comment := range doc.List {
+		rest, ok := strings.CutPrefix(comment.Text, directive)
+		if !ok || (rest != "" && rest[0] != ' ' && rest[0] != '\t') {
+			continue
+		}
+
+		args := map[string]string{}
+
+		for _, arg := range strings.Fields(rest) {
+			key, value, _ := strings.Cut(arg, "=")
+			args[key] = value
+		}
+
+		return args, true
+	}
+
+	return nil, false
+}
+
+func newResources(p *pkg, spec *ast.TypeSpec, args map[string]string) (*resources, error) {
+	obj, _ := p.info.Defs[spec.Name].(*types.TypeName)
+	if obj == nil {
+		return nil, errors.Errorf("unknown type %s", spec.Name.Name)
+	}
+
+	named, ok := obj.Type().(*types.Named)
+	if !ok || named.TypeParams().Len() > 0 {
+		return nil, errors.Errorf("%s is not a non-generic named type", obj.Name())
+	}
+
+	structType, ok := named.Underlying().(*types.Struct)
+	if !ok {
+		return nil, errors.Errorf("%s is not a struct", obj.Name())
+	}
+
+	if args["remote"] == "" {
+		return nil, errors.New("missing remote=<type> argument of directive")
+	}
+
+	remote, err := types.Eval(p.fset, p.types, spec.Pos(), args["remote"])
+	if err != nil {
+		return nil, errors.Wrapf(err, "evaluating remote type %s", args["remote"])
+	}
+
+	if !remote.IsType() {
+		return nil, errors.Errorf("remote %s is not a type", args["remote"])
+	}
+
+	r := &resources{
+		named:    named,
+		remote:   remote.Type,
+		funcName: args["func"],
+	}
+
+	if r.funcName == "" {
+		r.funcName = "new" + obj.Name()
+	}
+
+	for i := 0; i < structType.NumFields(); i++ {
+		r.fields = append(r.fields, &field{
+			name: structType.Field(i).Name(),
+			typ:  structType.Field(i).Type(),
+		})
+	}
+
+	return r, nil
+}
+
+// resolve finds the constructors of the fields and orders the fields by their dependencies.
+func (r *resources) resolve() error {
+	for _, f := range r.fields {
+		err := r.resolveField(f)
+		if err != nil {
+			return errors.Wrapf(err, "field %s", f.name)
+		}
+	}
+
+	ordered, err := depsort.Sort(
+		r.fields,
+		func(f *field) []*field {
+			var dependencies []*field
+
+			for _, arg := range f.args {
+				if arg.kind == dependencyArgument {
+					dependencies = append(dependencies, arg.dependency)
+				}
+			}
+
+			return dependencies
+		},
+		func(f *field) string {
+			return f.name
+		},
+	)
+	if err != nil {
+		return err //nolint:wrapcheck // wrapped by the caller
+	}
+
+	r.fields = ordered
+
+	return nil
+}
+
+// resolveField finds the constructor of f in the package of the struct and of the field type.
+func (r *resources) resolveField(f *field) error {
+	scopes := []*types.Scope{r.named.Obj().Pkg().Scope()}
+
+	named := namedOf(f.typ)
+	if named != nil && named.Obj().Pkg() != nil && named.Obj().Pkg() != r.named.Obj().Pkg() {
+		scopes = append(scopes, named.Obj().Pkg().Scope())
+	}
+
+	var candidates []*field
+
+	for _, scope := range scopes {
+		for _, name := range scope.Names() {
+			fn, ok := scope.Lookup(name).(*types.Func)
+			if !ok || (fn.Pkg() != r.named.Obj().Pkg() && !fn.Exported()) {
+				continue
+			}
+
+			candidate, ok := r.match(f, fn)
+			if ok {
+				candidates = append(candidates, candidate)
+			}
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		return errors.Errorf(
+			"no constructor of %s with the Remote or transacter as first parameter",
+			r.typeString(f.typ),
+		)
+	case 1:
+		*f = *candidates[0]
+
+		return nil
+	}
+
+	names := make([]string, 0, len(candidates))
+
+	for _, candidate := range candidates {
+		if named != nil && candidate.constructor.Name() == "New"+named.Obj().Name() {
+			*f = *candidate
+
+			return nil
+		}
+
+		names = append(names, candidate.constructor.Name())
+	}
+
+	return errors.Errorf(
+		"ambiguous constructors of %s: %s",
+		r.typeString(f.typ), strings.Join(names, ", "),
+	)
+}
+
+// typeString returns t qualified relative to the package of the struct.
+func (r *resources) typeString(t types.Type) string {
+	return types.TypeString(t, types.RelativeTo(r.named.Obj().Pkg()))
+}
+
+// match returns f with fn as constructor if fn creates the type of f from the available
+// arguments.
+func (r *resources) match(f *field, fn *types.Func) (*field, bool) {
+	sig, _ := fn.Type().(*types.Signature)
+	if sig == nil || sig.Recv() != nil || sig.TypeParams().Len() > 0 || sig.Variadic() ||
+		sig.Params().Len() == 0 {
+		return nil, false
+	}
+
+	results := sig.Results()
+
+	switch {
+	case results.Len() == 1 && types.Identical(results.At(0).Type(), f.typ):
+	case results.Len() == 2 && types.Identical(results.At(0).Type(), f.typ) &&
+		isError(results.At(1).Type()):
+	default:
+		return nil, false
+	}
+
+	candidate := &field{
+		name:        f.name,
+		typ:         f.typ,
+		constructor: fn,
+		fails:       results.Len() == 2,
+	}
+
+	first := true
+
+	for i := 0; i < sig.Params().Len(); i++ {
+		arg, ok := r.argument(f, sig.Params().At(i).Type())
+		if !ok {
+			return nil, false
+		}
+
+		if first && arg.kind != contextArgument {
+			// the first parameter after an optional context has to be the Remote or transacter
+			if arg.kind != remoteArgument && arg.kind != transacterArgument {
+				return nil, false
+			}
+
+			first = false
+		}
+
+		candidate.args = append(candidate.args, arg)
+	}
+
+	return candidate, !first
+}
+
+// argument returns the argument passed to a parameter of type param of the constructor of f.
+func (r *resources) argument(f *field, param types.Type) (argument, bool) {
+	switch {
+	case isNamed(param, "context", "Context"):
+		return argument{kind: contextArgument}, true
+	case types.Identical(param, r.remote):
+		return argument{kind: remoteArgument}, true
+	case r.isTransacter(param):
+		return argument{kind: transacterArgument}, true
+	}
+
+	var dependency *field
+
+	for _, other := range r.fields {
+		if other == f || !types.Identical(other.typ, param) {
+			continue
+		}
+
+		if dependency != nil {
+			// ambiguous dependency
+			return argument{}, false
+		}
+
+		dependency = other
+	}
+
+	if dependency != nil {
+		return argument{kind: dependencyArgument, dependency: dependency}, true
+	}
+
+	iface, ok := param.Underlying().(*types.Interface)
+	if ok && !iface.Empty() && types.AssignableTo(r.remote, param) {
+		// an interface satisfied by the Remote, eg a subset of its methods
+		return argument{kind: remoteArgument}, true
+	}
+
+	return argument{}, false
+}
+
+// isTransacter reports whether t is *generic.Transacter[Remote, Resources].
+func (r *resources) isTransacter(t types.Type) bool {
+	ptr, ok := t.(*types.Pointer)
+	if !ok || !isNamed(ptr.Elem(), genericPath, "Transacter") {
+		return false
+	}
+
+	args := namedOf(ptr.Elem()).TypeArgs()
+
+	return args.Len() == 2 && types.Identical(args.At(0), r.remote) &&
+		types.Identical(args.At(1), r.named)
+}
+
+// generate returns the formatted source of the factory functions of all.
+func generate(pkg *types.Package, all []*resources) ([]byte, error) {
+	// the identifiers of the package and the generated functions can neither be used as local
+	// names nor as import names
+	declared := map[string]bool{}
+
+	for _, name := range pkg.Scope().Names() {
+		declared[name] = true
+	}
+
+	for _, r := range all {
+		declared[r.funcName] = true
+	}
+
+	names := newLocals(declared)
+
+	imps := &imports{
+		pkg:   pkg,
+		names: map[string]string{},
+		used:  map[string]bool{},
+	}
+
+	for name := range declared {
+		imps.used[name] = true
+	}
+
+	for _, name := range names.all() {
+		imps.used[name] = true
+	}
+
+	var body bytes.Buffer
+
+	for _, r := range all {
+		r.generate(&body, imps, names)
+	}
+
+	var source bytes.Buffer
+
+	fmt.Fprintf(&source, "// Code generated by atomicgen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&source, "//go:build !%s\n\n", buildTag)
+	fmt.Fprintf(&source, "package %s\n\n", pkg.Name())
+	imps.generate(&source)
+	source.Write(body.Bytes())
+
+	formatted, err := format.Source(source.Bytes())
+	if err != nil {
+		return nil, errors.Wrapf(err, "formatting generated source:\n%s", source.String())
+	}
+
+	return formatted, nil
+}
+
+func (r *resources) generate(w *bytes.Buffer, imps *imports, names locals) {
+	typeName := r.named.Obj().Name()
+	remote := types.TypeString(r.remote, imps.qualifier)
+
+	fmt.Fprintf(
+		w,
+		"// %s creates the %s of a transaction on %s.\n"+
+			"// It is used as createResources function of %s.NewTransacter.\n",
+		r.funcName, typeName, names.tx, imps.name(genericPath, "generic"),
+	)
+	fmt.Fprintf(
+		w,
+		"func %s(\n\t%s %s.Context,\n\t%s *%s.Transacter[%s, %s],\n\t%s %s,\n) "+
+			"(%s, error) {\n",
+		r.funcName,
+		names.ctx, imps.name("context", "context"),
+		names.transacter, imps.name(genericPath, "generic"), remote, typeName,
+		names.tx, remote,
+		typeName,
+	)
+
+	fails := false
+
+	for _, f := range r.fields {
+		fails = fails || f.fails
+	}
+
+	if fails {
+		fmt.Fprintf(w, "var (\n%s %s\n%s error\n)\n\n", names.resources, typeName, names.err)
+	} else {
+		fmt.Fprintf(w, "var %s %s\n\n", names.resources, typeName)
+	}
+
+	for _, f := range r.fields {
+		args := make([]string, 0, len(f.args))
+
+		for _, arg := range f.args {
+			switch arg.kind {
+			case contextArgument:
+				args = append(args, names.ctx)
+			case remoteArgument:
+				args = append(args, names.tx)
+			case transacterArgument:
+				args = append(args, names.transacter)
+			case dependencyArgument:
+				args = append(args, names.resources+"."+arg.dependency.name)
+			}
+		}
+
+		constructor := f.constructor.Name()
+		if qualifier := imps.qualifier(f.constructor.Pkg()); qualifier != "" {
+			constructor = qualifier + "." + constructor
+		}
+
+		call := constructor + "(" + strings.Join(args, ", ") + ")"
+
+		if !f.fails {
+			fmt.Fprintf(w, "%s.%s = %s\n", names.resources, f.name, call)
+
+			continue
+		}
+
+		fmt.Fprintf(w, "\n%s.%s, %s = %s\n", names.resources, f.name, names.err, call)
+		fmt.Fprintf(
+			w,
+			"if %s != nil {\nreturn %s{}, %s.Errorf(\"creating %s: %%w\", %s)\n}\n\n",
+			names.err, typeName, imps.name("fmt", "fmt"), f.name, names.err,
+		)
+	}
+
+	fmt.Fprintf(w, "\nreturn %s, nil\n}\n\n", names.resources)
+}
+
+// newLocals returns the local names of the factory functions, renamed if they are declared.
+func newLocals(declared map[string]bool) locals {
+	return locals{
+		ctx:        unique("ctx", declared),
+		transacter: unique("transacter", declared),
+		tx:         unique("tx", declared),
+		resources:  unique("resources", declared),
+		err:        unique("err", declared),
+	}
+}
+
+func (names locals) all() []string {
+	return []string{names.ctx, names.transacter, names.tx, names.resources, names.err}
+}
+
+// unique returns name with the lowest numeric suffix which is not used.
+func unique(name string, used map[string]bool) string {
+	result := name
+	for i := 2; used[result]; i++ {
+		result = name + strconv.Itoa(i)
+	}
+
+	return result
+}
+
+// qualifier returns the name of pkg in the generated file.
+func (imps *imports) qualifier(pkg *types.Package) string {
+	if pkg == imps.pkg {
+		return ""
+	}
+
+	return imps.name(pkg.Path(), pkg.Name())
+}
+
+// name returns the name of the package with path in the generated file, importing it if
+// necessary.
+func (imps *imports) name(path string, name string) string {
+	if existing, ok := imps.names[path]; ok {
+		return existing
+	}
+
+	result := unique(name, imps.used)
+	imps.names[path] = result
+	imps.used[result] = true
+
+	return result
+}
+
+func (imps *imports) generate(w *bytes.Buffer) {
+	paths := make([]string, 0, len(imps.names))
+
+	for path := range imps.names {
+		paths = append(paths, path)
+	}
+
+	// standard library packages first, separated from the others
+	sort.Slice(paths, func(i, j int) bool {
+		if isStd(paths[i]) != isStd(paths[j]) {
+			return isStd(paths[i])
+		}
+
+		return paths[i] < paths[j]
+	})
+
+	fmt.Fprintf(w, "import (\n")
+
+	for i, path := range paths {
+		if i > 0 && isStd(path) != isStd(paths[i-1]) {
+			fmt.Fprintf(w, "\n")
+		}
+
+		name := imps.names[path]
+		if name == filepath.Base(path) {
+			fmt.Fprintf(w, "%q\n", path)
+		} else {
+			fmt.Fprintf(w, "%s %q\n", name, path)
+		}
+	}
+
+	fmt.Fprintf(w, ")\n\n")
+}
+
+func isStd(path string) bool {
+	first, _, _ := strings.Cut(path, "/")
+
+	return !strings.Contains(first, ".")
+}
+
+func namedOf(t types.Type) *types.Named {
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+
+	named, _ := t.(*types.Named)
+
+	return named
+}
+
+func isNamed(t types.Type, path string, name string) bool {
+	named, ok := t.(*types.Named)
+
+	return ok && named.Obj().Pkg() != nil && named.Obj().Pkg().Path() == path &&
+		named.Obj().Name() == name
+}
+
+func isError(t types.Type) bool {
+	return types.Identical(t, types.Universe.Lookup("error").Type())
+}
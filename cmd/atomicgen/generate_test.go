@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// TestGenerate compares the generated code for every package in testdata with its golden file
+// and checks that the package compiles with it.
+func TestGenerate(t *testing.T) {
+	entries, err := os.ReadDir("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the importer is shared, so the dependencies are only type checked once
+	fset := token.NewFileSet()
+	imp := importer.ForCompiler(fset, "source", nil)
+
+	for _, entry := range entries {
+		dir := filepath.Join("testdata", entry.Name())
+
+		t.Run(entry.Name(), func(t *testing.T) {
+			source, err := generateDir(dir)
+			if err != nil {
+				t.Fatalf("generating: %v", err)
+			}
+
+			golden := filepath.Join(dir, "atomicgen.go.golden")
+			if *update {
+				err = os.WriteFile(golden, source, 0o600)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			expected, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(source, expected) {
+				t.Errorf("generated source differs from %s:\n%s", golden, source)
+			}
+
+			compile(t, fset, imp, dir, source)
+		})
+	}
+}
+
+// compile type checks the package in dir together with the generated source.
+func compile(
+	t *testing.T,
+	fset *token.FileSet,
+	imp types.Importer,
+	dir string,
+	source []byte,
+) {
+	t.Helper()
+
+	generated, err := parser.ParseFile(fset, filepath.Join(dir, "atomicgen.go"), source, 0)
+	if err != nil {
+		t.Fatalf("parsing generated source: %v", err)
+	}
+
+	files := []*ast.File{generated}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+
+		file, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		files = append(files, file)
+	}
+
+	config := types.Config{Importer: imp}
+
+	_, err = config.Check(dir, fset, files, nil)
+	if err != nil {
+		t.Errorf("generated source does not compile: %v", err)
+	}
+}
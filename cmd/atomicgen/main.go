@@ -0,0 +1,56 @@
+// Command atomicgen generates the createResources functions passed to generic.NewTransacter.
+//
+// It scans the package in the directory passed as argument, by default the working directory,
+// for struct types annotated with the directive
+//
+//	//atomicgen:resources remote=<Remote type> [func=<function name>]
+//
+// and generates a factory function for every annotated struct, named new<Struct> by default.
+// The Remote type is an expression valid in the file of the struct, eg generic.SQLRemote.
+//
+// The value of every field is created with a constructor returning the type of the field and
+// optionally an error. Constructors are the functions of the package of the struct and of the
+// package of the field type whose first parameter, after an optional context.Context, is the
+// Remote or the *generic.Transacter.
+// Further parameters can be a context.Context, the Remote, the transacter or the type of another
+// field of the struct, which is then created first. If several constructors match a field,
+// New<Type> is chosen.
+// The parameters, variables and imports of the generated code get a numeric suffix if their names
+// are declared in the package.
+//
+// atomicgen is usually run with go generate:
+//
+//	//go:generate go run github.com/beeemT/go-atomic/cmd/atomicgen
+//
+//	//atomicgen:resources remote=generic.SQLRemote
+//	type Resources struct {
+//		Foos FooRepo
+//		Bars BarRepo
+//	}
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+func main() {
+	output := flag.String("output", "atomicgen.go", "name of the generated file")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: atomicgen [-output file] [dir]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+
+	err := run(dir, *output)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "atomicgen: %v\n", err)
+		os.Exit(1)
+	}
+}
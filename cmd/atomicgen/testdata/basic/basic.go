@@ -0,0 +1,46 @@
+package basic
+
+import (
+	"context"
+
+	"github.com/beeemT/go-atomic/generic"
+)
+
+type (
+	//atomicgen:resources remote=generic.SQLRemote
+	Resources struct {
+		Orders  OrderRepo
+		Users   UserRepo
+		Outbox  Outbox
+		Clocked Clocked
+	}
+
+	UserRepo struct {
+		tx generic.SQLRemote
+	}
+
+	OrderRepo struct {
+		users UserRepo
+	}
+
+	Outbox struct{}
+
+	Clocked struct{}
+)
+
+func NewUserRepo(tx generic.SQLRemote) UserRepo {
+	return UserRepo{tx: tx}
+}
+
+// NewOrderRepo depends on the UserRepo, which is created first.
+func NewOrderRepo(ctx context.Context, tx generic.SQLRemote, users UserRepo) (OrderRepo, error) {
+	return OrderRepo{users: users}, nil
+}
+
+func NewOutbox(tx generic.SQLRemote) (Outbox, error) {
+	return Outbox{}, nil
+}
+
+func NewClocked(transacter *generic.Transacter[generic.SQLRemote, Resources]) Clocked {
+	return Clocked{}
+}
@@ -0,0 +1,41 @@
+// Package collisions declares the identifiers used by the generated code, which has to rename its
+// locals and imports.
+package collisions
+
+import (
+	stdcontext "context"
+
+	gen "github.com/beeemT/go-atomic/generic"
+)
+
+var (
+	ctx        = stdcontext.Background()
+	transacter = "transacter"
+	fmt        = "fmt"
+	context    = "context"
+	generic    = "generic"
+)
+
+type (
+	//atomicgen:resources remote=gen.SQLRemote
+	resources struct {
+		repo repo
+		tx   tx
+	}
+
+	repo struct{}
+
+	tx struct{}
+)
+
+func err() error {
+	return nil
+}
+
+func newRepo(c stdcontext.Context, remote gen.SQLRemote) (repo, error) {
+	return repo{}, err()
+}
+
+func newTx(t *gen.Transacter[gen.SQLRemote, resources], r repo) tx {
+	return tx{}
+}
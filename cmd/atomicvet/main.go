@@ -0,0 +1,13 @@
+// Command atomicvet runs the [atomicvet.Analyzer] with go vet:
+//
+//	go vet -vettool=$(which atomicvet) ./...
+package main
+
+import (
+	"github.com/beeemT/go-atomic/atomicvet"
+	"golang.org/x/tools/go/analysis/unitchecker"
+)
+
+func main() {
+	unitchecker.Main(atomicvet.Analyzer)
+}
@@ -0,0 +1,86 @@
+package atomic
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"net"
+	"os"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// ErrCommitUnknown is in the chain of errors returned from executors if committing a transaction
+// failed in a way that leaves it unknown whether the commit was applied by the remote.
+// Errors carrying it are never retried by [DefaultRetry], as rerunning the transaction might
+// apply its statements twice.
+var ErrCommitUnknown = errors.New("commit result unknown")
+
+// CommitUnknownError wraps the error returned by a commit whose outcome is unknown.
+// It matches [ErrCommitUnknown] with errors.Is and unwraps to the original commit error.
+type CommitUnknownError struct {
+	Err error
+}
+
+// Error implements the error interface.
+func (e *CommitUnknownError) Error() string {
+	return ErrCommitUnknown.Error() + ": " + e.Err.Error()
+}
+
+// Unwrap returns the original commit error.
+func (e *CommitUnknownError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is [ErrCommitUnknown].
+func (e *CommitUnknownError) Is(target error) bool {
+	return target == ErrCommitUnknown //nolint:errorlint // sentinel comparison
+}
+
+// ClassifyCommitError wraps err in a [CommitUnknownError] if it was returned by a commit and
+// indicates that the connection to the remote broke while committing.
+// Errors which definitely leave the transaction uncommitted, like a cancelled context,
+// sql.ErrTxDone or a [TimeoutError], are returned unchanged.
+func ClassifyCommitError(err error) error {
+	if err == nil || !isAmbiguousCommitError(err) {
+		return err
+	}
+
+	return &CommitUnknownError{Err: err}
+}
+
+func isAmbiguousCommitError(err error) bool {
+	switch {
+	case errors.Is(err, ErrCommitUnknown),
+		errors.Is(err, ErrTimeout),
+		errors.Is(err, sql.ErrTxDone),
+		errors.Is(err, context.Canceled),
+		errors.Is(err, context.DeadlineExceeded):
+		return false
+	}
+
+	return IsConnectionError(err)
+}
+
+// IsConnectionError reports whether err indicates that the connection to the remote broke, eg
+// driver.ErrBadConn, a closed or reset network connection or a network timeout.
+func IsConnectionError(err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, driver.ErrBadConn),
+		errors.Is(err, net.ErrClosed),
+		errors.Is(err, io.EOF),
+		errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, os.ErrDeadlineExceeded),
+		errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, syscall.EPIPE):
+		return true
+	}
+
+	var netErr net.Error
+
+	return errors.As(err, &netErr)
+}
@@ -0,0 +1,75 @@
+package atomic_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/beeemT/go-atomic"
+)
+
+func TestClassifyCommitError(t *testing.T) {
+	unknown := &atomic.CommitUnknownError{Err: driver.ErrBadConn}
+
+	for _, tc := range []struct {
+		name    string
+		err     error
+		unknown bool
+	}{
+		{name: "nil"},
+		{name: "canceled", err: context.Canceled},
+		{name: "deadline exceeded", err: fmt.Errorf("committing: %w", context.DeadlineExceeded)},
+		{
+			name: "timeout",
+			err:  &atomic.TimeoutError{Kind: atomic.StatementTimeout, Err: io.EOF},
+		},
+		{name: "tx done", err: sql.ErrTxDone},
+		{name: "other", err: errors.New("serialization failure")},
+		{name: "bad conn", err: driver.ErrBadConn, unknown: true},
+		{name: "eof", err: io.EOF, unknown: true},
+		{name: "unexpected eof", err: io.ErrUnexpectedEOF, unknown: true},
+		{name: "closed", err: net.ErrClosed, unknown: true},
+		{name: "reset", err: fmt.Errorf("read: %w", syscall.ECONNRESET), unknown: true},
+		{name: "broken pipe", err: syscall.EPIPE, unknown: true},
+		{name: "io timeout", err: os.ErrDeadlineExceeded, unknown: true},
+		{
+			name:    "net error",
+			err:     &net.OpError{Op: "read", Net: "tcp", Err: errors.New("no route")},
+			unknown: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := atomic.ClassifyCommitError(tc.err)
+
+			if !tc.unknown {
+				if err != tc.err { //nolint:errorlint // the error has to be returned unchanged
+					t.Fatalf("expected %v to be returned unchanged, got %v", tc.err, err)
+				}
+
+				return
+			}
+
+			var classified *atomic.CommitUnknownError
+
+			//nolint:errorlint // the original error has to be wrapped as is
+			if !errors.As(err, &classified) || classified.Err != tc.err {
+				t.Fatalf("expected CommitUnknownError wrapping %v, got %v", tc.err, err)
+			}
+
+			if !errors.Is(err, atomic.ErrCommitUnknown) || !errors.Is(err, tc.err) {
+				t.Fatalf("expected %v to match ErrCommitUnknown and %v", err, tc.err)
+			}
+		})
+	}
+
+	if err := atomic.ClassifyCommitError(unknown); err != unknown { //nolint:errorlint // same error
+		t.Fatalf("expected CommitUnknownError not to be wrapped again, got %v", err)
+	}
+}
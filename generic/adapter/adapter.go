@@ -0,0 +1,241 @@
+// Package adapter provides a minimal statement interface on top of the remotes supported by
+// go-atomic. It is used by the helper packages (eg outbox) to run their own statements on whichever
+// remote the transacter of the user is built on.
+package adapter
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"strings"
+
+	"github.com/beeemT/go-atomic/generic"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+type (
+	// Dialect is the SQL dialect spoken by the database behind a remote.
+	Dialect int
+
+	// Conn is a minimal statement interface implemented by the adapters of this package.
+	// Queries passed to Conn always use '?' as placeholder, the adapters rebind them to the
+	// placeholder format of the dialect.
+	Conn interface {
+		// Exec executes query and returns the number of affected rows.
+		Exec(ctx context.Context, query string, args ...any) (int64, error)
+		// Query executes query and returns the resulting rows.
+		Query(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+		// Dialect returns the dialect of the database behind the connection.
+		Dialect() Dialect
+	}
+
+	// SQLConn implements [Conn] for [generic.SQLRemote].
+	SQLConn struct {
+		remote  generic.SQLRemote
+		dialect Dialect
+	}
+
+	// SQLXConn implements [Conn] for [generic.SQLXRemote].
+	SQLXConn struct {
+		remote  generic.SQLXRemote
+		dialect Dialect
+	}
+
+	// GormConn implements [Conn] for [generic.GormRemote].
+	GormConn struct {
+		remote  generic.GormRemote
+		dialect Dialect
+	}
+)
+
+const (
+	// Postgres is the dialect of PostgreSQL and compatible databases like CockroachDB.
+	Postgres Dialect = iota + 1
+	// MySQL is the dialect of MySQL and MariaDB.
+	MySQL
+	// SQLite is the dialect of SQLite.
+	SQLite
+)
+
+var (
+	_ Conn = SQLConn{}
+	_ Conn = SQLXConn{}
+	_ Conn = GormConn{}
+)
+
+// String returns the name of the dialect.
+func (d Dialect) String() string {
+	switch d {
+	case Postgres:
+		return "postgres"
+	case MySQL:
+		return "mysql"
+	case SQLite:
+		return "sqlite"
+	}
+
+	return "unknown"
+}
+
+// DriverDialect infers the dialect of the databases of drv from the package of the driver.
+// It recognizes lib/pq, pgx, go-sql-driver/mysql, mattn/go-sqlite3 and modernc.org/sqlite and
+// returns 0 for other drivers.
+func DriverDialect(drv driver.Driver) Dialect {
+	if drv == nil {
+		return 0
+	}
+
+	typ := reflect.TypeOf(drv)
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	pkg := typ.PkgPath()
+
+	switch {
+	case strings.HasPrefix(pkg, "github.com/lib/pq"),
+		strings.HasPrefix(pkg, "github.com/jackc/pgx"):
+		return Postgres
+	case strings.HasPrefix(pkg, "github.com/go-sql-driver/mysql"):
+		return MySQL
+	case strings.HasPrefix(pkg, "github.com/mattn/go-sqlite3"),
+		strings.HasPrefix(pkg, "modernc.org/sqlite"):
+		return SQLite
+	}
+
+	return 0
+}
+
+// Rebind rewrites the '?' placeholders in query to the placeholder format of the dialect.
+func (d Dialect) Rebind(query string) string {
+	if d == Postgres {
+		return sqlx.Rebind(sqlx.DOLLAR, query)
+	}
+
+	return query
+}
+
+// SQL creates a new [Conn] for remote.
+func SQL(remote generic.SQLRemote, dialect Dialect) SQLConn {
+	return SQLConn{
+		remote:  remote,
+		dialect: dialect,
+	}
+}
+
+// Exec implements [Conn].
+func (c SQLConn) Exec(ctx context.Context, query string, args ...any) (int64, error) {
+	result, err := c.remote.ExecContext(ctx, c.dialect.Rebind(query), args...)
+	if err != nil {
+		return 0, errors.Wrap(err, "executing statement")
+	}
+
+	return rowsAffected(result)
+}
+
+// Query implements [Conn].
+func (c SQLConn) Query(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	rows, err := c.remote.QueryContext(ctx, c.dialect.Rebind(query), args...)
+
+	return rows, errors.Wrap(err, "executing query")
+}
+
+// Dialect implements [Conn].
+func (c SQLConn) Dialect() Dialect {
+	return c.dialect
+}
+
+// SQLX creates a new [Conn] for remote.
+// Placeholders are rebound using the Rebind method of remote, ie based on its driver name.
+func SQLX(remote generic.SQLXRemote, dialect Dialect) SQLXConn {
+	return SQLXConn{
+		remote:  remote,
+		dialect: dialect,
+	}
+}
+
+// Exec implements [Conn].
+// [generic.SQLXRemote] does not offer an ExecContext method returning errors, so the statement is
+// executed through the ExecContext method of the underlying sqlx.Tx / sqlx.DB if present and else
+// through a prepared statement.
+func (c SQLXConn) Exec(ctx context.Context, query string, args ...any) (int64, error) {
+	query = c.remote.Rebind(query)
+
+	if execer, ok := c.remote.(interface {
+		ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	}); ok {
+		result, err := execer.ExecContext(ctx, query, args...)
+		if err != nil {
+			return 0, errors.Wrap(err, "executing statement")
+		}
+
+		return rowsAffected(result)
+	}
+
+	stmt, err := c.remote.PreparexContext(ctx, query)
+	if err != nil {
+		return 0, errors.Wrap(err, "preparing statement")
+	}
+	defer stmt.Close()
+
+	result, err := stmt.ExecContext(ctx, args...)
+	if err != nil {
+		return 0, errors.Wrap(err, "executing statement")
+	}
+
+	return rowsAffected(result)
+}
+
+// Query implements [Conn].
+func (c SQLXConn) Query(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	rows, err := c.remote.QueryxContext(ctx, c.remote.Rebind(query), args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "executing query")
+	}
+
+	return rows.Rows, nil
+}
+
+// Dialect implements [Conn].
+func (c SQLXConn) Dialect() Dialect {
+	return c.dialect
+}
+
+// Gorm creates a new [Conn] for remote.
+// Placeholders are rebound by gorm.
+func Gorm(remote generic.GormRemote, dialect Dialect) GormConn {
+	return GormConn{
+		remote:  remote,
+		dialect: dialect,
+	}
+}
+
+// Exec implements [Conn].
+func (c GormConn) Exec(ctx context.Context, query string, args ...any) (int64, error) {
+	db := c.remote.WithContext(ctx).Exec(query, args...)
+	if db.Error != nil {
+		return 0, errors.Wrap(db.Error, "executing statement")
+	}
+
+	return db.RowsAffected, nil
+}
+
+// Query implements [Conn].
+func (c GormConn) Query(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	rows, err := c.remote.WithContext(ctx).Raw(query, args...).Rows()
+
+	return rows, errors.Wrap(err, "executing query")
+}
+
+// Dialect implements [Conn].
+func (c GormConn) Dialect() Dialect {
+	return c.dialect
+}
+
+func rowsAffected(result sql.Result) (int64, error) {
+	affected, err := result.RowsAffected()
+
+	return affected, errors.Wrap(err, "reading affected rows")
+}
@@ -0,0 +1,318 @@
+package generic
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/beeemT/go-atomic/internal/depsort"
+	"github.com/pkg/errors"
+)
+
+const (
+	autoContext autoArgumentKind = iota + 1
+	autoRemote
+	autoTransacter
+	autoDependency
+)
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+type (
+	autoArgumentKind int
+
+	// autoConstructor is a constructor passed to [AutoResources].
+	autoConstructor struct {
+		fn     reflect.Value
+		result reflect.Type
+		fails  bool
+		args   []autoArgument
+	}
+
+	// autoArgument is an argument passed to an autoConstructor.
+	autoArgument struct {
+		kind autoArgumentKind
+		// dependency is the type of the value passed for arguments of kind autoDependency.
+		dependency reflect.Type
+	}
+)
+
+// AutoResources builds a createResources function for [NewTransacter] from constructors, as an
+// alternative to the code generated by atomicgen.
+//
+// Every constructor is a function returning a value and optionally an error. Its parameters are
+// matched by type: a context.Context receives the context of the session, the Remote (or an
+// interface implemented by it) receives the transaction, a *Transacter[Remote, Resources] receives
+// the transacter and every other parameter receives the value created by the constructor of its
+// type. The values are assigned to the fields of Resources, which has to be a struct, with the
+// type of the values. A field with an interface type for which no constructor exists receives the
+// value of the only constructor creating a type which implements the interface.
+//
+// The constructors are validated once when AutoResources is called. It fails if a field has no
+// constructor or several implementing ones, a type has several constructors, a parameter cannot
+// be provided, a constructor creates a type which is neither a field nor a dependency, or the
+// dependencies are cyclic.
+func AutoResources[Remote any, Resources any](constructors ...any) (
+	func(
+		ctx context.Context,
+		transacter *Transacter[Remote, Resources],
+		tx Remote,
+	) (Resources, error),
+	error,
+) {
+	resourcesType := reflect.TypeOf((*Resources)(nil)).Elem()
+	if resourcesType.Kind() != reflect.Struct {
+		return nil, errors.Errorf("resources type %s is not a struct", resourcesType)
+	}
+
+	all, err := autoConstructors[Remote, Resources](constructors)
+	if err != nil {
+		return nil, err
+	}
+
+	fieldConstructors, err := autoFields(resourcesType, all)
+	if err != nil {
+		return nil, err
+	}
+
+	ordered, err := autoOrder(all)
+	if err != nil {
+		return nil, err
+	}
+
+	createResources := func(
+		ctx context.Context,
+		transacter *Transacter[Remote, Resources],
+		tx Remote,
+	) (Resources, error) {
+		var resources Resources
+
+		values := make(map[reflect.Type]reflect.Value, len(ordered))
+
+		for _, constructor := range ordered {
+			args := make([]reflect.Value, 0, len(constructor.args))
+
+			for i, arg := range constructor.args {
+				var value reflect.Value
+
+				switch arg.kind {
+				case autoContext:
+					value = reflect.ValueOf(&ctx).Elem()
+				case autoRemote:
+					value = reflect.ValueOf(&tx).Elem()
+				case autoTransacter:
+					value = reflect.ValueOf(transacter)
+				case autoDependency:
+					value = values[arg.dependency]
+				}
+
+				args = append(args, value.Convert(constructor.fn.Type().In(i)))
+			}
+
+			results := constructor.fn.Call(args)
+			if constructor.fails && !results[1].IsNil() {
+				err, _ := results[1].Interface().(error)
+
+				return resources, fmt.Errorf("creating %s: %w", constructor.result, err)
+			}
+
+			values[constructor.result] = results[0]
+		}
+
+		fields := reflect.ValueOf(&resources).Elem()
+
+		for i := 0; i < fields.NumField(); i++ {
+			fields.Field(i).Set(values[fieldConstructors[i].result])
+		}
+
+		return resources, nil
+	}
+
+	return createResources, nil
+}
+
+// autoConstructors validates constructors and returns them in the passed order.
+func autoConstructors[Remote any, Resources any](constructors []any) ([]*autoConstructor, error) {
+	remoteType := reflect.TypeOf((*Remote)(nil)).Elem()
+	transacterType := reflect.TypeOf((*Transacter[Remote, Resources])(nil))
+
+	all := make([]*autoConstructor, 0, len(constructors))
+	byType := make(map[reflect.Type]*autoConstructor, len(constructors))
+
+	for _, c := range constructors {
+		fn := reflect.ValueOf(c)
+		if fn.Kind() != reflect.Func || fn.IsNil() {
+			return nil, errors.Errorf("constructor %T is not a function", c)
+		}
+
+		fnType := fn.Type()
+
+		switch {
+		case fnType.IsVariadic():
+			return nil, errors.Errorf("constructor %s is variadic", fnType)
+		case fnType.NumOut() == 1,
+			fnType.NumOut() == 2 && fnType.Out(1) == errorType:
+		default:
+			return nil, errors.Errorf(
+				"constructor %s does not return a value and optionally an error",
+				fnType,
+			)
+		}
+
+		constructor := &autoConstructor{
+			fn:     fn,
+			result: fnType.Out(0),
+			fails:  fnType.NumOut() == 2,
+		}
+
+		if _, ok := byType[constructor.result]; ok {
+			return nil, errors.Errorf("several constructors of %s", constructor.result)
+		}
+
+		all = append(all, constructor)
+		byType[constructor.result] = constructor
+	}
+
+	for _, constructor := range all {
+		fnType := constructor.fn.Type()
+
+		for i := 0; i < fnType.NumIn(); i++ {
+			param := fnType.In(i)
+
+			switch {
+			case param == contextType:
+				constructor.args = append(constructor.args, autoArgument{kind: autoContext})
+			case param == remoteType:
+				constructor.args = append(constructor.args, autoArgument{kind: autoRemote})
+			case param == transacterType:
+				constructor.args = append(constructor.args, autoArgument{kind: autoTransacter})
+			case byType[param] != nil:
+				constructor.args = append(
+					constructor.args,
+					autoArgument{kind: autoDependency, dependency: param},
+				)
+			case param.Kind() == reflect.Interface && param.NumMethod() > 0 &&
+				remoteType.Implements(param):
+				// an interface satisfied by the Remote, eg a subset of its methods
+				constructor.args = append(constructor.args, autoArgument{kind: autoRemote})
+			default:
+				return nil, errors.Errorf(
+					"no constructor of %s, parameter %d of constructor %s",
+					param, i, fnType,
+				)
+			}
+		}
+	}
+
+	return all, nil
+}
+
+// autoFields returns the constructor creating the value of every field of resourcesType and
+// checks that every constructor is needed.
+func autoFields(resourcesType reflect.Type, all []*autoConstructor) ([]*autoConstructor, error) {
+	fields := make([]*autoConstructor, 0, resourcesType.NumField())
+	used := make(map[reflect.Type]bool, len(all))
+
+	for i := 0; i < resourcesType.NumField(); i++ {
+		field := resourcesType.Field(i)
+		if !field.IsExported() {
+			return nil, errors.Errorf("field %s of %s is not exported", field.Name, resourcesType)
+		}
+
+		constructor, err := autoFieldConstructor(field, all)
+		if err != nil {
+			return nil, errors.Wrapf(err, "field %s of %s", field.Name, resourcesType)
+		}
+
+		fields = append(fields, constructor)
+		used[constructor.result] = true
+	}
+
+	for _, constructor := range all {
+		for _, arg := range constructor.args {
+			if arg.kind == autoDependency {
+				used[arg.dependency] = true
+			}
+		}
+	}
+
+	for _, constructor := range all {
+		if !used[constructor.result] {
+			return nil, errors.Errorf(
+				"constructor %s creates %s, which is neither a field of %s nor a dependency",
+				constructor.fn.Type(), constructor.result, resourcesType,
+			)
+		}
+	}
+
+	return fields, nil
+}
+
+// autoFieldConstructor returns the constructor of the type of field, or the only constructor of a
+// type implementing field if it has an interface type.
+func autoFieldConstructor(
+	field reflect.StructField,
+	all []*autoConstructor,
+) (*autoConstructor, error) {
+	var implementing []*autoConstructor
+
+	for _, constructor := range all {
+		switch {
+		case constructor.result == field.Type:
+			return constructor, nil
+		case field.Type.Kind() == reflect.Interface &&
+			constructor.result.Implements(field.Type):
+			implementing = append(implementing, constructor)
+		}
+	}
+
+	switch len(implementing) {
+	case 0:
+		return nil, errors.Errorf("no constructor of %s", field.Type)
+	case 1:
+		return implementing[0], nil
+	}
+
+	results := make([]string, 0, len(implementing))
+
+	for _, constructor := range implementing {
+		results = append(results, constructor.result.String())
+	}
+
+	return nil, errors.Errorf(
+		"several constructors of types implementing %s: %s",
+		field.Type, strings.Join(results, ", "),
+	)
+}
+
+// autoOrder orders the constructors so that every constructor follows its dependencies.
+func autoOrder(all []*autoConstructor) ([]*autoConstructor, error) {
+	byType := make(map[reflect.Type]*autoConstructor, len(all))
+
+	for _, constructor := range all {
+		byType[constructor.result] = constructor
+	}
+
+	//nolint:wrapcheck // the cycle is described by the error
+	return depsort.Sort(
+		all,
+		func(constructor *autoConstructor) []*autoConstructor {
+			var dependencies []*autoConstructor
+
+			for _, arg := range constructor.args {
+				if arg.kind == autoDependency {
+					dependencies = append(dependencies, byType[arg.dependency])
+				}
+			}
+
+			return dependencies
+		},
+		func(constructor *autoConstructor) string {
+			return constructor.result.String()
+		},
+	)
+}
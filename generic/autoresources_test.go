@@ -0,0 +1,245 @@
+package generic_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/beeemT/go-atomic/atomictest"
+	"github.com/beeemT/go-atomic/generic"
+)
+
+type (
+	remote struct {
+		name string
+	}
+
+	namer interface {
+		Name() string
+	}
+
+	userRepo struct {
+		remote string
+	}
+
+	orderRepo struct {
+		users userRepo
+		ctx   context.Context //nolint:containedctx // checks the passed context
+	}
+
+	clock struct {
+		transacter *generic.Transacter[remote, resources]
+	}
+
+	store interface {
+		Store() string
+	}
+
+	resources struct {
+		Orders orderRepo
+		Users  userRepo
+		Clock  clock
+		Store  store
+	}
+)
+
+type ctxKey struct{}
+
+var errConstructor = errors.New("constructor failed")
+
+func (r remote) Name() string {
+	return r.name
+}
+
+func (r userRepo) Store() string {
+	return "users"
+}
+
+func newUserRepo(tx namer) userRepo {
+	return userRepo{remote: tx.Name()}
+}
+
+func newOrderRepo(ctx context.Context, users userRepo) (orderRepo, error) {
+	return orderRepo{users: users, ctx: ctx}, nil
+}
+
+func newClock(transacter *generic.Transacter[remote, resources]) clock {
+	return clock{transacter: transacter}
+}
+
+func TestAutoResources(t *testing.T) {
+	createResources, err := generic.AutoResources[remote, resources](
+		newOrderRepo, newClock, newUserRepo,
+	)
+	if err != nil {
+		t.Fatalf("building createResources: %v", err)
+	}
+
+	transacter := generic.NewTransacter[remote, resources](
+		atomictest.NewExecuter(remote{name: "tx"}),
+		createResources,
+	)
+	ctx := context.WithValue(context.Background(), ctxKey{}, "value")
+
+	err = transacter.Transact(ctx, func(_ context.Context, r resources) error {
+		switch {
+		case r.Users.remote != "tx":
+			t.Errorf("expected user repo on the remote, got %+v", r.Users)
+		case r.Orders.users != r.Users:
+			t.Errorf("expected order repo to depend on user repo, got %+v", r.Orders)
+		case r.Orders.ctx.Value(ctxKey{}) != "value":
+			t.Error("expected order repo to receive the context of the session")
+		case r.Clock.transacter == nil:
+			t.Error("expected clock to receive the transacter")
+		case r.Store != r.Users:
+			t.Errorf("expected interface field to receive the user repo, got %+v", r.Store)
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("transacting: %v", err)
+	}
+}
+
+func TestAutoResourcesConstructorError(t *testing.T) {
+	createResources, err := generic.AutoResources[remote, resources](
+		func(userRepo) (orderRepo, error) {
+			return orderRepo{}, errConstructor
+		},
+		newClock,
+		newUserRepo,
+	)
+	if err != nil {
+		t.Fatalf("building createResources: %v", err)
+	}
+
+	_, err = createResources(context.Background(), nil, remote{})
+	if !errors.Is(err, errConstructor) {
+		t.Fatalf("expected constructor error in chain, got %v", err)
+	}
+}
+
+func TestAutoResourcesInvalid(t *testing.T) {
+	type (
+		a struct{}
+		b struct{}
+	)
+
+	type cyclic struct {
+		A a
+		B b
+	}
+
+	tests := []struct {
+		name         string
+		constructors []any
+		build        func(constructors []any) error
+		err          string
+	}{
+		{
+			name:         "missing constructor",
+			constructors: []any{newUserRepo},
+			build: func(constructors []any) error {
+				_, err := generic.AutoResources[remote, struct {
+					Users userRepo
+					Extra a
+				}](constructors...)
+
+				return err
+			},
+			err: "field Extra of struct { Users generic_test.userRepo; Extra generic_test.a }: " +
+				"no constructor of generic_test.a",
+		},
+		{
+			name: "several constructors",
+			constructors: []any{
+				newOrderRepo, newClock, newUserRepo,
+				func(remote) userRepo { return userRepo{} },
+			},
+			err: "several constructors of generic_test.userRepo",
+		},
+		{
+			name: "unknown parameter",
+			constructors: []any{
+				func(remote, a) (orderRepo, error) { return orderRepo{}, nil },
+				newClock, newUserRepo,
+			},
+			err: "no constructor of generic_test.a",
+		},
+		{
+			name: "unused constructor",
+			constructors: []any{
+				newOrderRepo, newClock, newUserRepo, func(remote) a { return a{} },
+			},
+			err: "neither a field",
+		},
+		{
+			name: "ambiguous interface",
+			constructors: []any{
+				newOrderRepo, newClock, newUserRepo,
+				func(remote) struct{ userRepo } { return struct{ userRepo }{} },
+			},
+			err: "several constructors of types implementing generic_test.store",
+		},
+		{
+			name:         "not a function",
+			constructors: []any{newOrderRepo, newClock, userRepo{}},
+			err:          "is not a function",
+		},
+		{
+			name: "cycle",
+			constructors: []any{
+				func(remote, b) a { return a{} },
+				func(remote, a) b { return b{} },
+			},
+			build: func(constructors []any) error {
+				_, err := generic.AutoResources[remote, cyclic](constructors...)
+
+				return err
+			},
+			err: "dependency cycle generic_test.a -> generic_test.b -> generic_test.a",
+		},
+		{
+			name:         "not a struct",
+			constructors: []any{newUserRepo},
+			build: func(constructors []any) error {
+				_, err := generic.AutoResources[remote, store](constructors...)
+
+				return err
+			},
+			err: "is not a struct",
+		},
+		{
+			name:         "unexported field",
+			constructors: []any{newUserRepo},
+			build: func(constructors []any) error {
+				_, err := generic.AutoResources[remote, struct{ users userRepo }](
+					constructors...,
+				)
+
+				return err
+			},
+			err: "not exported",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			build := test.build
+			if build == nil {
+				build = func(constructors []any) error {
+					_, err := generic.AutoResources[remote, resources](constructors...)
+
+					return err
+				}
+			}
+
+			err := build(test.constructors)
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("expected error containing %q, got %v", test.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,257 @@
+// Package chaos implements a [generic.Executer] wrapper injecting failures and latency into
+// transactions, to validate retry and idempotency logic.
+//
+// Faults are configured by the [Rule]s of a [Policy]. Rules apply probabilistically, drawn from a
+// random source seeded by the policy so that runs are reproducible, or deterministically to the
+// nth occurrence of their [Point].
+package chaos
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/beeemT/go-atomic"
+	"github.com/beeemT/go-atomic/generic"
+	"github.com/pkg/errors"
+)
+
+var _ generic.Executer[struct{}] = (*Executer[struct{}])(nil)
+
+// ErrInjected is the error injected by rules without Err.
+var ErrInjected = errors.New("injected fault")
+
+const (
+	// Begin is the opening of a transaction. Failures are returned without calling the wrapped
+	// executer.
+	Begin Point = iota + 1
+	// Statement is a statement run on the Remote. It requires the wrap function of the executer.
+	// Statements of methods without error result are only delayed, see
+	// [generic.Statement.Fallible].
+	Statement
+	// Commit is the commit of a transaction. Failures roll back the transaction.
+	Commit
+	// CommitAck is the acknowledgement of a successful commit. Failures are returned as
+	// [atomic.CommitUnknownError] although the transaction was committed.
+	CommitAck
+)
+
+type (
+	// Point is a point of a transaction at which faults are injected.
+	Point int
+
+	// Rule injects a fault at occurrences of a point.
+	Rule struct {
+		// Point is the point the rule applies to.
+		Point Point
+		// Probability is the probability of the rule applying to an occurrence of the point.
+		Probability float64
+		// Nth applies the rule to the nth occurrence of the point only, counted from 1 over the
+		// lifetime of the executer. Probability is ignored if Nth is set.
+		Nth int
+		// Latency delays the occurrence.
+		Latency time.Duration
+		// Err is injected at the occurrence. A nil Err injects [ErrInjected].
+		Err error
+		// LatencyOnly injects only Latency and no error.
+		LatencyOnly bool
+	}
+
+	// Policy configures the faults injected by an [Executer].
+	Policy struct {
+		// Seed seeds the random source of probabilistic rules.
+		Seed int64
+		// Rules are the rules of the policy. All rules applying to an occurrence inject their
+		// latency, the first one with an error fails it.
+		Rules []Rule
+	}
+
+	// Executer wraps a [generic.Executer] and injects the faults of a [Policy].
+	// Executer is safe for concurrent use.
+	Executer[Remote any] struct {
+		executer generic.Executer[Remote]
+		policy   Policy
+		wrap     func(Remote, generic.StatementHook) Remote
+		clock    atomic.Clock
+
+		mu          sync.Mutex
+		random      *rand.Rand
+		occurrences map[Point]int
+		injected    map[Point]int
+	}
+
+	// ExecuterOption configures the [Executer] instance.
+	ExecuterOption[Remote any] func(*Executer[Remote])
+)
+
+// String returns the name of the point.
+func (p Point) String() string {
+	switch p {
+	case Begin:
+		return "begin"
+	case Statement:
+		return "statement"
+	case Commit:
+		return "commit"
+	case CommitAck:
+		return "commit ack"
+	default:
+		return "unknown"
+	}
+}
+
+// WithStatementHook sets the function wrapping the Remote passed to executed functions with a
+// statement hook, eg [generic.HookSQLRemote]. Without it no faults are injected at [Statement].
+func WithStatementHook[Remote any](
+	wrap func(Remote, generic.StatementHook) Remote,
+) ExecuterOption[Remote] {
+	return func(e *Executer[Remote]) {
+		e.wrap = wrap
+	}
+}
+
+// WithClock sets the clock used for waiting for the latency of rules, eg a fake clock in tests.
+func WithClock[Remote any](clock atomic.Clock) ExecuterOption[Remote] {
+	return func(e *Executer[Remote]) {
+		e.clock = clock
+	}
+}
+
+// NewExecuter creates a new Executer injecting the faults of policy into executer.
+//
+// By default:
+//   - uses [atomic.SystemClock] as clock.
+func NewExecuter[Remote any](
+	executer generic.Executer[Remote],
+	policy Policy,
+	opts ...ExecuterOption[Remote],
+) *Executer[Remote] {
+	e := &Executer[Remote]{
+		executer:    executer,
+		policy:      policy,
+		clock:       atomic.SystemClock{},
+		random:      rand.New(rand.NewSource(policy.Seed)), //nolint:gosec // reproducible faults
+		occurrences: map[Point]int{},
+		injected:    map[Point]int{},
+	}
+
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	return e
+}
+
+// Execute executes run with the wrapped executer and injects the faults of the policy.
+func (e *Executer[Remote]) Execute(ctx context.Context, run func(Remote) error) error {
+	err := e.inject(ctx, Begin, true)
+	if err != nil {
+		return errors.Wrap(err, "beginning transaction")
+	}
+
+	var (
+		committing bool
+		ackErr     error
+	)
+
+	err = e.executer.Execute(ctx, func(remote Remote) error {
+		if e.wrap != nil {
+			remote = e.wrap(remote, func(ctx context.Context, statement generic.Statement) error {
+				return e.inject(ctx, Statement, statement.Fallible())
+			})
+		}
+
+		err := run(remote)
+		if err != nil {
+			return err
+		}
+
+		committing = true
+
+		err = e.inject(ctx, Commit, true)
+		if err != nil {
+			return errors.Wrap(err, "committing transaction")
+		}
+
+		ackErr = e.inject(ctx, CommitAck, true)
+
+		return nil
+	})
+	if err != nil || !committing || ackErr == nil {
+		return err //nolint:wrapcheck // errors of the wrapped executer are passed through
+	}
+
+	return &atomic.CommitUnknownError{Err: ackErr}
+}
+
+// Injected returns how often an error was injected at point.
+func (e *Executer[Remote]) Injected(point Point) int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.injected[point]
+}
+
+// Occurrences returns how often point occurred.
+func (e *Executer[Remote]) Occurrences(point Point) int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.occurrences[point]
+}
+
+// inject applies the rules of point to its next occurrence. It sleeps for the latency of the
+// applying rules and returns the error of the first applying rule with one if fallible is set.
+func (e *Executer[Remote]) inject(ctx context.Context, point Point, fallible bool) error {
+	latency, err := e.apply(point, fallible)
+
+	if latency > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err() //nolint:wrapcheck // context errors are passed through
+		case <-e.clock.After(latency):
+		}
+	}
+
+	return err
+}
+
+func (e *Executer[Remote]) apply(point Point, fallible bool) (time.Duration, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.occurrences[point]++
+	occurrence := e.occurrences[point]
+
+	var (
+		latency time.Duration
+		err     error
+	)
+
+	for _, rule := range e.policy.Rules {
+		if rule.Point != point {
+			continue
+		}
+
+		switch {
+		case rule.Nth > 0 && rule.Nth != occurrence:
+			continue
+		case rule.Nth <= 0 && e.random.Float64() >= rule.Probability:
+			continue
+		}
+
+		latency += rule.Latency
+
+		if err == nil && fallible && !rule.LatencyOnly {
+			err = rule.Err
+			if err == nil {
+				err = ErrInjected
+			}
+
+			e.injected[point]++
+		}
+	}
+
+	return latency, err
+}
@@ -0,0 +1,213 @@
+package chaos_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/beeemT/go-atomic"
+	"github.com/beeemT/go-atomic/atomictest"
+	"github.com/beeemT/go-atomic/generic"
+	"github.com/beeemT/go-atomic/generic/chaos"
+	gsql "github.com/beeemT/go-atomic/generic/sql"
+	"github.com/beeemT/go-atomic/internal/sqlitetest"
+)
+
+func TestLatencyUsesClock(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	clock := atomictest.NewClock(time.Unix(0, 0))
+	executer := chaos.NewExecuter[struct{}](
+		atomictest.NewExecuter(struct{}{}),
+		chaos.Policy{Rules: []chaos.Rule{{
+			Point:       chaos.Begin,
+			Nth:         1,
+			Latency:     time.Hour,
+			LatencyOnly: true,
+		}}},
+		chaos.WithClock[struct{}](clock),
+	)
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- executer.Execute(ctx, func(struct{}) error {
+			return nil
+		})
+	}()
+
+	err := clock.BlockUntil(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err = <-done:
+		t.Fatalf("expected execution to wait for the latency, got %v", err)
+	default:
+	}
+
+	clock.Advance(time.Hour)
+
+	select {
+	case err = <-done:
+		if err != nil {
+			t.Fatalf("executing: %v", err)
+		}
+	case <-ctx.Done():
+		t.Fatal("expected execution to continue after the latency elapsed")
+	}
+}
+
+func TestBeginFault(t *testing.T) {
+	wrapped := atomictest.NewExecuter(struct{}{})
+	executer := chaos.NewExecuter[struct{}](
+		wrapped,
+		chaos.Policy{Rules: []chaos.Rule{{Point: chaos.Begin, Nth: 1}}},
+	)
+
+	err := executer.Execute(context.Background(), func(struct{}) error {
+		t.Fatal("expected run not to be called")
+
+		return nil
+	})
+	if !errors.Is(err, chaos.ErrInjected) {
+		t.Fatalf("expected ErrInjected, got %v", err)
+	}
+
+	if executions := wrapped.Executions(); len(executions) != 0 {
+		t.Fatalf("expected wrapped executer not to be called, got %v", executions)
+	}
+}
+
+func TestStatementFault(t *testing.T) {
+	errStatement := errors.New("statement failed")
+	db := sqlitetest.Open(t, "CREATE TABLE markers (value TEXT NOT NULL)")
+	executer := chaos.NewExecuter[generic.SQLRemote](
+		gsql.NewExecuter(db),
+		chaos.Policy{Rules: []chaos.Rule{{Point: chaos.Statement, Nth: 2, Err: errStatement}}},
+		chaos.WithStatementHook(generic.HookSQLRemote),
+	)
+
+	err := executer.Execute(context.Background(), func(tx generic.SQLRemote) error {
+		for _, value := range []string{"first", "second"} {
+			_, err := tx.ExecContext(
+				context.Background(),
+				"INSERT INTO markers (value) VALUES (?)",
+				value,
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if !errors.Is(err, errStatement) {
+		t.Fatalf("expected statement error, got %v", err)
+	}
+
+	if occurrences, injected := executer.Occurrences(chaos.Statement),
+		executer.Injected(chaos.Statement); occurrences != 2 || injected != 1 {
+		t.Fatalf("expected 1 of 2 statements to fail, got %d of %d", injected, occurrences)
+	}
+
+	var n int
+
+	err = db.QueryRow("SELECT COUNT(*) FROM markers").Scan(&n)
+	if err != nil {
+		t.Fatalf("counting markers: %v", err)
+	}
+
+	if n != 0 {
+		t.Fatalf("expected transaction to be rolled back, got %d rows", n)
+	}
+}
+
+func TestCommitFault(t *testing.T) {
+	wrapped := atomictest.NewExecuter(struct{}{})
+	executer := chaos.NewExecuter[struct{}](
+		wrapped,
+		chaos.Policy{Rules: []chaos.Rule{{Point: chaos.Commit, Nth: 1}}},
+	)
+
+	err := executer.Execute(context.Background(), func(struct{}) error {
+		return nil
+	})
+	if !errors.Is(err, chaos.ErrInjected) || errors.Is(err, atomic.ErrCommitUnknown) {
+		t.Fatalf("expected ErrInjected, got %v", err)
+	}
+
+	if wrapped.Commits() != 0 || wrapped.Rollbacks() != 1 {
+		t.Fatalf(
+			"expected transaction to be rolled back, got %d commits and %d rollbacks",
+			wrapped.Commits(), wrapped.Rollbacks(),
+		)
+	}
+}
+
+func TestCommitAckFault(t *testing.T) {
+	wrapped := atomictest.NewExecuter(struct{}{})
+	executer := chaos.NewExecuter[struct{}](
+		wrapped,
+		chaos.Policy{Rules: []chaos.Rule{{Point: chaos.CommitAck, Nth: 1}}},
+	)
+
+	err := executer.Execute(context.Background(), func(struct{}) error {
+		return nil
+	})
+	if !errors.Is(err, atomic.ErrCommitUnknown) || !errors.Is(err, chaos.ErrInjected) {
+		t.Fatalf("expected CommitUnknownError wrapping ErrInjected, got %v", err)
+	}
+
+	if wrapped.Commits() != 1 {
+		t.Fatalf("expected transaction to be committed, got %d commits", wrapped.Commits())
+	}
+}
+
+// failures returns which of n executions with a begin fault of probability 0.5 failed.
+func failures(t *testing.T, seed int64, n int) []bool {
+	t.Helper()
+
+	executer := chaos.NewExecuter[struct{}](
+		atomictest.NewExecuter(struct{}{}),
+		chaos.Policy{
+			Seed:  seed,
+			Rules: []chaos.Rule{{Point: chaos.Begin, Probability: 0.5}},
+		},
+	)
+
+	failed := make([]bool, n)
+
+	for i := range failed {
+		err := executer.Execute(context.Background(), func(struct{}) error {
+			return nil
+		})
+		if err != nil && !errors.Is(err, chaos.ErrInjected) {
+			t.Fatalf("executing: %v", err)
+		}
+
+		failed[i] = err != nil
+	}
+
+	if injected := executer.Injected(chaos.Begin); injected == 0 || injected == n {
+		t.Fatalf("expected some of %d executions to fail, got %d", n, injected)
+	}
+
+	return failed
+}
+
+func TestSeededProbability(t *testing.T) {
+	first := failures(t, 1, 100)
+
+	if second := failures(t, 1, 100); !reflect.DeepEqual(first, second) {
+		t.Fatal("expected executers with the same seed to inject the same faults")
+	}
+
+	if other := failures(t, 2, 100); reflect.DeepEqual(first, other) {
+		t.Fatal("expected executers with different seeds to inject different faults")
+	}
+}
@@ -1,18 +1,35 @@
-// Package crdb implements [generic.Executer] for cockroachdb
+// Package crdb implements [generic.Executer] for cockroachdb.
+// Executers are provided for sqlx ([NewExecuter]), database/sql ([NewSQLExecuter]), pgx
+// ([NewPgxExecuter]) and gorm ([NewGormExecuter]). All of them retry transactions with the retry
+// loop of cockroach and share their options.
 package crdb
 
 import (
 	"context"
 	"database/sql"
 
+	"github.com/beeemT/go-atomic"
 	"github.com/beeemT/go-atomic/generic"
+	"github.com/beeemT/go-atomic/generic/adapter"
+	"github.com/beeemT/go-atomic/internal/sqlgen"
 	crdb "github.com/cockroachdb/cockroach-go/v2/crdb/crdbsqlx"
+	"github.com/jackc/pgx/v5"
 	"github.com/jmoiron/sqlx"
 	"github.com/pkg/errors"
 )
 
+const (
+	// PriorityLow is the LOW transaction priority.
+	PriorityLow Priority = "LOW"
+	// PriorityNormal is the NORMAL transaction priority.
+	PriorityNormal Priority = "NORMAL"
+	// PriorityHigh is the HIGH transaction priority.
+	PriorityHigh Priority = "HIGH"
+)
+
 var (
 	_ generic.SQLXRemote                   = (*sqlx.Tx)(nil)
+	_ generic.SQLXRemote                   = (*Tx)(nil)
 	_ generic.Executer[generic.SQLXRemote] = Executer{}
 )
 
@@ -20,46 +37,224 @@ type (
 	// Executer implements the [generic.Executer] interface for cockroachdb with sqlx
 	Executer struct {
 		db     *sqlx.DB
-		txOpts *sql.TxOptions
+		config config
+	}
+
+	// ExecuterOption configures the executers of this package.
+	ExecuterOption func(*config)
+
+	config struct {
+		txOpts        *sql.TxOptions
+		pgxTxOpts     pgx.TxOptions
+		priority      Priority
+		readCommitted bool
+		followerReads bool
 	}
 
-	// ExecuterOption configures the [Executer] instance
-	ExecuterOption func(*Executer)
+	// Priority is the priority of a transaction in conflicts with other transactions.
+	Priority string
+
+	// Tx is the Remote passed to the function executed by [Executer]. It is a sqlx transaction
+	// which knows how often it was restarted by the retry loop of cockroach.
+	Tx struct {
+		*sqlx.Tx
+
+		restarts int
+	}
 )
 
-// WithTxOptions allows setting the TxOptions to use when opening a new transaction
+// WithTxOptions allows setting the TxOptions to use when opening a new transaction.
+// It is not used by the pgx executer, see [WithPgxTxOptions].
 func WithTxOptions(opts *sql.TxOptions) ExecuterOption {
-	return func(e *Executer) {
-		e.txOpts = opts
+	return func(c *config) {
+		c.txOpts = opts
 	}
 }
 
-// NewExecuter creates a new Executer
-func NewExecuter(db *sqlx.DB, opts ...ExecuterOption) Executer {
-	executer := Executer{
-		db:     db,
-		txOpts: &sql.TxOptions{},
+// WithPgxTxOptions allows setting the TxOptions the pgx executer uses when opening a new
+// transaction.
+func WithPgxTxOptions(opts pgx.TxOptions) ExecuterOption {
+	return func(c *config) {
+		c.pgxTxOpts = opts
+	}
+}
+
+// WithPriority sets the priority of the transactions with SET TRANSACTION PRIORITY.
+func WithPriority(priority Priority) ExecuterOption {
+	return func(c *config) {
+		c.priority = priority
 	}
+}
 
-	for _, opt := range opts {
-		opt(&executer)
+// WithReadCommitted runs the transactions with the READ COMMITTED isolation level instead of
+// SERIALIZABLE. The isolation level is set when the transaction is opened and overrides the
+// isolation level of [WithTxOptions] and [WithPgxTxOptions]. It has to be enabled on the cluster
+// with the sql.txn.read_committed_isolation.enabled setting.
+func WithReadCommitted() ExecuterOption {
+	return func(c *config) {
+		c.readCommitted = true
 	}
+}
 
-	return executer
+// WithFollowerReads runs transactions opened with a context marked by [generic.ReadOnly] with
+// AS OF SYSTEM TIME follower_read_timestamp(), so they are served by the closest replica with
+// slightly stale data.
+func WithFollowerReads() ExecuterOption {
+	return func(c *config) {
+		c.followerReads = true
+	}
+}
+
+// NewExecuter creates a new Executer
+func NewExecuter(db *sqlx.DB, opts ...ExecuterOption) Executer {
+	return Executer{
+		db:     db,
+		config: newConfig(opts),
+	}
 }
 
 // Execute executes the provided function in a transaction with the cockroach retries on retryable
-// errors
+// errors. The Remote passed to run is a [*Tx].
+// The [generic.Timeouts] of ctx are applied to the transaction, errors caused by them are returned
+// as [atomic.TimeoutError].
 func (executer Executer) Execute(ctx context.Context, run func(generic.SQLXRemote) error) error {
 	return errors.Wrap(
-		crdb.ExecuteTx(
+		atomic.ClassifyTimeoutError(crdb.ExecuteTx(
 			ctx,
 			executer.db,
-			executer.txOpts,
-			func(tx *sqlx.Tx) error {
-				return run(tx)
-			},
-		),
+			executer.config.txOptions(),
+			attempt(
+				ctx,
+				executer.config,
+				func(tx *sqlx.Tx, statement string) error {
+					_, err := tx.ExecContext(ctx, statement)
+
+					return err //nolint:wrapcheck // wrapped by attempt
+				},
+				func(tx *sqlx.Tx, restarts int) error {
+					return run(&Tx{Tx: tx, restarts: restarts})
+				},
+			),
+		)),
 		"creating / executing crdb sqlx tx",
 	)
 }
+
+// Restarts returns how often the transaction was restarted by the retry loop of cockroach.
+func (tx *Tx) Restarts() int {
+	return tx.restarts
+}
+
+// Restarts returns how often the transaction of the session in ctx was restarted by the retry
+// loop of cockroach. Remotes wrapped by the session guard or hooks are unwrapped with
+// [generic.UnwrapRemote]. It returns 0 if ctx holds no session of an executer of this package.
+func Restarts(ctx context.Context) int {
+	var remote any
+
+	switch session := ctx.Value(atomic.SessionContextKey).(type) {
+	case *generic.Session[generic.SQLXRemote]:
+		remote = session.Tx
+	case *generic.Session[generic.SQLRemote]:
+		remote = session.Tx
+	case *generic.Session[generic.PgxRemote]:
+		remote = session.Tx
+	case *generic.Session[generic.GormRemote]:
+		remote = session.Tx
+	}
+
+	for remote != nil {
+		if restarter, ok := remote.(interface{ Restarts() int }); ok {
+			return restarter.Restarts()
+		}
+
+		remote, _ = generic.UnwrapRemote(remote)
+	}
+
+	return 0
+}
+
+func newConfig(opts []ExecuterOption) config {
+	c := config{
+		txOpts: &sql.TxOptions{},
+	}
+
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	return c
+}
+
+// txOptions returns the options of database/sql transactions.
+func (c config) txOptions() *sql.TxOptions {
+	if !c.readCommitted {
+		return c.txOpts
+	}
+
+	opts := sql.TxOptions{Isolation: sql.LevelReadCommitted}
+	if c.txOpts != nil {
+		opts.ReadOnly = c.txOpts.ReadOnly
+	}
+
+	return &opts
+}
+
+// pgxTxOptions returns the options of pgx transactions.
+func (c config) pgxTxOptions() pgx.TxOptions {
+	opts := c.pgxTxOpts
+	if c.readCommitted {
+		opts.IsoLevel = pgx.ReadCommitted
+	}
+
+	return opts
+}
+
+// statements returns the statements configuring a new transaction. The transaction settings are
+// executed before the first attempt only, as they cannot be changed after statements were
+// executed, which is the case when cockroach restarts the transaction at its savepoint. The
+// timeouts are executed before every attempt.
+func (c config) statements(ctx context.Context) (settings []string, timeouts []string) {
+	if c.followerReads && generic.IsReadOnly(ctx) {
+		settings = append(settings, "SET TRANSACTION AS OF SYSTEM TIME follower_read_timestamp()")
+	}
+
+	if c.priority != "" {
+		settings = append(settings, "SET TRANSACTION PRIORITY "+string(c.priority))
+	}
+
+	ctxTimeouts, _ := generic.TimeoutsFromContext(ctx)
+
+	return settings, sqlgen.SetTimeouts(adapter.Postgres, ctxTimeouts)
+}
+
+// attempt returns the function called by the retry loop of cockroach for every attempt. It
+// configures the transaction with the statements of c and passes it to run with the number of
+// restarts.
+func attempt[T any](
+	ctx context.Context,
+	c config,
+	exec func(tx T, statement string) error,
+	run func(tx T, restarts int) error,
+) func(T) error {
+	settings, timeouts := c.statements(ctx)
+	first := append(settings, timeouts...)
+	attempts := 0
+
+	return func(tx T) error {
+		attempts++
+
+		statements := timeouts
+		if attempts == 1 {
+			statements = first
+		}
+
+		for _, statement := range statements {
+			err := exec(tx, statement)
+			if err != nil {
+				return errors.Wrap(err, statement)
+			}
+		}
+
+		return run(tx, attempts-1)
+	}
+}
@@ -0,0 +1,88 @@
+package crdb
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/beeemT/go-atomic/generic"
+	"github.com/jackc/pgx/v5"
+)
+
+func TestAttemptStatements(t *testing.T) {
+	ctx := generic.WithTimeouts(
+		generic.ReadOnly(context.Background()),
+		generic.Timeouts{Statement: time.Second},
+	)
+	c := newConfig([]ExecuterOption{
+		WithPriority(PriorityHigh),
+		WithFollowerReads(),
+		WithReadCommitted(),
+	})
+
+	var executed [][]string
+
+	fn := attempt(
+		ctx,
+		c,
+		func(tx *[]string, statement string) error {
+			*tx = append(*tx, statement)
+
+			return nil
+		},
+		func(tx *[]string, restarts int) error {
+			if restarts != len(executed) {
+				t.Fatalf("expected %d restarts, got %d", len(executed), restarts)
+			}
+
+			executed = append(executed, *tx)
+
+			return nil
+		},
+	)
+
+	for i := 0; i < 2; i++ {
+		var tx []string
+
+		err := fn(&tx)
+		if err != nil {
+			t.Fatalf("attempt %d: %v", i, err)
+		}
+	}
+
+	expected := [][]string{
+		{
+			"SET TRANSACTION AS OF SYSTEM TIME follower_read_timestamp()",
+			"SET TRANSACTION PRIORITY HIGH",
+			"SET LOCAL statement_timeout = 1000",
+		},
+		{"SET LOCAL statement_timeout = 1000"},
+	}
+	if !reflect.DeepEqual(executed, expected) {
+		t.Fatalf("expected statements %q, got %q", expected, executed)
+	}
+}
+
+func TestReadCommittedTxOptions(t *testing.T) {
+	c := newConfig([]ExecuterOption{
+		WithTxOptions(&sql.TxOptions{Isolation: sql.LevelSerializable, ReadOnly: true}),
+		WithPgxTxOptions(pgx.TxOptions{IsoLevel: pgx.Serializable, AccessMode: pgx.ReadOnly}),
+		WithReadCommitted(),
+	})
+
+	opts := c.txOptions()
+	if opts.Isolation != sql.LevelReadCommitted || !opts.ReadOnly {
+		t.Fatalf("unexpected sql options %+v", opts)
+	}
+
+	pgxOpts := c.pgxTxOptions()
+	if pgxOpts.IsoLevel != pgx.ReadCommitted || pgxOpts.AccessMode != pgx.ReadOnly {
+		t.Fatalf("unexpected pgx options %+v", pgxOpts)
+	}
+
+	if opts := newConfig(nil).txOptions(); opts.Isolation != sql.LevelDefault {
+		t.Fatalf("unexpected default options %+v", opts)
+	}
+}
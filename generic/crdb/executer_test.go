@@ -0,0 +1,247 @@
+package crdb_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/beeemT/go-atomic"
+	"github.com/beeemT/go-atomic/generic"
+	"github.com/beeemT/go-atomic/generic/crdb"
+	"github.com/beeemT/go-atomic/generic/executortest"
+	"github.com/beeemT/go-atomic/internal/sqlitetest"
+	"github.com/jmoiron/sqlx"
+)
+
+const markers = "CREATE TABLE markers (value TEXT NOT NULL)"
+
+// restartError is a retryable error of cockroach, which restarts the transaction at its
+// savepoint. SQLite supports the savepoint statements of the retry loop.
+type restartError struct{}
+
+func (restartError) Error() string {
+	return "restart transaction"
+}
+
+func (restartError) SQLState() string {
+	return "40001"
+}
+
+// restartOnce returns a run function failing its first attempt with a restartError and checking
+// crdb.Restarts in every attempt.
+func restartOnce[Resources any](
+	t *testing.T,
+	insert func(ctx context.Context, resources Resources) error,
+) func(ctx context.Context, resources Resources) error {
+	t.Helper()
+
+	attempts := 0
+
+	return func(ctx context.Context, resources Resources) error {
+		if restarts := crdb.Restarts(ctx); restarts != attempts {
+			t.Fatalf("expected %d restarts, got %d", attempts, restarts)
+		}
+
+		attempts++
+
+		err := insert(ctx, resources)
+		if err != nil {
+			return err
+		}
+
+		if attempts == 1 {
+			return restartError{}
+		}
+
+		return nil
+	}
+}
+
+func count(t *testing.T, query func(dest *int) error) int {
+	t.Helper()
+
+	var n int
+
+	err := query(&n)
+	if err != nil {
+		t.Fatalf("counting markers: %v", err)
+	}
+
+	return n
+}
+
+func TestSQLExecuterRestart(t *testing.T) {
+	db := sqlitetest.Open(t, markers)
+	transacter := generic.NewTransacter[generic.SQLRemote, generic.SQLRemote](
+		crdb.NewSQLExecuter(db),
+		func(_ context.Context, _ *generic.Transacter[generic.SQLRemote, generic.SQLRemote],
+			tx generic.SQLRemote,
+		) (generic.SQLRemote, error) {
+			return tx, nil
+		},
+		generic.WithSessionGuard[generic.SQLRemote, generic.SQLRemote](generic.HookSQLRemote),
+	)
+
+	err := transacter.Transact(context.Background(), restartOnce(t,
+		func(ctx context.Context, tx generic.SQLRemote) error {
+			_, err := tx.ExecContext(ctx, "INSERT INTO markers (value) VALUES ('sql')")
+
+			return err
+		},
+	))
+	if err != nil {
+		t.Fatalf("transacting: %v", err)
+	}
+
+	if n := count(t, func(dest *int) error {
+		return db.QueryRow("SELECT COUNT(*) FROM markers").Scan(dest)
+	}); n != 1 {
+		t.Fatalf("expected insert of restarted attempt to be rolled back, got %d rows", n)
+	}
+}
+
+func TestSQLXExecuterRestart(t *testing.T) {
+	db := sqlx.NewDb(sqlitetest.Open(t, markers), "sqlite3")
+	transacter := generic.NewTransacter[generic.SQLXRemote, generic.SQLXRemote](
+		crdb.NewExecuter(db),
+		func(_ context.Context, _ *generic.Transacter[generic.SQLXRemote, generic.SQLXRemote],
+			tx generic.SQLXRemote,
+		) (generic.SQLXRemote, error) {
+			return tx, nil
+		},
+		generic.WithSessionGuard[generic.SQLXRemote, generic.SQLXRemote](generic.HookSQLXRemote),
+	)
+
+	err := transacter.Transact(context.Background(), restartOnce(t,
+		func(ctx context.Context, tx generic.SQLXRemote) error {
+			return tx.GetContext(ctx, new(int), "INSERT INTO markers (value) VALUES ('sqlx') "+
+				"RETURNING 1")
+		},
+	))
+	if err != nil {
+		t.Fatalf("transacting: %v", err)
+	}
+
+	if n := count(t, func(dest *int) error {
+		return db.Get(dest, "SELECT COUNT(*) FROM markers")
+	}); n != 1 {
+		t.Fatalf("expected insert of restarted attempt to be rolled back, got %d rows", n)
+	}
+}
+
+func TestGormExecuterRestart(t *testing.T) {
+	db := sqlitetest.OpenGorm(t, markers)
+	transacter := generic.NewTransacter[generic.GormRemote, generic.GormRemote](
+		crdb.NewGormExecuter(db),
+		func(_ context.Context, _ *generic.Transacter[generic.GormRemote, generic.GormRemote],
+			tx generic.GormRemote,
+		) (generic.GormRemote, error) {
+			return tx, nil
+		},
+	)
+
+	err := transacter.Transact(context.Background(), restartOnce(t,
+		func(_ context.Context, tx generic.GormRemote) error {
+			return tx.Exec("INSERT INTO markers (value) VALUES ('gorm')").Error
+		},
+	))
+	if err != nil {
+		t.Fatalf("transacting: %v", err)
+	}
+
+	if n := count(t, func(dest *int) error {
+		return db.Raw("SELECT COUNT(*) FROM markers").Scan(dest).Error
+	}); n != 1 {
+		t.Fatalf("expected insert of restarted attempt to be rolled back, got %d rows", n)
+	}
+}
+
+func TestRestartsWithoutSession(t *testing.T) {
+	if restarts := crdb.Restarts(context.Background()); restarts != 0 {
+		t.Fatalf("expected 0 restarts without session, got %d", restarts)
+	}
+
+	ctx := context.WithValue(
+		context.Background(),
+		atomic.SessionContextKey,
+		&generic.Session[generic.SQLRemote]{},
+	)
+	if restarts := crdb.Restarts(ctx); restarts != 0 {
+		t.Fatalf("expected 0 restarts without Remote, got %d", restarts)
+	}
+}
+
+func TestConformance(t *testing.T) {
+	t.Run("sqlx", func(t *testing.T) {
+		executortest.Run(t, func(t *testing.T) executortest.Harness[generic.SQLXRemote] {
+			db := sqlx.NewDb(sqlitetest.Open(t, markers), "sqlite3")
+
+			return executortest.Harness[generic.SQLXRemote]{
+				Executer: crdb.NewExecuter(db),
+				Insert: func(ctx context.Context, tx generic.SQLXRemote, value string) error {
+					_, err := tx.NamedExecContext(
+						ctx,
+						"INSERT INTO markers (value) VALUES (:value)",
+						map[string]any{"value": value},
+					)
+
+					return err
+				},
+				Exists: func(ctx context.Context, value string) (bool, error) {
+					var n int
+
+					err := db.GetContext(
+						ctx, &n, "SELECT COUNT(*) FROM markers WHERE value = ?", value,
+					)
+
+					return n > 0, err
+				},
+			}
+		})
+	})
+
+	t.Run("sql", func(t *testing.T) {
+		executortest.Run(t, func(t *testing.T) executortest.Harness[generic.SQLRemote] {
+			db := sqlitetest.Open(t, markers)
+
+			return executortest.Harness[generic.SQLRemote]{
+				Executer: crdb.NewSQLExecuter(db),
+				Insert: func(ctx context.Context, tx generic.SQLRemote, value string) error {
+					_, err := tx.ExecContext(ctx, "INSERT INTO markers (value) VALUES (?)", value)
+
+					return err
+				},
+				Exists: func(ctx context.Context, value string) (bool, error) {
+					var n int
+
+					err := db.QueryRowContext(
+						ctx, "SELECT COUNT(*) FROM markers WHERE value = ?", value,
+					).Scan(&n)
+
+					return n > 0, err
+				},
+			}
+		})
+	})
+
+	t.Run("gorm", func(t *testing.T) {
+		executortest.Run(t, func(t *testing.T) executortest.Harness[generic.GormRemote] {
+			db := sqlitetest.OpenGorm(t, markers)
+
+			return executortest.Harness[generic.GormRemote]{
+				Executer: crdb.NewGormExecuter(db),
+				Insert: func(_ context.Context, tx generic.GormRemote, value string) error {
+					return tx.Exec("INSERT INTO markers (value) VALUES (?)", value).Error
+				},
+				Exists: func(ctx context.Context, value string) (bool, error) {
+					var n int
+
+					err := db.WithContext(ctx).
+						Raw("SELECT COUNT(*) FROM markers WHERE value = ?", value).
+						Scan(&n).Error
+
+					return n > 0, err
+				},
+			}
+		})
+	})
+}
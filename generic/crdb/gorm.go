@@ -0,0 +1,76 @@
+package crdb
+
+import (
+	"context"
+
+	"github.com/beeemT/go-atomic"
+	"github.com/beeemT/go-atomic/generic"
+	"github.com/cockroachdb/cockroach-go/v2/crdb/crdbgorm"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+var (
+	_ generic.GormRemote                   = (*GormTx)(nil)
+	_ generic.Executer[generic.GormRemote] = GormExecuter{}
+)
+
+type (
+	// GormExecuter implements the [generic.Executer] interface for cockroachdb with gorm.
+	GormExecuter struct {
+		db     *gorm.DB
+		config config
+	}
+
+	// GormTx is the Remote passed to the function executed by [GormExecuter]. It is a gorm
+	// transaction which knows how often it was restarted by the retry loop of cockroach.
+	GormTx struct {
+		*gormDB
+
+		restarts int
+	}
+
+	// gormDB is embedded with a name which does not shadow the DB method.
+	gormDB = gorm.DB
+)
+
+// NewGormExecuter creates a new GormExecuter
+func NewGormExecuter(db *gorm.DB, opts ...ExecuterOption) GormExecuter {
+	return GormExecuter{
+		db:     db,
+		config: newConfig(opts),
+	}
+}
+
+// Execute executes the provided function in a transaction with the cockroach retries on retryable
+// errors. The Remote passed to run is a [*GormTx].
+// The [generic.Timeouts] of ctx are applied to the transaction, errors caused by them are returned
+// as [atomic.TimeoutError].
+func (executer GormExecuter) Execute(
+	ctx context.Context,
+	run func(generic.GormRemote) error,
+) error {
+	return errors.Wrap(
+		atomic.ClassifyTimeoutError(crdbgorm.ExecuteTx(
+			ctx,
+			executer.db,
+			executer.config.txOptions(),
+			attempt(
+				ctx,
+				executer.config,
+				func(tx *gorm.DB, statement string) error {
+					return tx.Exec(statement).Error
+				},
+				func(tx *gorm.DB, restarts int) error {
+					return run(&GormTx{gormDB: tx, restarts: restarts})
+				},
+			),
+		)),
+		"creating / executing crdb gorm tx",
+	)
+}
+
+// Restarts returns how often the transaction was restarted by the retry loop of cockroach.
+func (tx *GormTx) Restarts() int {
+	return tx.restarts
+}
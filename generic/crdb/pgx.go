@@ -0,0 +1,77 @@
+package crdb
+
+import (
+	"context"
+
+	"github.com/beeemT/go-atomic"
+	"github.com/beeemT/go-atomic/generic"
+	crdbpgx "github.com/cockroachdb/cockroach-go/v2/crdb/crdbpgxv5"
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+)
+
+var (
+	_ generic.PgxRemote                   = (pgx.Tx)(nil)
+	_ generic.PgxRemote                   = (*PgxTx)(nil)
+	_ generic.Executer[generic.PgxRemote] = PgxExecuter{}
+)
+
+type (
+	// PgxConn opens pgx transactions, it is implemented by pgx.Conn and pgxpool.Pool.
+	PgxConn = crdbpgx.Conn
+
+	// PgxExecuter implements the [generic.Executer] interface for cockroachdb with pgx.
+	PgxExecuter struct {
+		conn   PgxConn
+		config config
+	}
+
+	// PgxTx is the Remote passed to the function executed by [PgxExecuter]. It is a pgx
+	// transaction which knows how often it was restarted by the retry loop of cockroach.
+	PgxTx struct {
+		pgx.Tx
+
+		restarts int
+	}
+)
+
+// NewPgxExecuter creates a new PgxExecuter opening transactions with conn, ie a pgx.Conn or a
+// pgxpool.Pool. The transactions are opened with the options set by [WithPgxTxOptions].
+func NewPgxExecuter(conn PgxConn, opts ...ExecuterOption) PgxExecuter {
+	return PgxExecuter{
+		conn:   conn,
+		config: newConfig(opts),
+	}
+}
+
+// Execute executes the provided function in a transaction with the cockroach retries on retryable
+// errors. The Remote passed to run is a [*PgxTx].
+// The [generic.Timeouts] of ctx are applied to the transaction, errors caused by them are returned
+// as [atomic.TimeoutError].
+func (executer PgxExecuter) Execute(ctx context.Context, run func(generic.PgxRemote) error) error {
+	return errors.Wrap(
+		atomic.ClassifyTimeoutError(crdbpgx.ExecuteTx(
+			ctx,
+			executer.conn,
+			executer.config.pgxTxOptions(),
+			attempt(
+				ctx,
+				executer.config,
+				func(tx pgx.Tx, statement string) error {
+					_, err := tx.Exec(ctx, statement)
+
+					return err //nolint:wrapcheck // wrapped by attempt
+				},
+				func(tx pgx.Tx, restarts int) error {
+					return run(&PgxTx{Tx: tx, restarts: restarts})
+				},
+			),
+		)),
+		"creating / executing crdb pgx tx",
+	)
+}
+
+// Restarts returns how often the transaction was restarted by the retry loop of cockroach.
+func (tx *PgxTx) Restarts() int {
+	return tx.restarts
+}
@@ -0,0 +1,72 @@
+package crdb
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/beeemT/go-atomic"
+	"github.com/beeemT/go-atomic/generic"
+	"github.com/cockroachdb/cockroach-go/v2/crdb"
+	"github.com/pkg/errors"
+)
+
+var (
+	_ generic.SQLRemote                   = (*SQLTx)(nil)
+	_ generic.Executer[generic.SQLRemote] = SQLExecuter{}
+)
+
+type (
+	// SQLExecuter implements the [generic.Executer] interface for cockroachdb with database/sql.
+	SQLExecuter struct {
+		db     *sql.DB
+		config config
+	}
+
+	// SQLTx is the Remote passed to the function executed by [SQLExecuter]. It is a database/sql
+	// transaction which knows how often it was restarted by the retry loop of cockroach.
+	SQLTx struct {
+		*sql.Tx
+
+		restarts int
+	}
+)
+
+// NewSQLExecuter creates a new SQLExecuter
+func NewSQLExecuter(db *sql.DB, opts ...ExecuterOption) SQLExecuter {
+	return SQLExecuter{
+		db:     db,
+		config: newConfig(opts),
+	}
+}
+
+// Execute executes the provided function in a transaction with the cockroach retries on retryable
+// errors. The Remote passed to run is a [*SQLTx].
+// The [generic.Timeouts] of ctx are applied to the transaction, errors caused by them are returned
+// as [atomic.TimeoutError].
+func (executer SQLExecuter) Execute(ctx context.Context, run func(generic.SQLRemote) error) error {
+	return errors.Wrap(
+		atomic.ClassifyTimeoutError(crdb.ExecuteTx(
+			ctx,
+			executer.db,
+			executer.config.txOptions(),
+			attempt(
+				ctx,
+				executer.config,
+				func(tx *sql.Tx, statement string) error {
+					_, err := tx.ExecContext(ctx, statement)
+
+					return err //nolint:wrapcheck // wrapped by attempt
+				},
+				func(tx *sql.Tx, restarts int) error {
+					return run(&SQLTx{Tx: tx, restarts: restarts})
+				},
+			),
+		)),
+		"creating / executing crdb sql tx",
+	)
+}
+
+// Restarts returns how often the transaction was restarted by the retry loop of cockroach.
+func (tx *SQLTx) Restarts() int {
+	return tx.restarts
+}
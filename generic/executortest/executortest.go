@@ -0,0 +1,304 @@
+// Package executortest provides a conformance suite for implementations of [generic.Executer].
+// It checks that an executer commits, rolls back, handles panics and cancelled contexts and
+// works with the nesting and retries of [generic.Transacter] like the executers of go-atomic.
+//
+// The suite is run from a test of the executer package:
+//
+//	func TestConformance(t *testing.T) {
+//		executortest.Run(t, func(t *testing.T) executortest.Harness[generic.SQLRemote] {
+//			db := openDB(t) // eg an in-memory SQLite database with a markers table
+//
+//			return executortest.Harness[generic.SQLRemote]{
+//				Executer: sql.NewExecuter(db),
+//				Insert: func(ctx context.Context, tx generic.SQLRemote, value string) error {
+//					_, err := tx.ExecContext(ctx, "INSERT INTO markers (value) VALUES (?)", value)
+//					return err
+//				},
+//				Exists: func(ctx context.Context, value string) (bool, error) {
+//					var count int
+//					err := db.QueryRowContext(
+//						ctx, "SELECT COUNT(*) FROM markers WHERE value = ?", value,
+//					).Scan(&count)
+//					return count > 0, err
+//				},
+//			}
+//		})
+//	}
+package executortest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/beeemT/go-atomic/generic"
+)
+
+// Harness connects the conformance suite to the executer under test.
+type Harness[Remote any] struct {
+	// Executer is the executer under test.
+	Executer generic.Executer[Remote]
+	// Insert writes a marker row holding value within the transaction of remote.
+	Insert func(ctx context.Context, remote Remote, value string) error
+	// Exists reports whether a committed marker row holds value. It is called outside of
+	// transactions.
+	Exists func(ctx context.Context, value string) (bool, error)
+}
+
+var errRun = errors.New("run failed")
+
+// Run runs the conformance suite as subtests of t. factory is called for every subtest and has
+// to return a harness with an empty data source.
+func Run[Remote any](t *testing.T, factory func(t *testing.T) Harness[Remote]) {
+	t.Helper()
+
+	tests := []struct {
+		name string
+		test func(t *testing.T, h Harness[Remote])
+	}{
+		{"Commit", testCommit[Remote]},
+		{"Rollback", testRollback[Remote]},
+		{"Panic", testPanic[Remote]},
+		{"CancelledBeforeBegin", testCancelledBeforeBegin[Remote]},
+		{"CancelledDuringRun", testCancelledDuringRun[Remote]},
+		{"NestedCommit", testNestedCommit[Remote]},
+		{"NestedRollback", testNestedRollback[Remote]},
+		{"Retry", testRetry[Remote]},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.test(t, factory(t))
+		})
+	}
+}
+
+func testCommit[Remote any](t *testing.T, h Harness[Remote]) {
+	ctx := context.Background()
+
+	err := h.Executer.Execute(ctx, func(remote Remote) error {
+		return h.Insert(ctx, remote, "commit")
+	})
+	if err != nil {
+		t.Fatalf("executing: %v", err)
+	}
+
+	expectExists(t, h, "commit", true)
+}
+
+func testRollback[Remote any](t *testing.T, h Harness[Remote]) {
+	ctx := context.Background()
+
+	err := h.Executer.Execute(ctx, func(remote Remote) error {
+		err := h.Insert(ctx, remote, "rollback")
+		if err != nil {
+			t.Fatalf("inserting: %v", err)
+		}
+
+		return errRun
+	})
+	if !errors.Is(err, errRun) {
+		t.Fatalf("expected error of run in chain, got %v", err)
+	}
+
+	expectExists(t, h, "rollback", false)
+	expectUsable(t, h)
+}
+
+func testPanic[Remote any](t *testing.T, h Harness[Remote]) {
+	ctx := context.Background()
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("expected panic of run to be propagated")
+			}
+		}()
+
+		_ = h.Executer.Execute(ctx, func(remote Remote) error {
+			err := h.Insert(ctx, remote, "panic")
+			if err != nil {
+				t.Fatalf("inserting: %v", err)
+			}
+
+			panic("run panicked")
+		})
+	}()
+
+	expectExists(t, h, "panic", false)
+	expectUsable(t, h)
+}
+
+func testCancelledBeforeBegin[Remote any](t *testing.T, h Harness[Remote]) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := h.Executer.Execute(ctx, func(remote Remote) error {
+		return h.Insert(ctx, remote, "cancelled")
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled in chain, got %v", err)
+	}
+
+	expectExists(t, h, "cancelled", false)
+	expectUsable(t, h)
+}
+
+// testCancelledDuringRun checks that the result of Execute reports the truth if ctx is cancelled
+// after the statements of run were executed: the transaction is either rolled back and an error
+// is returned, or it is committed and no error is returned.
+func testCancelledDuringRun[Remote any](t *testing.T, h Harness[Remote]) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err := h.Executer.Execute(ctx, func(remote Remote) error {
+		err := h.Insert(ctx, remote, "cancelled")
+		if err != nil {
+			t.Fatalf("inserting: %v", err)
+		}
+
+		cancel()
+
+		return nil
+	})
+
+	expectExists(t, h, "cancelled", err == nil)
+	expectUsable(t, h)
+}
+
+func testNestedCommit[Remote any](t *testing.T, h Harness[Remote]) {
+	transacter := newTransacter(h)
+	executions := 0
+
+	err := transacter.Transact(
+		context.Background(),
+		func(ctx context.Context, remote Remote) error {
+			executions++
+
+			err := h.Insert(ctx, remote, "outer")
+			if err != nil {
+				return err
+			}
+
+			return transacter.Transact(ctx, func(ctx context.Context, remote Remote) error {
+				return h.Insert(ctx, remote, "inner")
+			})
+		},
+	)
+	if err != nil {
+		t.Fatalf("transacting: %v", err)
+	}
+
+	if executions != 1 {
+		t.Fatalf("expected outer run to be executed once, got %d", executions)
+	}
+
+	expectExists(t, h, "outer", true)
+	expectExists(t, h, "inner", true)
+}
+
+func testNestedRollback[Remote any](t *testing.T, h Harness[Remote]) {
+	transacter := newTransacter(h)
+
+	err := transacter.Transact(
+		context.Background(),
+		func(ctx context.Context, remote Remote) error {
+			err := h.Insert(ctx, remote, "outer")
+			if err != nil {
+				return err
+			}
+
+			return transacter.Transact(ctx, func(ctx context.Context, remote Remote) error {
+				err := h.Insert(ctx, remote, "inner")
+				if err != nil {
+					return err
+				}
+
+				return errRun
+			})
+		},
+	)
+	if !errors.Is(err, errRun) {
+		t.Fatalf("expected error of inner run in chain, got %v", err)
+	}
+
+	expectExists(t, h, "outer", false)
+	expectExists(t, h, "inner", false)
+}
+
+func testRetry[Remote any](t *testing.T, h Harness[Remote]) {
+	transacter := newTransacter(h, generic.WithBackOffDelays[Remote, Remote](0))
+	attempt := 0
+
+	err := transacter.Transact(
+		context.Background(),
+		func(ctx context.Context, remote Remote) error {
+			attempt++
+			if attempt == 1 {
+				err := h.Insert(ctx, remote, "first attempt")
+				if err != nil {
+					return err
+				}
+
+				return context.DeadlineExceeded
+			}
+
+			return h.Insert(ctx, remote, "second attempt")
+		},
+	)
+	if err != nil {
+		t.Fatalf("transacting: %v", err)
+	}
+
+	if attempt != 2 {
+		t.Fatalf("expected 2 attempts, got %d", attempt)
+	}
+
+	expectExists(t, h, "first attempt", false)
+	expectExists(t, h, "second attempt", true)
+}
+
+func newTransacter[Remote any](
+	h Harness[Remote],
+	opts ...generic.TransacterOption[Remote, Remote],
+) generic.Transacter[Remote, Remote] {
+	return generic.NewTransacter[Remote, Remote](
+		h.Executer,
+		func(_ context.Context, _ *generic.Transacter[Remote, Remote], tx Remote) (Remote, error) {
+			return tx, nil
+		},
+		opts...,
+	)
+}
+
+// expectUsable checks that the executer commits transactions after a failed one, ie it did not
+// leak the connection or transaction of the failed one.
+func expectUsable[Remote any](t *testing.T, h Harness[Remote]) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err := h.Executer.Execute(ctx, func(remote Remote) error {
+		return h.Insert(ctx, remote, "usable")
+	})
+	if err != nil {
+		t.Fatalf("executing after failed transaction: %v", err)
+	}
+
+	expectExists(t, h, "usable", true)
+}
+
+func expectExists[Remote any](t *testing.T, h Harness[Remote], value string, expected bool) {
+	t.Helper()
+
+	exists, err := h.Exists(context.Background(), value)
+	if err != nil {
+		t.Fatalf("checking marker %q: %v", value, err)
+	}
+
+	if exists != expected {
+		t.Fatalf("expected marker %q to exist: %t, got %t", value, expected, exists)
+	}
+}
@@ -60,13 +60,27 @@ func NewExecuter[T GormlikeDB[Remote], Remote any](
 	return executer
 }
 
-// Execute executes the provided function in a transaction
-func (executer Executer[T, Remote]) Execute(_ context.Context, run func(Remote) error) error {
+// Execute executes the provided function in a transaction.
+// The transaction is not opened if ctx is done already, as GormlikeDB does not support
+// contexts. Panics in run roll back the transaction.
+func (executer Executer[T, Remote]) Execute(ctx context.Context, run func(Remote) error) error {
+	if ctx.Err() != nil {
+		return errors.Wrap(ctx.Err(), "opening gorm tx")
+	}
+
 	db := executer.db.Begin(executer.txOpts)
 	if db.Error() != nil {
 		return errors.Wrap(db.Error(), "opening gorm tx")
 	}
 
+	defer func() {
+		if p := recover(); p != nil {
+			db.Rollback()
+
+			panic(p)
+		}
+	}()
+
 	err := run(db.Remote())
 	if err != nil {
 		db = db.Rollback()
@@ -82,7 +96,7 @@ func (executer Executer[T, Remote]) Execute(_ context.Context, run func(Remote)
 
 	db = db.Commit()
 	if db.Error() != nil {
-		return errors.Wrap(err, "committing gorm tx")
+		return errors.Wrap(db.Error(), "committing gorm tx")
 	}
 
 	return nil
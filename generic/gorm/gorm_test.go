@@ -0,0 +1,60 @@
+package gorm_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/beeemT/go-atomic/generic"
+	"github.com/beeemT/go-atomic/generic/executortest"
+	ggorm "github.com/beeemT/go-atomic/generic/gorm"
+	"github.com/beeemT/go-atomic/internal/sqlitetest"
+	"gorm.io/gorm"
+)
+
+// gormDB embeds a gorm.DB to implement GormlikeDB as described in the package documentation.
+type gormDB struct {
+	*gorm.DB
+}
+
+func (db gormDB) Begin(opts ...*sql.TxOptions) ggorm.GormlikeDB[generic.GormRemote] {
+	return gormDB{db.DB.Begin(opts...)}
+}
+
+func (db gormDB) Rollback() ggorm.GormlikeDB[generic.GormRemote] {
+	return gormDB{db.DB.Rollback()}
+}
+
+func (db gormDB) Commit() ggorm.GormlikeDB[generic.GormRemote] {
+	return gormDB{db.DB.Commit()}
+}
+
+func (db gormDB) Remote() generic.GormRemote {
+	return db.DB
+}
+
+func (db gormDB) Error() error {
+	return db.DB.Error
+}
+
+func TestConformance(t *testing.T) {
+	executortest.Run(t, func(t *testing.T) executortest.Harness[generic.GormRemote] {
+		db := sqlitetest.OpenGorm(t, "CREATE TABLE markers (value TEXT NOT NULL)")
+
+		return executortest.Harness[generic.GormRemote]{
+			Executer: ggorm.NewExecuter[gormDB, generic.GormRemote](gormDB{db}),
+			Insert: func(_ context.Context, tx generic.GormRemote, value string) error {
+				return tx.Exec("INSERT INTO markers (value) VALUES (?)", value).Error
+			},
+			Exists: func(ctx context.Context, value string) (bool, error) {
+				var count int
+
+				err := db.WithContext(ctx).
+					Raw("SELECT COUNT(*) FROM markers WHERE value = ?", value).
+					Scan(&count).Error
+
+				return count > 0, err
+			},
+		}
+	})
+}
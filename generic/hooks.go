@@ -0,0 +1,330 @@
+package generic
+
+import (
+	"context"
+	"database/sql"
+	"runtime/debug"
+	syncatomic "sync/atomic"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/beeemT/go-atomic"
+)
+
+var (
+	_ SQLRemote  = hookedSQLRemote{}
+	_ SQLXRemote = hookedSQLXRemote{}
+
+	_ sql.Result      = failedResult{}
+	_ context.Context = failedContext{}
+)
+
+// closed is the done channel of failedContext.
+var closed = func() chan struct{} {
+	c := make(chan struct{})
+	close(c)
+
+	return c
+}()
+
+type (
+	// Statement describes a statement run on a remote wrapped by [HookSQLRemote] or
+	// [HookSQLXRemote].
+	Statement struct {
+		// Method is the name of the called method of the remote, eg "ExecContext".
+		Method string
+		// Query is the query of the statement.
+		Query string
+	}
+
+	// StatementHook is called before every statement run on a hooked remote. If it returns an
+	// error, the statement is not run and the error is returned by the called method.
+	// Methods without error result (see [Statement.Fallible]) return a row whose Scan returns the
+	// error or a result whose methods return the error instead.
+	StatementHook func(ctx context.Context, statement Statement) error
+
+	// failedResult is the result of a statement whose hook failed.
+	failedResult struct {
+		err error
+	}
+
+	// failedContext is a done context whose Err is err. Passed to database/sql, the error is
+	// returned before a connection is used, which creates rows failing with err for methods
+	// without error result, eg QueryRowContext.
+	failedContext struct {
+		context.Context //nolint:containedctx // wraps the context of the statement
+		err             error
+	}
+
+	hookedSQLRemote struct {
+		remote SQLRemote
+		hook   StatementHook
+	}
+
+	hookedSQLXRemote struct {
+		remote SQLXRemote
+		hook   StatementHook
+	}
+)
+
+// Fallible reports whether the method of the statement returns an error. Errors of hooks for other
+// statements are returned when the returned row is scanned or the returned result is read.
+func (statement Statement) Fallible() bool {
+	switch statement.Method {
+	case "QueryRowContext", "QueryRowxContext", "MustExecContext":
+		return false
+	}
+
+	return true
+}
+
+// HookSQLRemote returns a [SQLRemote] calling hook before every statement run on remote.
+// The returned remote unwraps to remote.
+func HookSQLRemote(remote SQLRemote, hook StatementHook) SQLRemote {
+	return hookedSQLRemote{remote: remote, hook: hook}
+}
+
+// HookSQLXRemote returns a [SQLXRemote] calling hook before every statement run on remote.
+// The returned remote unwraps to remote.
+func HookSQLXRemote(remote SQLXRemote, hook StatementHook) SQLXRemote {
+	return hookedSQLXRemote{remote: remote, hook: hook}
+}
+
+// UnwrapRemote returns the Remote wrapped by remote, eg by [HookSQLRemote] or the session guard,
+// if remote has an Unwrap method returning one of the Remote interfaces of this package.
+// It returns false if remote wraps no Remote.
+func UnwrapRemote(remote any) (any, bool) {
+	switch r := remote.(type) {
+	case interface{ Unwrap() SQLRemote }:
+		return r.Unwrap(), true
+	case interface{ Unwrap() SQLXRemote }:
+		return r.Unwrap(), true
+	case interface{ Unwrap() PgxRemote }:
+		return r.Unwrap(), true
+	case interface{ Unwrap() GormRemote }:
+		return r.Unwrap(), true
+	}
+
+	return nil, false
+}
+
+func (ctx failedContext) Done() <-chan struct{} {
+	return closed
+}
+
+func (ctx failedContext) Err() error {
+	return ctx.err
+}
+
+func (r failedResult) LastInsertId() (int64, error) {
+	return 0, r.err
+}
+
+func (r failedResult) RowsAffected() (int64, error) {
+	return 0, r.err
+}
+
+// Unwrap returns the hooked remote.
+func (r hookedSQLRemote) Unwrap() SQLRemote {
+	return r.remote
+}
+
+func (r hookedSQLRemote) ExecContext(
+	ctx context.Context,
+	query string,
+	args ...any,
+) (sql.Result, error) {
+	err := r.hook(ctx, Statement{Method: "ExecContext", Query: query})
+	if err != nil {
+		return nil, err
+	}
+
+	return r.remote.ExecContext(ctx, query, args...) //nolint:wrapcheck // proxy
+}
+
+func (r hookedSQLRemote) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	err := r.hook(ctx, Statement{Method: "PrepareContext", Query: query})
+	if err != nil {
+		return nil, err
+	}
+
+	return r.remote.PrepareContext(ctx, query) //nolint:wrapcheck // proxy
+}
+
+func (r hookedSQLRemote) QueryContext(
+	ctx context.Context,
+	query string,
+	args ...any,
+) (*sql.Rows, error) {
+	err := r.hook(ctx, Statement{Method: "QueryContext", Query: query})
+	if err != nil {
+		return nil, err
+	}
+
+	return r.remote.QueryContext(ctx, query, args...) //nolint:wrapcheck // proxy
+}
+
+func (r hookedSQLRemote) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	err := r.hook(ctx, Statement{Method: "QueryRowContext", Query: query})
+	if err != nil {
+		return r.remote.QueryRowContext(failedContext{Context: ctx, err: err}, query, args...)
+	}
+
+	return r.remote.QueryRowContext(ctx, query, args...)
+}
+
+// Unwrap returns the hooked remote.
+func (r hookedSQLXRemote) Unwrap() SQLXRemote {
+	return r.remote
+}
+
+func (r hookedSQLXRemote) BindNamed(query string, arg any) (string, []any, error) {
+	return r.remote.BindNamed(query, arg) //nolint:wrapcheck // proxy
+}
+
+func (r hookedSQLXRemote) DriverName() string {
+	return r.remote.DriverName()
+}
+
+func (r hookedSQLXRemote) GetContext(
+	ctx context.Context,
+	dest any,
+	query string,
+	args ...any,
+) error {
+	err := r.hook(ctx, Statement{Method: "GetContext", Query: query})
+	if err != nil {
+		return err
+	}
+
+	return r.remote.GetContext(ctx, dest, query, args...) //nolint:wrapcheck // proxy
+}
+
+func (r hookedSQLXRemote) MustExecContext(
+	ctx context.Context,
+	query string,
+	args ...any,
+) sql.Result {
+	err := r.hook(ctx, Statement{Method: "MustExecContext", Query: query})
+	if err != nil {
+		return failedResult{err: err}
+	}
+
+	return r.remote.MustExecContext(ctx, query, args...)
+}
+
+func (r hookedSQLXRemote) NamedExecContext(
+	ctx context.Context,
+	query string,
+	arg any,
+) (sql.Result, error) {
+	err := r.hook(ctx, Statement{Method: "NamedExecContext", Query: query})
+	if err != nil {
+		return nil, err
+	}
+
+	return r.remote.NamedExecContext(ctx, query, arg) //nolint:wrapcheck // proxy
+}
+
+func (r hookedSQLXRemote) NamedQuery(query string, arg any) (*sqlx.Rows, error) {
+	err := r.hook(context.Background(), Statement{Method: "NamedQuery", Query: query})
+	if err != nil {
+		return nil, err
+	}
+
+	return r.remote.NamedQuery(query, arg) //nolint:wrapcheck // proxy
+}
+
+func (r hookedSQLXRemote) NamedStmtContext(
+	ctx context.Context,
+	stmt *sqlx.NamedStmt,
+) *sqlx.NamedStmt {
+	return r.remote.NamedStmtContext(ctx, stmt)
+}
+
+func (r hookedSQLXRemote) PrepareNamedContext(
+	ctx context.Context,
+	query string,
+) (*sqlx.NamedStmt, error) {
+	err := r.hook(ctx, Statement{Method: "PrepareNamedContext", Query: query})
+	if err != nil {
+		return nil, err
+	}
+
+	return r.remote.PrepareNamedContext(ctx, query) //nolint:wrapcheck // proxy
+}
+
+func (r hookedSQLXRemote) PreparexContext(ctx context.Context, query string) (*sqlx.Stmt, error) {
+	err := r.hook(ctx, Statement{Method: "PreparexContext", Query: query})
+	if err != nil {
+		return nil, err
+	}
+
+	return r.remote.PreparexContext(ctx, query) //nolint:wrapcheck // proxy
+}
+
+func (r hookedSQLXRemote) QueryRowxContext(
+	ctx context.Context,
+	query string,
+	args ...any,
+) *sqlx.Row {
+	err := r.hook(ctx, Statement{Method: "QueryRowxContext", Query: query})
+	if err != nil {
+		return r.remote.QueryRowxContext(failedContext{Context: ctx, err: err}, query, args...)
+	}
+
+	return r.remote.QueryRowxContext(ctx, query, args...)
+}
+
+func (r hookedSQLXRemote) QueryxContext(
+	ctx context.Context,
+	query string,
+	args ...any,
+) (*sqlx.Rows, error) {
+	err := r.hook(ctx, Statement{Method: "QueryxContext", Query: query})
+	if err != nil {
+		return nil, err
+	}
+
+	return r.remote.QueryxContext(ctx, query, args...) //nolint:wrapcheck // proxy
+}
+
+func (r hookedSQLXRemote) Rebind(query string) string {
+	return r.remote.Rebind(query)
+}
+
+func (r hookedSQLXRemote) SelectContext(
+	ctx context.Context,
+	dest any,
+	query string,
+	args ...any,
+) error {
+	err := r.hook(ctx, Statement{Method: "SelectContext", Query: query})
+	if err != nil {
+		return err
+	}
+
+	return r.remote.SelectContext(ctx, dest, query, args...) //nolint:wrapcheck // proxy
+}
+
+// sessionGuard fails statements on the Remote of a session after the session ended.
+type sessionGuard struct {
+	closed syncatomic.Bool
+	stack  []byte
+}
+
+func newSessionGuard() *sessionGuard {
+	return &sessionGuard{stack: debug.Stack()}
+}
+
+func (guard *sessionGuard) close() {
+	guard.closed.Store(true)
+}
+
+func (guard *sessionGuard) hook(context.Context, Statement) error {
+	if guard.closed.Load() {
+		return &atomic.SessionClosedError{Stack: guard.stack}
+	}
+
+	return nil
+}
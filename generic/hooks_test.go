@@ -0,0 +1,118 @@
+package generic_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/beeemT/go-atomic"
+	"github.com/beeemT/go-atomic/generic"
+	gsql "github.com/beeemT/go-atomic/generic/sql"
+	gsqlx "github.com/beeemT/go-atomic/generic/sqlx"
+	"github.com/beeemT/go-atomic/internal/sqlitetest"
+	"github.com/jmoiron/sqlx"
+)
+
+const markers = "CREATE TABLE markers (value TEXT NOT NULL)"
+
+// leak runs a transaction with transacter and returns the Remote passed as resources, which is
+// used after its session ended.
+func leak[Remote any](t *testing.T, transacter generic.Transacter[Remote, Remote]) Remote {
+	t.Helper()
+
+	var leaked Remote
+
+	err := transacter.Transact(context.Background(), func(_ context.Context, tx Remote) error {
+		leaked = tx
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("transacting: %v", err)
+	}
+
+	return leaked
+}
+
+func passRemote[Remote any](
+	_ context.Context,
+	_ *generic.Transacter[Remote, Remote],
+	tx Remote,
+) (Remote, error) {
+	return tx, nil
+}
+
+func TestSessionGuardSQL(t *testing.T) {
+	ctx := context.Background()
+	leaked := leak(t, generic.NewTransacter[generic.SQLRemote, generic.SQLRemote](
+		gsql.NewExecuter(sqlitetest.Open(t, markers)),
+		passRemote[generic.SQLRemote],
+		generic.WithSessionGuard[generic.SQLRemote, generic.SQLRemote](generic.HookSQLRemote),
+	))
+
+	_, err := leaked.ExecContext(ctx, "INSERT INTO markers (value) VALUES ('leaked')")
+	if !errors.Is(err, atomic.ErrSessionClosed) {
+		t.Fatalf("expected ErrSessionClosed from ExecContext, got %v", err)
+	}
+
+	err = leaked.QueryRowContext(ctx, "SELECT COUNT(*) FROM markers").Scan(new(int))
+	if !errors.Is(err, atomic.ErrSessionClosed) {
+		t.Fatalf("expected ErrSessionClosed from QueryRowContext, got %v", err)
+	}
+}
+
+func TestSessionGuardSQLX(t *testing.T) {
+	ctx := context.Background()
+	leaked := leak(t, generic.NewTransacter[generic.SQLXRemote, generic.SQLXRemote](
+		gsqlx.NewExecuter(sqlx.NewDb(sqlitetest.Open(t, markers), "sqlite3")),
+		passRemote[generic.SQLXRemote],
+		generic.WithSessionGuard[generic.SQLXRemote, generic.SQLXRemote](generic.HookSQLXRemote),
+	))
+
+	err := leaked.QueryRowxContext(ctx, "SELECT COUNT(*) FROM markers").Scan(new(int))
+	if !errors.Is(err, atomic.ErrSessionClosed) {
+		t.Fatalf("expected ErrSessionClosed from QueryRowxContext, got %v", err)
+	}
+
+	result := leaked.MustExecContext(ctx, "INSERT INTO markers (value) VALUES ('leaked')")
+
+	_, err = result.RowsAffected()
+	if !errors.Is(err, atomic.ErrSessionClosed) {
+		t.Fatalf("expected ErrSessionClosed from MustExecContext, got %v", err)
+	}
+
+	_, err = result.LastInsertId()
+	if !errors.Is(err, atomic.ErrSessionClosed) {
+		t.Fatalf("expected ErrSessionClosed from MustExecContext, got %v", err)
+	}
+}
+
+func TestSessionGuardInSession(t *testing.T) {
+	transacter := generic.NewTransacter[generic.SQLRemote, generic.SQLRemote](
+		gsql.NewExecuter(sqlitetest.Open(t, markers)),
+		passRemote[generic.SQLRemote],
+		generic.WithSessionGuard[generic.SQLRemote, generic.SQLRemote](generic.HookSQLRemote),
+	)
+
+	err := transacter.Transact(
+		context.Background(),
+		func(ctx context.Context, tx generic.SQLRemote) error {
+			_, err := tx.ExecContext(ctx, "INSERT INTO markers (value) VALUES ('guarded')")
+			if err != nil {
+				return err
+			}
+
+			var count int
+
+			err = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM markers").Scan(&count)
+			if err == nil && count != 1 {
+				t.Fatalf("expected 1 marker, got %d", count)
+			}
+
+			return err
+		},
+	)
+	if err != nil {
+		t.Fatalf("transacting: %v", err)
+	}
+}
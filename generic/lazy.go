@@ -0,0 +1,45 @@
+package generic
+
+import "sync"
+
+// Lazy is a value which is created when it is first accessed. It is used for fields of Resources
+// which are expensive to create, eg repositories preparing statements, and not needed by every
+// call of Transact:
+//
+//	func createResources(
+//		ctx context.Context,
+//		_ *generic.Transacter[generic.SQLRemote, Resources],
+//		tx generic.SQLRemote,
+//	) (Resources, error) {
+//		return Resources{
+//			Reports: generic.NewLazy(func() (ReportRepo, error) {
+//				return NewReportRepo(ctx, tx)
+//			}),
+//		}, nil
+//	}
+//
+// Lazy is safe for concurrent use.
+type Lazy[T any] struct {
+	once   sync.Once
+	create func() (T, error)
+	value  T
+	err    error
+}
+
+// NewLazy creates a new Lazy created with create.
+func NewLazy[T any](create func() (T, error)) *Lazy[T] {
+	return &Lazy[T]{
+		create: create,
+	}
+}
+
+// Get returns the value, creating it on the first call. The error of the creation is returned by
+// every call, the value is not created again.
+func (lazy *Lazy[T]) Get() (T, error) {
+	lazy.once.Do(func() {
+		lazy.value, lazy.err = lazy.create()
+		lazy.create = nil
+	})
+
+	return lazy.value, lazy.err
+}
@@ -0,0 +1,61 @@
+package generic_test
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/beeemT/go-atomic/generic"
+)
+
+func TestLazy(t *testing.T) {
+	var created atomic.Int32
+
+	lazy := generic.NewLazy(func() (int, error) {
+		return int(created.Add(1)), nil
+	})
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			value, err := lazy.Get()
+			if err != nil || value != 1 {
+				t.Errorf("expected value 1, got %d, %v", value, err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if created.Load() != 1 {
+		t.Fatalf("expected value to be created once, got %d", created.Load())
+	}
+}
+
+func TestLazyError(t *testing.T) {
+	errCreate := errors.New("create failed")
+	calls := 0
+
+	lazy := generic.NewLazy(func() (int, error) {
+		calls++
+
+		return 0, errCreate
+	})
+
+	for i := 0; i < 2; i++ {
+		_, err := lazy.Get()
+		if !errors.Is(err, errCreate) {
+			t.Fatalf("expected error of create, got %v", err)
+		}
+	}
+
+	if calls != 1 {
+		t.Fatalf("expected value not to be created again, got %d calls", calls)
+	}
+}
@@ -0,0 +1,309 @@
+// Package multi implements [generic.Executer] for units of work spanning several executers, eg
+// a Postgres database and Redis.
+// It opens a transaction on every participant and commits them one after the other in a defined
+// order. This is a best effort approach: if a commit fails after other participants committed
+// already, the committed participants are reported and their compensations are run. If the outcome
+// of the failed commit is unknown, the compensations are left to the caller.
+package multi
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/beeemT/go-atomic"
+	"github.com/beeemT/go-atomic/generic"
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+)
+
+var (
+	_ generic.Executer[Remotes] = Executer{}
+
+	// ErrPartialCommit is matched by [PartialCommitError].
+	ErrPartialCommit = errors.New("partial commit")
+	// ErrUnknownParticipant is returned by [Remote] if no participant with the name exists.
+	ErrUnknownParticipant = errors.New("unknown participant")
+
+	// errPanicked rolls back the participants if run panicked.
+	errPanicked = errors.New("run panicked")
+)
+
+type (
+	// Remotes holds the remotes of the transactions opened for every participant.
+	// It is passed as composite Remote to the createResources function of the transacter,
+	// the individual remotes are retrieved with [Remote].
+	Remotes struct {
+		remotes map[string]any
+	}
+
+	// Participant is an executer taking part in the unit of work of an [Executer].
+	Participant struct {
+		name       string
+		execute    func(ctx context.Context, run func(any) error) error
+		compensate func(ctx context.Context) error
+	}
+
+	// ParticipantOption configures the [Participant] instance.
+	ParticipantOption func(*Participant)
+
+	// Executer implements the [generic.Executer] interface for several participants.
+	Executer struct {
+		participants    []Participant
+		onPartialCommit func(ctx context.Context, err *PartialCommitError)
+	}
+
+	// ExecuterOption configures the [Executer] instance.
+	ExecuterOption func(*Executer)
+
+	// PartialCommitError is returned if a participant failed to commit after other participants
+	// committed already.
+	// It does not unwrap to the commit error, so retry functions do not rerun the unit of work.
+	// If the outcome of the failed commit is unknown, it also matches [atomic.ErrCommitUnknown].
+	PartialCommitError struct {
+		// Committed lists the participants which committed, in commit order.
+		Committed []string
+		// Failed is the participant whose commit failed.
+		Failed string
+		// RolledBack lists the participants which were rolled back after the failed commit.
+		RolledBack []string
+		// Err is the error returned by the failed commit.
+		Err error
+		// CompensationErr holds the errors of the compensations of committed participants.
+		CompensationErr error
+		// Ambiguous reports that the failed commit returned [atomic.ErrCommitUnknown], so the
+		// failed participant might have committed as well. Compensations are not run in this case,
+		// as they could undo a unit of work which was applied completely.
+		Ambiguous bool
+	}
+)
+
+// NewRemotes creates Remotes holding remotes by participant name.
+// It is used by executers which open the transactions of their participants themselves.
+func NewRemotes(remotes map[string]any) Remotes {
+	return Remotes{remotes: remotes}
+}
+
+// WithCompensation sets a function which is called if the participant committed but a later
+// participant failed to commit. It should undo the changes of the participant.
+// It is not called if the outcome of the failed commit is unknown, see
+// [PartialCommitError.Ambiguous].
+func WithCompensation(compensate func(ctx context.Context) error) ParticipantOption {
+	return func(p *Participant) {
+		p.compensate = compensate
+	}
+}
+
+// NewParticipant creates a new Participant named name which opens its transactions with executer.
+// The Remote of the transaction is available to createResources with [Remote] using name.
+func NewParticipant[Remote any](
+	name string,
+	executer generic.Executer[Remote],
+	opts ...ParticipantOption,
+) Participant {
+	participant := Participant{
+		name: name,
+		execute: func(ctx context.Context, run func(any) error) error {
+			//nolint:wrapcheck //wrapped by the multi executer
+			return executer.Execute(ctx, func(remote Remote) error {
+				return run(remote)
+			})
+		},
+	}
+
+	for _, opt := range opts {
+		opt(&participant)
+	}
+
+	return participant
+}
+
+// WithPartialCommitHandler sets a function which is called with every partial commit, after the
+// compensations of the committed participants ran. It can eg be used for alerting or to resolve
+// ambiguous commits, see [PartialCommitError.Ambiguous].
+func WithPartialCommitHandler(
+	onPartialCommit func(ctx context.Context, err *PartialCommitError),
+) ExecuterOption {
+	return func(e *Executer) {
+		e.onPartialCommit = onPartialCommit
+	}
+}
+
+// NewExecuter creates a new Executer for participants.
+// Participants commit in the order they are passed in, the transaction of the first participant
+// is committed first. As the transactions are nested, a participant whose executer retries
+// internally (eg the crdb executer) reruns the transactions of all participants before it, so
+// such a participant should be passed first.
+func NewExecuter(participants []Participant, opts ...ExecuterOption) Executer {
+	executer := Executer{
+		participants:    participants,
+		onPartialCommit: func(context.Context, *PartialCommitError) {},
+	}
+
+	for _, opt := range opts {
+		opt(&executer)
+	}
+
+	return executer
+}
+
+// Execute executes the provided function in a transaction of every participant.
+// If a participant fails to commit, all participants which did not commit yet are rolled back.
+// If participants committed already, a [PartialCommitError] is returned.
+// If the outcome of the failed commit is unknown, no compensations are run and the returned
+// [PartialCommitError] is marked as ambiguous.
+// Panics in run roll back the transactions of all participants and are propagated afterwards.
+func (executer Executer) Execute(ctx context.Context, run func(Remotes) error) error {
+	var (
+		remotes   = Remotes{remotes: make(map[string]any, len(executer.participants))}
+		committed int
+		panicked  any
+		execute   func(i int) error
+	)
+
+	// participants are nested in reverse order, so the first participant commits first
+	execute = func(i int) error {
+		if i < 0 {
+			// a panic is returned as error, so every participant rolls back its transaction even
+			// if its executer does not handle panics
+			var err error
+
+			panicked, err = protect(remotes, run)
+
+			return err
+		}
+
+		participant := executer.participants[i]
+		innerFailed := false
+
+		err := participant.execute(ctx, func(remote any) error {
+			remotes.remotes[participant.name] = remote
+
+			err := execute(i - 1)
+			innerFailed = err != nil
+
+			return err
+		})
+
+		switch {
+		case err == nil:
+			committed++
+
+			return nil
+		case innerFailed || committed == 0:
+			return errors.Wrapf(err, "executing participant %s", participant.name)
+		}
+
+		return executer.partialCommit(ctx, i, err)
+	}
+
+	err := execute(len(executer.participants) - 1)
+	if panicked != nil {
+		panic(panicked)
+	}
+
+	return err
+}
+
+// protect calls run and recovers a panic of it, which is returned together with errPanicked.
+func protect(remotes Remotes, run func(Remotes) error) (panicked any, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			panicked, err = p, errPanicked
+		}
+	}()
+
+	return nil, run(remotes)
+}
+
+// partialCommit reports the failed commit of the participant at index failed and runs the
+// compensations of the participants committed before it in reverse commit order, unless the
+// outcome of the failed commit is unknown.
+func (executer Executer) partialCommit(ctx context.Context, failed int, commitErr error) error {
+	err := &PartialCommitError{
+		Failed:    executer.participants[failed].name,
+		Err:       commitErr,
+		Ambiguous: errors.Is(commitErr, atomic.ErrCommitUnknown),
+	}
+
+	for _, participant := range executer.participants[:failed] {
+		err.Committed = append(err.Committed, participant.name)
+	}
+
+	for _, participant := range executer.participants[failed+1:] {
+		err.RolledBack = append(err.RolledBack, participant.name)
+	}
+
+	for i := failed - 1; i >= 0 && !err.Ambiguous; i-- {
+		participant := executer.participants[i]
+		if participant.compensate == nil {
+			continue
+		}
+
+		err.CompensationErr = multierr.Append(
+			err.CompensationErr,
+			errors.Wrapf(
+				participant.compensate(ctx),
+				"compensating participant %s",
+				participant.name,
+			),
+		)
+	}
+
+	executer.onPartialCommit(ctx, err)
+
+	return err
+}
+
+// Error implements the error interface.
+func (e *PartialCommitError) Error() string {
+	failed := "failed"
+	if e.Ambiguous {
+		failed = "outcome unknown for"
+	}
+
+	msg := fmt.Sprintf(
+		"%s: committed [%s], %s %s: %v",
+		ErrPartialCommit,
+		strings.Join(e.Committed, ", "),
+		failed,
+		e.Failed,
+		e.Err,
+	)
+
+	if e.CompensationErr != nil {
+		msg += fmt.Sprintf("; compensating: %v", e.CompensationErr)
+	}
+
+	return msg
+}
+
+// Is reports whether target is [ErrPartialCommit], or [atomic.ErrCommitUnknown] if the error is
+// ambiguous.
+func (e *PartialCommitError) Is(target error) bool {
+	//nolint:errorlint // sentinel comparison
+	return target == ErrPartialCommit || e.Ambiguous && target == atomic.ErrCommitUnknown
+}
+
+// Remote returns the remote of the participant named name from remotes.
+func Remote[R any](remotes Remotes, name string) (R, error) {
+	var zero R
+
+	remote, ok := remotes.remotes[name]
+	if !ok {
+		return zero, errors.Wrap(ErrUnknownParticipant, name)
+	}
+
+	typed, ok := remote.(R)
+	if !ok {
+		return zero, errors.Errorf(
+			"cannot use remote %T of participant %s as %s",
+			remote,
+			name,
+			reflect.TypeOf((*R)(nil)).Elem(),
+		)
+	}
+
+	return typed, nil
+}
@@ -0,0 +1,205 @@
+package multi_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/beeemT/go-atomic"
+	"github.com/beeemT/go-atomic/atomictest"
+	"github.com/beeemT/go-atomic/generic"
+	"github.com/beeemT/go-atomic/generic/executortest"
+	"github.com/beeemT/go-atomic/generic/multi"
+	gsql "github.com/beeemT/go-atomic/generic/sql"
+	"github.com/beeemT/go-atomic/internal/sqlitetest"
+)
+
+var participants = []string{"first", "second"}
+
+func TestConformance(t *testing.T) {
+	executortest.Run(t, func(t *testing.T) executortest.Harness[multi.Remotes] {
+		dbs := make(map[string]*sql.DB, len(participants))
+		executerParticipants := make([]multi.Participant, 0, len(participants))
+
+		for _, name := range participants {
+			dbs[name] = sqlitetest.Open(t, "CREATE TABLE markers (value TEXT NOT NULL)")
+			executerParticipants = append(
+				executerParticipants,
+				multi.NewParticipant[generic.SQLRemote](name, gsql.NewExecuter(dbs[name])),
+			)
+		}
+
+		return executortest.Harness[multi.Remotes]{
+			Executer: multi.NewExecuter(executerParticipants),
+			Insert: func(ctx context.Context, remotes multi.Remotes, value string) error {
+				for _, name := range participants {
+					tx, err := multi.Remote[generic.SQLRemote](remotes, name)
+					if err != nil {
+						return err
+					}
+
+					_, err = tx.ExecContext(ctx, "INSERT INTO markers (value) VALUES (?)", value)
+					if err != nil {
+						return err
+					}
+				}
+
+				return nil
+			},
+			// Exists reports whether the marker was committed by all participants and fails if it
+			// was only committed by some of them.
+			Exists: func(ctx context.Context, value string) (bool, error) {
+				committed := 0
+
+				for _, name := range participants {
+					var count int
+
+					err := dbs[name].QueryRowContext(
+						ctx, "SELECT COUNT(*) FROM markers WHERE value = ?", value,
+					).Scan(&count)
+					if err != nil {
+						return false, err
+					}
+
+					if count > 0 {
+						committed++
+					}
+				}
+
+				if committed != 0 && committed != len(participants) {
+					return false, fmt.Errorf(
+						"marker %q committed by %d participants", value, committed,
+					)
+				}
+
+				return committed > 0, nil
+			},
+		}
+	})
+}
+
+// TestPanicRollsBackParticipants checks that a panic of run rolls back participants whose
+// executer does not handle panics itself.
+func TestPanicRollsBackParticipants(t *testing.T) {
+	first := atomictest.NewExecuter(struct{}{})
+	second := atomictest.NewExecuter(struct{}{})
+	executer := multi.NewExecuter([]multi.Participant{
+		multi.NewParticipant[struct{}]("first", first),
+		multi.NewParticipant[struct{}]("second", second),
+	})
+
+	func() {
+		defer func() {
+			if recover() != "run panicked" {
+				t.Fatal("expected panic of run to be propagated")
+			}
+		}()
+
+		_ = executer.Execute(context.Background(), func(multi.Remotes) error {
+			panic("run panicked")
+		})
+	}()
+
+	if first.Rollbacks() != 1 || second.Rollbacks() != 1 {
+		t.Fatalf(
+			"expected all participants to roll back, got %+v and %+v",
+			first.Executions(),
+			second.Executions(),
+		)
+	}
+}
+
+func TestPartialCommit(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		commitErr   error
+		ambiguous   bool
+		compensated []string
+	}{
+		{
+			name:        "failed",
+			commitErr:   errors.New("commit failed"),
+			compensated: []string{"second", "first"},
+		},
+		{
+			name:      "outcome unknown",
+			commitErr: &atomic.CommitUnknownError{Err: net.ErrClosed},
+			ambiguous: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				compensated []string
+				handled     *multi.PartialCommitError
+			)
+
+			participant := func(
+				name string,
+				executer *atomictest.Executer[struct{}],
+			) multi.Participant {
+				return multi.NewParticipant[struct{}](name, executer,
+					multi.WithCompensation(func(context.Context) error {
+						compensated = append(compensated, name)
+
+						return nil
+					}),
+				)
+			}
+
+			failing := atomictest.NewExecuter(struct{}{})
+			failing.FailCommit(tc.commitErr)
+
+			rolledBack := atomictest.NewExecuter(struct{}{})
+			executer := multi.NewExecuter(
+				[]multi.Participant{
+					participant("first", atomictest.NewExecuter(struct{}{})),
+					participant("second", atomictest.NewExecuter(struct{}{})),
+					participant("third", failing),
+					participant("fourth", rolledBack),
+				},
+				multi.WithPartialCommitHandler(
+					func(_ context.Context, err *multi.PartialCommitError) {
+						handled = err
+					},
+				),
+			)
+
+			err := executer.Execute(context.Background(), func(multi.Remotes) error {
+				return nil
+			})
+
+			var partial *multi.PartialCommitError
+			if !errors.As(err, &partial) || !errors.Is(err, multi.ErrPartialCommit) {
+				t.Fatalf("expected PartialCommitError, got %v", err)
+			}
+
+			if !reflect.DeepEqual(partial.Committed, []string{"first", "second"}) ||
+				partial.Failed != "third" ||
+				!reflect.DeepEqual(partial.RolledBack, []string{"fourth"}) ||
+				partial.Ambiguous != tc.ambiguous {
+				t.Fatalf("unexpected partial commit %+v", partial)
+			}
+
+			if errors.Is(err, atomic.ErrCommitUnknown) != tc.ambiguous {
+				t.Fatalf("expected ErrCommitUnknown to match only ambiguous commits, got %v", err)
+			}
+
+			if !reflect.DeepEqual(compensated, tc.compensated) {
+				t.Fatalf("expected compensations %v, got %v", tc.compensated, compensated)
+			}
+
+			if handled != partial {
+				t.Fatalf("expected handler to be called with the error, got %+v", handled)
+			}
+
+			if rolledBack.Rollbacks() != 1 {
+				t.Fatalf("expected participant after the failed one to roll back, got %+v",
+					rolledBack.Executions())
+			}
+		})
+	}
+}
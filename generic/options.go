@@ -1,6 +1,11 @@
 package generic
 
-import "time"
+import (
+	"context"
+	"time"
+
+	"github.com/beeemT/go-atomic"
+)
 
 // WithBackOffRetry sets the retry function which manages automatic retries on errors.
 func WithBackOffRetry[Remote any, Resources any](
@@ -20,3 +25,66 @@ func WithBackOffDelays[Remote any, Resources any](
 		transacter.backoffs = backoffs
 	}
 }
+
+// WithCommitVerifier sets a function which is called if a transaction failed with an error in the
+// chain of [atomic.ErrCommitUnknown], to check whether the commit was applied by the remote.
+// If verify reports the commit as applied, Transact returns successfully.
+// If verify reports the commit as not applied, the original commit error is passed to the retry
+// function without [atomic.ErrCommitUnknown], so it is retried like any other error.
+// If verify fails, the ambiguous error is kept and the transaction is not retried.
+func WithCommitVerifier[Remote any, Resources any](
+	verify func(ctx context.Context) (committed bool, err error),
+) TransacterOption[Remote, Resources] {
+	return func(transacter *Transacter[Remote, Resources]) {
+		transacter.verifyCommit = verify
+	}
+}
+
+// WithSessionInitializer adds a function which is called with the transaction of every new
+// session before run, eg to set session variables with SET LOCAL. It is called again for every
+// retry. Initializers are called in the order they were added, an error fails the transaction.
+func WithSessionInitializer[Remote any, Resources any](
+	initialize func(ctx context.Context, tx Remote) error,
+) TransacterOption[Remote, Resources] {
+	return func(transacter *Transacter[Remote, Resources]) {
+		transacter.initializers = append(transacter.initializers, initialize)
+	}
+}
+
+// WithClock sets the clock of the transacter. Unless a retry function is set with
+// [WithBackOffRetry], the backoffs between retries are awaited with clock, so tests can pass a
+// fake clock to skip them. The clock is available to createResources with [Transacter.Clock].
+func WithClock[Remote any, Resources any](clock atomic.Clock) TransacterOption[Remote, Resources] {
+	return func(transacter *Transacter[Remote, Resources]) {
+		transacter.clock = clock
+	}
+}
+
+// WithSessionGuard enables a debug mode which detects Remotes used after the session of their
+// transaction ended, eg because a repository leaked the Remote passed to createResources into a
+// long-lived struct. wrap wraps the Remote of every new session with a statement hook, eg
+// [HookSQLRemote] or [HookSQLXRemote]. Statements run on the wrapped Remote after the session
+// ended fail with an [atomic.SessionClosedError] holding the stack trace of the creation of the
+// session. Methods without error result, like QueryRowContext, return a row or result failing
+// with the error instead.
+// Capturing the stack trace for every session is expensive, so the guard should not be enabled in
+// production.
+func WithSessionGuard[Remote any, Resources any](
+	wrap func(Remote, StatementHook) Remote,
+) TransacterOption[Remote, Resources] {
+	return func(transacter *Transacter[Remote, Resources]) {
+		transacter.guard = wrap
+	}
+}
+
+// WithSessionResources makes the transacter create its Resources once per session instead of on
+// every call of Transact. Nested calls of the transacter reuse the Resources of the outermost call,
+// so createResources is called with the context of the outermost call. Every retry starts a new
+// session and creates new Resources. Fields of Resources which are expensive to create and not
+// used by every call can be wrapped in [Lazy]. Nested calls running concurrently in the same
+// session may create the Resources concurrently, only the first created ones are kept and shared.
+func WithSessionResources[Remote any, Resources any]() TransacterOption[Remote, Resources] {
+	return func(transacter *Transacter[Remote, Resources]) {
+		transacter.resourcesKey = &resourcesKey{}
+	}
+}
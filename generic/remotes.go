@@ -4,6 +4,8 @@ import (
 	"context"
 	"database/sql"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jmoiron/sqlx"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
@@ -39,6 +41,21 @@ type (
 		SelectContext(ctx context.Context, dest any, query string, args ...any) error
 	}
 
+	// PgxRemote is a subset of the shared methods of pgx.Conn, pgxpool.Pool and pgx.Tx, explicitly
+	// excluding transaction related methods.
+	PgxRemote interface {
+		CopyFrom(
+			ctx context.Context,
+			tableName pgx.Identifier,
+			columnNames []string,
+			rowSrc pgx.CopyFromSource,
+		) (int64, error)
+		Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+		Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+		QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+		SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+	}
+
 	// GormRemote is a subset of the methods on [gorm.io/gorm.DB], explicitly excluding Transaction
 	// related methods to possibly avoid programming errors through manually using transactions
 	// within [Transacter.Transact] closures.
@@ -0,0 +1,209 @@
+package generic
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	syncatomic "sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/beeemT/go-atomic"
+)
+
+type (
+	readOnlyContextKey struct{}
+	stickyContextKey   struct{}
+
+	// stickiness records whether a write was committed with a context returned by [Sticky].
+	stickiness struct {
+		wrote syncatomic.Bool
+	}
+
+	// replicaSet holds the read replicas of a transacter.
+	replicaSet[Remote any] struct {
+		executers []Executer[Remote]
+		next      *syncatomic.Uint64
+		lag       func(ctx context.Context, replica int) (time.Duration, error)
+		maxLag    time.Duration
+		onError   func(error)
+	}
+)
+
+// ReadOnly marks the units of work run with the returned context as read only, so they are
+// routed to a read replica by transacters configured with [WithReadReplicas].
+// It has no effect on Transact calls reusing the session of an outer call.
+func ReadOnly(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readOnlyContextKey{}, true)
+}
+
+// IsReadOnly reports whether ctx was marked as read only with [ReadOnly].
+func IsReadOnly(ctx context.Context) bool {
+	readOnly, _ := ctx.Value(readOnlyContextKey{}).(bool)
+
+	return readOnly
+}
+
+// Sticky returns a context which routes read only units of work to the primary once a unit of
+// work which is not read only committed with it, so reads observe preceding writes despite
+// replication lag. It is usually called once per request.
+func Sticky(ctx context.Context) context.Context {
+	return context.WithValue(ctx, stickyContextKey{}, &stickiness{})
+}
+
+// WithReadReplicas routes units of work run with a context marked by [ReadOnly] to replicas in
+// round robin order, all other units of work are executed by the executer of the transacter.
+// If opening or committing the transaction on a replica fails because the connection to the replica
+// broke, the unit of work is executed by the primary executer instead. Errors returned by run and
+// all other errors are returned as is, so run is not executed again if it failed.
+func WithReadReplicas[Remote any, Resources any](
+	replicas ...Executer[Remote],
+) TransacterOption[Remote, Resources] {
+	return func(transacter *Transacter[Remote, Resources]) {
+		transacter.replicaSet().executers = replicas
+	}
+}
+
+// WithReplicaLag sets a function reporting the replication lag of the replica at index replica
+// of [WithReadReplicas]. Replicas whose lag exceeds maxLag or whose lag cannot be determined are
+// skipped. If all replicas are skipped, the unit of work is executed by the primary.
+// lag is called for every read only unit of work and should cache its results if determining the
+// lag is expensive.
+func WithReplicaLag[Remote any, Resources any](
+	lag func(ctx context.Context, replica int) (time.Duration, error),
+	maxLag time.Duration,
+) TransacterOption[Remote, Resources] {
+	return func(transacter *Transacter[Remote, Resources]) {
+		replicas := transacter.replicaSet()
+		replicas.lag = lag
+		replicas.maxLag = maxLag
+	}
+}
+
+// WithReplicaErrorHandler sets a function which is called with errors of replicas which caused a
+// fallback to the primary, as well as errors of the lag function.
+func WithReplicaErrorHandler[Remote any, Resources any](
+	onError func(error),
+) TransacterOption[Remote, Resources] {
+	return func(transacter *Transacter[Remote, Resources]) {
+		transacter.replicaSet().onError = onError
+	}
+}
+
+// replicaSet returns the replicas of the transacter, creating them if necessary.
+func (transacter *Transacter[Remote, Resources]) replicaSet() *replicaSet[Remote] {
+	if transacter.replicas == nil {
+		transacter.replicas = &replicaSet[Remote]{
+			next:    &syncatomic.Uint64{},
+			onError: func(error) {},
+		}
+	}
+
+	return transacter.replicas
+}
+
+// execute executes run in a new session on the primary or, for read only units of work, on a
+// replica.
+func (transacter *Transacter[Remote, Resources]) execute(
+	ctx context.Context,
+	run func(context.Context, Resources) error,
+) error {
+	readOnly := IsReadOnly(ctx)
+	sticky, _ := ctx.Value(stickyContextKey{}).(*stickiness)
+
+	if readOnly && (sticky == nil || !sticky.wrote.Load()) {
+		replica, ok := transacter.replicas.pick(ctx)
+		if ok {
+			var (
+				completed bool
+				runErr    error
+			)
+
+			err := replica.Execute(ctx, transacter.newSession(ctx, func(
+				ctx context.Context,
+				resources Resources,
+			) error {
+				runErr = run(ctx, resources)
+				completed = true
+
+				return runErr
+			}))
+			if err == nil || (completed && runErr != nil) || !isReplicaError(err) {
+				return err //nolint:wrapcheck // wrapped by Transact
+			}
+
+			transacter.replicas.onError(fmt.Errorf("executing on replica: %w", err))
+		}
+	}
+
+	err := transacter.executer.Execute(ctx, transacter.newSession(ctx, run))
+	if err == nil && !readOnly && sticky != nil {
+		sticky.wrote.Store(true)
+	}
+
+	return err //nolint:wrapcheck // wrapped by Transact
+}
+
+// pick returns the next replica in round robin order whose lag is acceptable.
+func (replicas *replicaSet[Remote]) pick(ctx context.Context) (Executer[Remote], bool) {
+	if replicas == nil || len(replicas.executers) == 0 {
+		return nil, false
+	}
+
+	start := replicas.next.Add(1) - 1
+	count := uint64(len(replicas.executers))
+
+	for i := uint64(0); i < count; i++ {
+		index := int((start + i) % count) //nolint:gosec // index is less than len(executers)
+
+		if replicas.lag != nil {
+			lag, err := replicas.lag(ctx, index)
+			if err != nil {
+				replicas.onError(fmt.Errorf("determining lag of replica %d: %w", index, err))
+
+				continue
+			}
+
+			if lag > replicas.maxLag {
+				continue
+			}
+		}
+
+		return replicas.executers[index], true
+	}
+
+	return nil, false
+}
+
+// isReplicaError reports whether err of opening or committing a transaction is caused by the
+// replica rather than by the unit of work, ie the driver reported that the connection to the
+// replica broke or could not be established. Errors of ctx are not considered, as the primary
+// would fail with them as well.
+func isReplicaError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	if atomic.IsConnectionError(err) {
+		return true
+	}
+
+	var stateErr interface{ SQLState() string }
+	if !errors.As(err, &stateErr) {
+		return false
+	}
+
+	state := stateErr.SQLState()
+
+	switch {
+	case strings.HasPrefix(state, "08"):
+		// connection exception
+		return true
+	case state == "57P01", state == "57P02", state == "57P03":
+		// admin shutdown, crash shutdown, cannot connect now
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,253 @@
+package generic_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/beeemT/go-atomic/atomictest"
+	"github.com/beeemT/go-atomic/generic"
+)
+
+// replicaExecuters are the fake executers of the read replica tests. Their Remote is the name of
+// the executer, so units of work can tell where they ran.
+type replicaExecuters struct {
+	primary  *atomictest.Executer[string]
+	replicas []*atomictest.Executer[string]
+}
+
+func newReplicaTransacter(
+	opts ...generic.TransacterOption[string, string],
+) (generic.Transacter[string, string], replicaExecuters) {
+	executers := replicaExecuters{
+		primary: atomictest.NewExecuter("primary"),
+		replicas: []*atomictest.Executer[string]{
+			atomictest.NewExecuter("replica 0"),
+			atomictest.NewExecuter("replica 1"),
+		},
+	}
+
+	transacter := generic.NewTransacter[string, string](
+		executers.primary,
+		func(_ context.Context, _ *generic.Transacter[string, string], tx string) (string, error) {
+			return tx, nil
+		},
+		append(
+			[]generic.TransacterOption[string, string]{
+				generic.WithReadReplicas[string, string](
+					executers.replicas[0],
+					executers.replicas[1],
+				),
+				generic.WithBackOffDelays[string, string](0),
+			},
+			opts...,
+		)...,
+	)
+
+	return transacter, executers
+}
+
+// executedBy returns the name of the executer which ran the unit of work.
+func executedBy(
+	t *testing.T,
+	ctx context.Context,
+	transacter generic.Transacter[string, string],
+) string {
+	t.Helper()
+
+	var name string
+
+	err := transacter.Transact(ctx, func(_ context.Context, remote string) error {
+		name = remote
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("transacting: %v", err)
+	}
+
+	return name
+}
+
+func TestReadReplicasRouting(t *testing.T) {
+	transacter, _ := newReplicaTransacter()
+	ctx := context.Background()
+
+	for i, expected := range []string{"replica 0", "replica 1", "replica 0"} {
+		if name := executedBy(t, generic.ReadOnly(ctx), transacter); name != expected {
+			t.Fatalf("expected read %d to run on %s, got %s", i, expected, name)
+		}
+	}
+
+	if name := executedBy(t, ctx, transacter); name != "primary" {
+		t.Fatalf("expected write to run on primary, got %s", name)
+	}
+}
+
+func TestReadReplicasSticky(t *testing.T) {
+	transacter, _ := newReplicaTransacter()
+	ctx := generic.Sticky(context.Background())
+
+	if name := executedBy(t, generic.ReadOnly(ctx), transacter); name != "replica 0" {
+		t.Fatalf("expected read before write to run on replica 0, got %s", name)
+	}
+
+	if name := executedBy(t, ctx, transacter); name != "primary" {
+		t.Fatalf("expected write to run on primary, got %s", name)
+	}
+
+	if name := executedBy(t, generic.ReadOnly(ctx), transacter); name != "primary" {
+		t.Fatalf("expected read after write to run on primary, got %s", name)
+	}
+
+	other := context.Background()
+	if name := executedBy(t, generic.ReadOnly(other), transacter); name != "replica 1" {
+		t.Fatalf("expected read of other context to run on replica 1, got %s", name)
+	}
+}
+
+func TestReadReplicasLag(t *testing.T) {
+	lags := map[int]time.Duration{0: time.Minute, 1: 0}
+	errLag := errors.New("lag unknown")
+
+	var handled []error
+
+	transacter, _ := newReplicaTransacter(
+		generic.WithReplicaLag[string, string](
+			func(_ context.Context, replica int) (time.Duration, error) {
+				lag, ok := lags[replica]
+				if !ok {
+					return 0, errLag
+				}
+
+				return lag, nil
+			},
+			time.Second,
+		),
+		generic.WithReplicaErrorHandler[string, string](func(err error) {
+			handled = append(handled, err)
+		}),
+	)
+	ctx := generic.ReadOnly(context.Background())
+
+	for i := 0; i < 2; i++ {
+		if name := executedBy(t, ctx, transacter); name != "replica 1" {
+			t.Fatalf("expected read %d to skip lagging replica 0, got %s", i, name)
+		}
+	}
+
+	lags[1] = time.Minute
+	if name := executedBy(t, ctx, transacter); name != "primary" {
+		t.Fatalf("expected read to run on primary if all replicas lag, got %s", name)
+	}
+
+	delete(lags, 0)
+	delete(lags, 1)
+
+	if name := executedBy(t, ctx, transacter); name != "primary" {
+		t.Fatalf("expected read to run on primary if the lag is unknown, got %s", name)
+	}
+
+	if len(handled) != 2 || !errors.Is(handled[0], errLag) || !errors.Is(handled[1], errLag) {
+		t.Fatalf("expected lag errors to be handled, got %v", handled)
+	}
+}
+
+func TestReadReplicasFallback(t *testing.T) {
+	errRun := errors.New("run failed")
+
+	for _, tc := range []struct {
+		name     string
+		fail     func(replica *atomictest.Executer[string])
+		runErr   error
+		fallback bool
+	}{
+		{
+			name: "begin connection error",
+			fail: func(replica *atomictest.Executer[string]) {
+				replica.FailBegin(driver.ErrBadConn)
+			},
+			fallback: true,
+		},
+		{
+			name: "commit connection error",
+			fail: func(replica *atomictest.Executer[string]) {
+				replica.FailCommit(driver.ErrBadConn)
+			},
+			fallback: true,
+		},
+		{
+			name: "begin error",
+			fail: func(replica *atomictest.Executer[string]) {
+				replica.FailBegin(errRun)
+			},
+		},
+		{
+			name: "begin context error",
+			fail: func(replica *atomictest.Executer[string]) {
+				replica.FailBegin(context.DeadlineExceeded)
+			},
+		},
+		{
+			name:   "run connection error",
+			fail:   func(*atomictest.Executer[string]) {},
+			runErr: driver.ErrBadConn,
+		},
+		{
+			name:   "run error",
+			fail:   func(*atomictest.Executer[string]) {},
+			runErr: errRun,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var handled []error
+
+			transacter, executers := newReplicaTransacter(
+				generic.WithReplicaErrorHandler[string, string](func(err error) {
+					handled = append(handled, err)
+				}),
+				generic.WithBackOffDelays[string, string](),
+			)
+			tc.fail(executers.replicas[0])
+
+			var ran []string
+
+			err := transacter.Transact(
+				generic.ReadOnly(context.Background()),
+				func(_ context.Context, remote string) error {
+					ran = append(ran, remote)
+					if remote == "replica 0" {
+						return tc.runErr
+					}
+
+					return nil
+				},
+			)
+
+			if tc.fallback {
+				if err != nil {
+					t.Fatalf("expected fallback to succeed, got %v", err)
+				}
+
+				if executers.primary.Commits() != 1 || len(handled) != 1 {
+					t.Fatalf(
+						"expected fallback to primary with handled error, got %d commits and %v",
+						executers.primary.Commits(), handled,
+					)
+				}
+
+				return
+			}
+
+			if err == nil {
+				t.Fatal("expected replica error to be returned")
+			}
+
+			if len(executers.primary.Executions()) != 0 || len(handled) != 0 {
+				t.Fatalf("expected no fallback to primary, ran on %v, handled %v", ran, handled)
+			}
+		})
+	}
+}
@@ -5,7 +5,10 @@ import (
 	"context"
 	"database/sql"
 
+	"github.com/beeemT/go-atomic"
 	"github.com/beeemT/go-atomic/generic"
+	"github.com/beeemT/go-atomic/generic/adapter"
+	"github.com/beeemT/go-atomic/internal/sqlgen"
 	"github.com/pkg/errors"
 	"go.uber.org/multierr"
 )
@@ -18,8 +21,10 @@ var (
 type (
 	// Executer implements the [generic.Executer] interface for a stlib sql db
 	Executer struct {
-		db     *sql.DB
-		txOpts *sql.TxOptions
+		db      *sql.DB
+		txOpts  *sql.TxOptions
+		dialect adapter.Dialect
+		stmts   *generic.StmtCache
 	}
 
 	// ExecuterOption configures the [Executer] instance
@@ -33,11 +38,21 @@ func WithTxOptions(opts *sql.TxOptions) ExecuterOption {
 	}
 }
 
+// WithDialect sets the dialect of the database, which determines the statements applying the
+// [generic.Timeouts] of the context. Defaults to the dialect inferred from the driver of the
+// database with [adapter.DriverDialect]. Timeouts are not applied if the dialect is unknown.
+func WithDialect(dialect adapter.Dialect) ExecuterOption {
+	return func(e *Executer) {
+		e.dialect = dialect
+	}
+}
+
 // NewExecuter creates a new Executer
 func NewExecuter(db *sql.DB, opts ...ExecuterOption) Executer {
 	executer := Executer{
-		db:     db,
-		txOpts: &sql.TxOptions{},
+		db:      db,
+		txOpts:  &sql.TxOptions{},
+		dialect: adapter.DriverDialect(db.Driver()),
 	}
 
 	for _, opt := range opts {
@@ -47,16 +62,60 @@ func NewExecuter(db *sql.DB, opts ...ExecuterOption) Executer {
 	return executer
 }
 
-// Execute executes the provided function in a transaction
+// Execute executes the provided function in a transaction.
+// The transaction is opened on the connection pinned by [Executer.Pin] if ctx holds one.
+// The [generic.Timeouts] of ctx are applied to the transaction, errors caused by them are returned
+// as [atomic.TimeoutError]. Panics in run roll back the transaction.
 func (executer Executer) Execute(ctx context.Context, run func(generic.SQLRemote) error) error {
-	tx, err := executer.db.BeginTx(ctx, executer.txOpts)
+	if conn, ok := executer.Conn(ctx); ok {
+		return executer.execute(ctx, conn, classifyPinnedError, run)
+	}
+
+	return executer.execute(ctx, executer.db, func(err error) error { return err }, run)
+}
+
+// execute executes run in a transaction opened with beginner. The errors of opening and committing
+// the transaction are passed through classify, the errors of run are returned as is.
+func (executer Executer) execute(
+	ctx context.Context,
+	beginner interface {
+		BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+	},
+	classify func(err error) error,
+	run func(generic.SQLRemote) error,
+) error {
+	tx, err := beginner.BeginTx(ctx, executer.txOpts)
 	if err != nil {
-		return errors.Wrap(err, "opening sql tx")
+		return errors.Wrap(classify(err), "opening sql tx")
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+
+			panic(p)
+		}
+	}()
+
+	timeouts, _ := generic.TimeoutsFromContext(ctx)
+
+	err = executeAll(ctx, tx, sqlgen.SetTimeouts(executer.dialect, timeouts))
+	if err == nil {
+		err = errors.Wrap(
+			atomic.ClassifyTimeoutError(run(executer.remote(tx))),
+			"executing run",
+		)
 	}
 
-	err = run(tx)
+	err = multierr.Append(
+		err,
+		executeAll(
+			context.WithoutCancel(ctx),
+			tx,
+			sqlgen.ResetTimeouts(executer.dialect, timeouts),
+		),
+	)
 	if err != nil {
-		err = errors.Wrap(err, "executing run")
 		innerErr := tx.Rollback()
 		if innerErr != nil {
 			return multierr.Append( //nolint:wrapcheck //individual errors are wrapped
@@ -70,7 +129,22 @@ func (executer Executer) Execute(ctx context.Context, run func(generic.SQLRemote
 
 	err = tx.Commit()
 	if err != nil {
-		return errors.Wrap(err, "committing sql tx")
+		return errors.Wrap(
+			classify(atomic.ClassifyCommitError(atomic.ClassifyTimeoutError(err))),
+			"committing sql tx",
+		)
+	}
+
+	return nil
+}
+
+// executeAll executes statements in tx.
+func executeAll(ctx context.Context, tx *sql.Tx, statements []string) error {
+	for _, statement := range statements {
+		_, err := tx.ExecContext(ctx, statement)
+		if err != nil {
+			return errors.Wrap(err, statement)
+		}
 	}
 
 	return nil
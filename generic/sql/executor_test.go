@@ -0,0 +1,35 @@
+package sql_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/beeemT/go-atomic/generic"
+	"github.com/beeemT/go-atomic/generic/executortest"
+	gsql "github.com/beeemT/go-atomic/generic/sql"
+	"github.com/beeemT/go-atomic/internal/sqlitetest"
+)
+
+func TestConformance(t *testing.T) {
+	executortest.Run(t, func(t *testing.T) executortest.Harness[generic.SQLRemote] {
+		db := sqlitetest.Open(t, "CREATE TABLE markers (value TEXT NOT NULL)")
+
+		return executortest.Harness[generic.SQLRemote]{
+			Executer: gsql.NewExecuter(db),
+			Insert: func(ctx context.Context, tx generic.SQLRemote, value string) error {
+				_, err := tx.ExecContext(ctx, "INSERT INTO markers (value) VALUES (?)", value)
+
+				return err
+			},
+			Exists: func(ctx context.Context, value string) (bool, error) {
+				var count int
+
+				err := db.QueryRowContext(
+					ctx, "SELECT COUNT(*) FROM markers WHERE value = ?", value,
+				).Scan(&count)
+
+				return count > 0, err
+			},
+		}
+	})
+}
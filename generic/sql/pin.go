@@ -0,0 +1,93 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+)
+
+// ErrPinnedConnLost is in the chain of errors returned by transactions on a connection pinned by
+// [Executer.Pin] if opening or committing a transaction failed because the connection broke. Such
+// errors are not retried by [github.com/beeemT/go-atomic.DefaultRetry], as every retry would use
+// the broken connection again, and the session state of the connection is lost, so the pinned unit
+// of work has to be started again by the caller.
+var ErrPinnedConnLost = errors.New("pinned sql conn lost")
+
+type (
+	// pinnedConnKey is the context key of the connection pinned for a db.
+	pinnedConnKey struct {
+		db *sql.DB
+	}
+
+	// pinnedConnLostError wraps an error of a transaction on a broken pinned connection.
+	pinnedConnLostError struct {
+		err error
+	}
+)
+
+// Pin acquires a connection from the pool of the executer and calls fn with a context holding it.
+// All transactions opened by the executer with this context run on the pinned connection, so
+// session level state like temporary tables, session variables or prepared statements persists
+// across them. The connection is returned to the pool after fn returned.
+// If ctx holds a pinned connection of the executer already, fn is called with ctx.
+// If the pinned connection breaks, transactions fail with [ErrPinnedConnLost] and are not retried.
+// Errors returned by the executed functions are retried as usual.
+func (executer Executer) Pin(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	if _, ok := executer.Conn(ctx); ok {
+		return fn(ctx) //nolint:wrapcheck // the error of fn is returned as is
+	}
+
+	conn, err := executer.db.Conn(ctx)
+	if err != nil {
+		return errors.Wrap(err, "acquiring sql conn")
+	}
+	defer func() {
+		err = multierr.Append(err, errors.Wrap(conn.Close(), "releasing sql conn"))
+	}()
+
+	//nolint:wrapcheck // the error of fn is returned as is
+	return fn(context.WithValue(ctx, pinnedConnKey{db: executer.db}, conn))
+}
+
+// Conn returns the connection pinned by [Executer.Pin] in ctx, eg to run session level
+// statements outside of transactions. It returns false if ctx holds no pinned connection of the
+// executer.
+func (executer Executer) Conn(ctx context.Context) (*sql.Conn, bool) {
+	conn, ok := ctx.Value(pinnedConnKey{db: executer.db}).(*sql.Conn)
+
+	return conn, ok
+}
+
+// Error implements the error interface.
+func (e *pinnedConnLostError) Error() string {
+	return ErrPinnedConnLost.Error() + ": " + e.err.Error()
+}
+
+// Unwrap returns the error of the transaction.
+func (e *pinnedConnLostError) Unwrap() error {
+	return e.err
+}
+
+// Is reports whether target is [ErrPinnedConnLost].
+func (e *pinnedConnLostError) Is(target error) bool {
+	return target == ErrPinnedConnLost //nolint:errorlint // sentinel comparison
+}
+
+// Retryable reports that the transaction must not be retried on the broken connection.
+func (e *pinnedConnLostError) Retryable() bool {
+	return false
+}
+
+// classifyPinnedError wraps err in a pinnedConnLostError if it indicates that the pinned
+// connection broke. Only the errors database/sql and drivers report for broken connections are
+// considered, as timeouts or network errors may be returned for connections that are still usable.
+func classifyPinnedError(err error) error {
+	if err == nil || (!errors.Is(err, driver.ErrBadConn) && !errors.Is(err, sql.ErrConnDone)) {
+		return err
+	}
+
+	return &pinnedConnLostError{err: err}
+}
@@ -0,0 +1,127 @@
+package sql_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/beeemT/go-atomic/generic"
+	gsql "github.com/beeemT/go-atomic/generic/sql"
+	"github.com/beeemT/go-atomic/internal/sqlitetest"
+)
+
+func TestPin(t *testing.T) {
+	executer := gsql.NewExecuter(sqlitetest.Open(t))
+	transacter := newTransacter(executer)
+
+	err := executer.Pin(context.Background(), func(ctx context.Context) error {
+		conn, ok := executer.Conn(ctx)
+		if !ok {
+			t.Fatal("expected pinned connection in context")
+		}
+
+		_, err := conn.ExecContext(ctx, "CREATE TEMP TABLE pinned (value INTEGER)")
+		if err != nil {
+			return err
+		}
+
+		for i := 0; i < 2; i++ {
+			err = transacter.Transact(ctx, func(ctx context.Context, tx generic.SQLRemote) error {
+				_, err := tx.ExecContext(ctx, "INSERT INTO pinned (value) VALUES (1)")
+
+				return err
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		var n int
+
+		err = conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM pinned").Scan(&n)
+		if err == nil && n != 2 {
+			t.Fatalf("expected 2 rows in temporary table, got %d", n)
+		}
+
+		return err
+	})
+	if err != nil {
+		t.Fatalf("pinning: %v", err)
+	}
+}
+
+func TestPinnedConnLost(t *testing.T) {
+	executer := gsql.NewExecuter(sqlitetest.Open(t))
+	transacter := newTransacter(executer)
+	attempts := 0
+
+	err := executer.Pin(context.Background(), func(ctx context.Context) error {
+		conn, _ := executer.Conn(ctx)
+		_ = conn.Raw(func(any) error {
+			return driver.ErrBadConn
+		})
+
+		return transacter.Transact(ctx, func(context.Context, generic.SQLRemote) error {
+			attempts++
+
+			return nil
+		})
+	})
+	if !errors.Is(err, gsql.ErrPinnedConnLost) {
+		t.Fatalf("expected ErrPinnedConnLost in chain, got %v", err)
+	}
+
+	if attempts != 0 {
+		t.Fatalf("expected no attempt on the broken connection, got %d", attempts)
+	}
+}
+
+func TestPinnedRunErrorsRetried(t *testing.T) {
+	for _, runErr := range []error{context.DeadlineExceeded, net.ErrClosed} {
+		t.Run(runErr.Error(), func(t *testing.T) {
+			executer := gsql.NewExecuter(sqlitetest.Open(t))
+			transacter := newTransacter(
+				executer,
+				generic.WithBackOffDelays[generic.SQLRemote, generic.SQLRemote](0),
+			)
+			attempts := 0
+
+			err := executer.Pin(context.Background(), func(ctx context.Context) error {
+				return transacter.Transact(ctx, func(context.Context, generic.SQLRemote) error {
+					attempts++
+					if attempts == 1 {
+						return runErr
+					}
+
+					return nil
+				})
+			})
+			if err != nil {
+				t.Fatalf("expected retry to succeed, got %v", err)
+			}
+
+			if attempts != 2 {
+				t.Fatalf("expected 2 attempts, got %d", attempts)
+			}
+		})
+	}
+}
+
+func newTransacter(
+	executer gsql.Executer,
+	opts ...generic.TransacterOption[generic.SQLRemote, generic.SQLRemote],
+) generic.Transacter[generic.SQLRemote, generic.SQLRemote] {
+	return generic.NewTransacter[generic.SQLRemote, generic.SQLRemote](
+		executer,
+		func(
+			_ context.Context,
+			_ *generic.Transacter[generic.SQLRemote, generic.SQLRemote],
+			tx generic.SQLRemote,
+		) (generic.SQLRemote, error) {
+			return tx, nil
+		},
+		opts...,
+	)
+}
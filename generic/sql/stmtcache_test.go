@@ -0,0 +1,148 @@
+package sql_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/beeemT/go-atomic/generic"
+	gsql "github.com/beeemT/go-atomic/generic/sql"
+	"github.com/beeemT/go-atomic/internal/sqlitetest"
+)
+
+// prepare prepares the queries on tx in order and returns the statements.
+func prepare(ctx context.Context, tx generic.SQLRemote, queries ...string) ([]*sql.Stmt, error) {
+	stmts := make([]*sql.Stmt, 0, len(queries))
+
+	for _, query := range queries {
+		stmt, err := tx.PrepareContext(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+
+		stmts = append(stmts, stmt)
+	}
+
+	return stmts, nil
+}
+
+func TestStmtCacheStats(t *testing.T) {
+	executer := gsql.NewExecuter(sqlitetest.Open(t), gsql.WithStmtCache(2))
+
+	for i := 0; i < 2; i++ {
+		err := executer.Execute(context.Background(), func(tx generic.SQLRemote) error {
+			_, err := prepare(context.Background(), tx, "SELECT 1", "SELECT 1")
+
+			return err
+		})
+		if err != nil {
+			t.Fatalf("executing: %v", err)
+		}
+	}
+
+	stats := executer.StmtCache().Stats()
+	expected := generic.StmtCacheStats{Hits: 3, Misses: 1, Size: 1}
+
+	if stats != expected {
+		t.Fatalf("expected %+v, got %+v", expected, stats)
+	}
+}
+
+func TestStmtCacheEviction(t *testing.T) {
+	ctx := context.Background()
+	executer := gsql.NewExecuter(sqlitetest.Open(t), gsql.WithStmtCache(2))
+
+	err := executer.Execute(ctx, func(tx generic.SQLRemote) error {
+		// SELECT 2 is the least recently used statement when SELECT 3 is prepared
+		stmts, err := prepare(ctx, tx, "SELECT 1", "SELECT 2", "SELECT 1", "SELECT 3")
+		if err != nil {
+			return err
+		}
+
+		stats := executer.StmtCache().Stats()
+		expected := generic.StmtCacheStats{Hits: 1, Misses: 3, Evictions: 1, Size: 2}
+
+		if stats != expected {
+			t.Fatalf("expected %+v, got %+v", expected, stats)
+		}
+
+		// the statement bound to the transaction stays usable after its eviction
+		var value int
+
+		err = stmts[1].QueryRowContext(ctx).Scan(&value)
+		if err == nil && value != 2 {
+			t.Fatalf("expected 2, got %d", value)
+		}
+
+		return err
+	})
+	if err != nil {
+		t.Fatalf("executing: %v", err)
+	}
+
+	err = executer.Execute(ctx, func(tx generic.SQLRemote) error {
+		_, err := prepare(ctx, tx, "SELECT 1", "SELECT 3", "SELECT 2")
+
+		return err
+	})
+	if err != nil {
+		t.Fatalf("executing: %v", err)
+	}
+
+	stats := executer.StmtCache().Stats()
+	expected := generic.StmtCacheStats{Hits: 3, Misses: 4, Evictions: 2, Size: 2}
+
+	if stats != expected {
+		t.Fatalf("expected %+v, got %+v", expected, stats)
+	}
+}
+
+func TestCachedStmt(t *testing.T) {
+	ctx := context.Background()
+
+	_, err := generic.CachedStmt(ctx, "SELECT 1")
+	if !errors.Is(err, generic.ErrNoSession) {
+		t.Fatalf("expected ErrNoSession without session, got %v", err)
+	}
+
+	err = newTransacter(gsql.NewExecuter(sqlitetest.Open(t))).Transact(
+		ctx,
+		func(ctx context.Context, _ generic.SQLRemote) error {
+			_, err := generic.CachedStmt(ctx, "SELECT 1")
+			if !errors.Is(err, generic.ErrNoStmtCache) {
+				t.Fatalf("expected ErrNoStmtCache without cache, got %v", err)
+			}
+
+			return nil
+		},
+	)
+	if err != nil {
+		t.Fatalf("transacting: %v", err)
+	}
+
+	executer := gsql.NewExecuter(sqlitetest.Open(t), gsql.WithStmtCache(2))
+	transacter := newTransacter(
+		executer,
+		generic.WithSessionGuard[generic.SQLRemote, generic.SQLRemote](generic.HookSQLRemote),
+	)
+
+	err = transacter.Transact(ctx, func(ctx context.Context, _ generic.SQLRemote) error {
+		// the cache is found behind the Remote wrapped by the session guard
+		stmt, err := generic.CachedStmt(ctx, "SELECT 1")
+		if err != nil {
+			return err
+		}
+
+		var value int
+
+		return stmt.QueryRowContext(ctx).Scan(&value)
+	})
+	if err != nil {
+		t.Fatalf("transacting: %v", err)
+	}
+
+	if stats := executer.StmtCache().Stats(); stats.Misses != 1 {
+		t.Fatalf("expected statement to be prepared by the cache, got %+v", stats)
+	}
+}
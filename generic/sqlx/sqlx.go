@@ -5,7 +5,10 @@ import (
 	"context"
 	"database/sql"
 
+	"github.com/beeemT/go-atomic"
 	"github.com/beeemT/go-atomic/generic"
+	"github.com/beeemT/go-atomic/generic/adapter"
+	"github.com/beeemT/go-atomic/internal/sqlgen"
 	"github.com/jmoiron/sqlx"
 	"github.com/pkg/errors"
 	"go.uber.org/multierr"
@@ -19,8 +22,10 @@ var (
 type (
 	// Executer implements the [generic.Executer] interface for a sqlx db
 	Executer struct {
-		db     *sqlx.DB
-		txOpts *sql.TxOptions
+		db      *sqlx.DB
+		txOpts  *sql.TxOptions
+		dialect adapter.Dialect
+		stmts   *generic.StmtCache
 	}
 
 	// ExecuterOption configures the [Executer] instance
@@ -34,11 +39,21 @@ func WithTxOptions(opts *sql.TxOptions) ExecuterOption {
 	}
 }
 
+// WithDialect sets the dialect of the database, which determines the statements applying the
+// [generic.Timeouts] of the context. Defaults to the dialect inferred from the driver of the
+// database with [adapter.DriverDialect]. Timeouts are not applied if the dialect is unknown.
+func WithDialect(dialect adapter.Dialect) ExecuterOption {
+	return func(e *Executer) {
+		e.dialect = dialect
+	}
+}
+
 // NewExecuter creates a new Executer
 func NewExecuter(db *sqlx.DB, opts ...ExecuterOption) Executer {
 	executer := Executer{
-		db:     db,
-		txOpts: &sql.TxOptions{},
+		db:      db,
+		txOpts:  &sql.TxOptions{},
+		dialect: adapter.DriverDialect(db.Driver()),
 	}
 
 	for _, opt := range opts {
@@ -48,16 +63,42 @@ func NewExecuter(db *sqlx.DB, opts ...ExecuterOption) Executer {
 	return executer
 }
 
-// Execute executes the provided function in a transaction
+// Execute executes the provided function in a transaction.
+// The [generic.Timeouts] of ctx are applied to the transaction, errors caused by them are returned
+// as [atomic.TimeoutError]. Panics in run roll back the transaction.
 func (executer Executer) Execute(ctx context.Context, run func(generic.SQLXRemote) error) error {
 	tx, err := executer.db.BeginTxx(ctx, executer.txOpts)
 	if err != nil {
 		return errors.Wrap(err, "opening sqlx tx")
 	}
 
-	err = run(tx)
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+
+			panic(p)
+		}
+	}()
+
+	timeouts, _ := generic.TimeoutsFromContext(ctx)
+
+	err = executeAll(ctx, tx, sqlgen.SetTimeouts(executer.dialect, timeouts))
+	if err == nil {
+		err = errors.Wrap(
+			atomic.ClassifyTimeoutError(run(executer.remote(tx))),
+			"executing run",
+		)
+	}
+
+	err = multierr.Append(
+		err,
+		executeAll(
+			context.WithoutCancel(ctx),
+			tx,
+			sqlgen.ResetTimeouts(executer.dialect, timeouts),
+		),
+	)
 	if err != nil {
-		err = errors.Wrap(err, "executing run")
 		innerErr := tx.Rollback()
 		if innerErr != nil {
 			return multierr.Append( //nolint:wrapcheck //individual errors are wrapped
@@ -71,7 +112,22 @@ func (executer Executer) Execute(ctx context.Context, run func(generic.SQLXRemot
 
 	err = tx.Commit()
 	if err != nil {
-		return errors.Wrap(err, "committing sqlx tx")
+		return errors.Wrap(
+			atomic.ClassifyCommitError(atomic.ClassifyTimeoutError(err)),
+			"committing sqlx tx",
+		)
+	}
+
+	return nil
+}
+
+// executeAll executes statements in tx.
+func executeAll(ctx context.Context, tx *sqlx.Tx, statements []string) error {
+	for _, statement := range statements {
+		_, err := tx.ExecContext(ctx, statement)
+		if err != nil {
+			return errors.Wrap(err, statement)
+		}
 	}
 
 	return nil
@@ -0,0 +1,42 @@
+package sqlx_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/beeemT/go-atomic/generic"
+	"github.com/beeemT/go-atomic/generic/executortest"
+	gsqlx "github.com/beeemT/go-atomic/generic/sqlx"
+	"github.com/beeemT/go-atomic/internal/sqlitetest"
+	"github.com/jmoiron/sqlx"
+)
+
+func TestConformance(t *testing.T) {
+	executortest.Run(t, func(t *testing.T) executortest.Harness[generic.SQLXRemote] {
+		db := sqlx.NewDb(
+			sqlitetest.Open(t, "CREATE TABLE markers (value TEXT NOT NULL)"), "sqlite3",
+		)
+
+		return executortest.Harness[generic.SQLXRemote]{
+			Executer: gsqlx.NewExecuter(db),
+			Insert: func(ctx context.Context, tx generic.SQLXRemote, value string) error {
+				_, err := tx.NamedExecContext(
+					ctx,
+					"INSERT INTO markers (value) VALUES (:value)",
+					map[string]any{"value": value},
+				)
+
+				return err
+			},
+			Exists: func(ctx context.Context, value string) (bool, error) {
+				var count int
+
+				err := db.GetContext(
+					ctx, &count, db.Rebind("SELECT COUNT(*) FROM markers WHERE value = ?"), value,
+				)
+
+				return count > 0, err
+			},
+		}
+	})
+}
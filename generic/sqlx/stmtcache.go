@@ -0,0 +1,71 @@
+package sqlx
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/beeemT/go-atomic/generic"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	_ generic.SQLXRemote   = (*CachingTx)(nil)
+	_ generic.CachedStmter = (*CachingTx)(nil)
+)
+
+// CachingTx is the Remote passed by executers configured with [WithStmtCache]. Its PrepareContext
+// and PreparexContext return statements bound to the transaction from the statement cache of the
+// executer, so statements are prepared once per pool instead of in every transaction.
+// Statements returned by them are closed when the transaction ends.
+type CachingTx struct {
+	*sqlx.Tx
+
+	cache *generic.StmtCache
+}
+
+// WithStmtCache enables a statement cache holding up to capacity statements prepared on the
+// pool of the executer. The Remote passed to executed functions is a [*CachingTx] then.
+// A capacity of 0 disables eviction.
+func WithStmtCache(capacity int) ExecuterOption {
+	return func(e *Executer) {
+		e.stmts = generic.NewStmtCache(e.db.DB, capacity)
+	}
+}
+
+// StmtCache returns the statement cache of the executer, eg to read its metrics. It is nil unless
+// the executer was configured with [WithStmtCache].
+func (executer Executer) StmtCache() *generic.StmtCache {
+	return executer.stmts
+}
+
+// PrepareContext returns a statement for query bound to the transaction from the statement cache.
+func (tx *CachingTx) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return tx.CachedStmtContext(ctx, query)
+}
+
+// PreparexContext returns a statement for query bound to the transaction from the statement
+// cache.
+func (tx *CachingTx) PreparexContext(ctx context.Context, query string) (*sqlx.Stmt, error) {
+	stmt, err := tx.CachedStmtContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sqlx.Stmt{Stmt: stmt, Mapper: tx.Mapper}, nil
+}
+
+// CachedStmtContext returns a statement for query bound to the transaction from the statement
+// cache.
+func (tx *CachingTx) CachedStmtContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	//nolint:wrapcheck // errors are wrapped by the cache
+	return tx.cache.TxStmt(ctx, tx.Tx.Tx, query)
+}
+
+// remote returns the Remote passed to the executed functions for tx.
+func (executer Executer) remote(tx *sqlx.Tx) generic.SQLXRemote {
+	if executer.stmts == nil {
+		return tx
+	}
+
+	return &CachingTx{Tx: tx, cache: executer.stmts}
+}
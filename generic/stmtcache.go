@@ -0,0 +1,212 @@
+package generic
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"sync"
+
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+
+	"github.com/beeemT/go-atomic"
+)
+
+// ErrNoStmtCache is returned by [CachedStmt] if the Remote of the session in the context has no
+// statement cache.
+var ErrNoStmtCache = errors.New("no statement cache")
+
+type (
+	// StmtCache caches statements prepared on the pool of a database and hands out statements
+	// bound to transactions, so statements are not prepared again in every transaction.
+	// The least recently used statement is closed when the capacity is exceeded. Statements bound
+	// to transactions stay usable until their transaction ends, also if their cached statement is
+	// evicted, as database/sql defers closing the prepared statement of the driver until the
+	// statements bound to transactions are closed. StmtCache is safe for concurrent use.
+	StmtCache struct {
+		db       *sql.DB
+		capacity int
+
+		mu      sync.Mutex
+		entries map[string]*list.Element
+		lru     *list.List
+		stats   StmtCacheStats
+	}
+
+	// StmtCacheStats are the metrics of a [StmtCache].
+	StmtCacheStats struct {
+		// Hits is the number of statements served from the cache.
+		Hits uint64
+		// Misses is the number of statements prepared because they were not cached.
+		Misses uint64
+		// Evictions is the number of statements closed because the capacity was exceeded.
+		Evictions uint64
+		// Size is the number of cached statements.
+		Size int
+	}
+
+	// CachedStmter is implemented by the Remotes passed by executers with a statement cache, eg
+	// the sql and sqlx executers configured with WithStmtCache.
+	CachedStmter interface {
+		// CachedStmtContext returns a statement for query bound to the transaction of the
+		// Remote, prepared from the statement cache of the executer.
+		CachedStmtContext(ctx context.Context, query string) (*sql.Stmt, error)
+	}
+
+	stmtCacheEntry struct {
+		query string
+		stmt  *sql.Stmt
+		// users is the number of calls of TxStmt binding the statement to a transaction. A closed
+		// statement cannot be bound, so it is not closed while it is used.
+		users   int
+		evicted bool
+	}
+)
+
+// NewStmtCache creates a new StmtCache preparing statements on db and caching up to capacity
+// statements.
+func NewStmtCache(db *sql.DB, capacity int) *StmtCache {
+	return &StmtCache{
+		db:       db,
+		capacity: capacity,
+		entries:  map[string]*list.Element{},
+		lru:      list.New(),
+	}
+}
+
+// TxStmt returns a statement for query bound to tx. The statement is prepared on the pool and
+// cached unless it was cached already.
+func (cache *StmtCache) TxStmt(ctx context.Context, tx *sql.Tx, query string) (*sql.Stmt, error) {
+	entry, err := cache.acquire(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer cache.release(entry)
+
+	return tx.StmtContext(ctx, entry.stmt), nil
+}
+
+// Stats returns the metrics of the cache.
+func (cache *StmtCache) Stats() StmtCacheStats {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	stats := cache.stats
+	stats.Size = cache.lru.Len()
+
+	return stats
+}
+
+// Close closes and removes all cached statements. Statements in use are closed once released.
+func (cache *StmtCache) Close() error {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	var err error
+
+	for element := cache.lru.Front(); element != nil; element = element.Next() {
+		entry, _ := element.Value.(*stmtCacheEntry)
+		entry.evicted = true
+
+		if entry.users == 0 {
+			err = multierr.Append(err, errors.Wrapf(entry.stmt.Close(), "closing %s", entry.query))
+		}
+	}
+
+	cache.entries = map[string]*list.Element{}
+	cache.lru.Init()
+
+	return err
+}
+
+// acquire returns the cache entry of query, preparing its statement on a miss. The statement is
+// not closed by evictions until the entry is released.
+func (cache *StmtCache) acquire(ctx context.Context, query string) (*stmtCacheEntry, error) {
+	cache.mu.Lock()
+
+	if element, ok := cache.entries[query]; ok {
+		defer cache.mu.Unlock()
+
+		cache.stats.Hits++
+
+		return cache.use(element), nil
+	}
+
+	cache.stats.Misses++
+	cache.mu.Unlock()
+
+	stmt, err := cache.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, errors.Wrap(err, "preparing cached statement")
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if element, ok := cache.entries[query]; ok {
+		// prepared concurrently
+		_ = stmt.Close()
+
+		return cache.use(element), nil
+	}
+
+	element := cache.lru.PushFront(&stmtCacheEntry{query: query, stmt: stmt})
+	cache.entries[query] = element
+	entry := cache.use(element)
+
+	for cache.capacity > 0 && cache.lru.Len() > cache.capacity {
+		evicted, _ := cache.lru.Remove(cache.lru.Back()).(*stmtCacheEntry)
+		delete(cache.entries, evicted.query)
+
+		evicted.evicted = true
+		cache.stats.Evictions++
+
+		if evicted.users == 0 {
+			// statements bound to transactions already stay usable, database/sql closes the
+			// prepared statement of the driver once they are closed
+			_ = evicted.stmt.Close()
+		}
+	}
+
+	return entry, nil
+}
+
+// use marks the entry of element as used and returns it. The lock has to be held.
+func (cache *StmtCache) use(element *list.Element) *stmtCacheEntry {
+	cache.lru.MoveToFront(element)
+
+	entry, _ := element.Value.(*stmtCacheEntry)
+	entry.users++
+
+	return entry
+}
+
+// release releases an entry returned by acquire, closing its statement if it was evicted.
+func (cache *StmtCache) release(entry *stmtCacheEntry) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	entry.users--
+
+	if entry.evicted && entry.users == 0 {
+		_ = entry.stmt.Close()
+	}
+}
+
+// CachedStmt returns a statement for query bound to the transaction of the session in ctx,
+// prepared from the statement cache of the executer. It returns [ErrNoSession] if ctx holds no
+// session and [ErrNoStmtCache] if the Remote of the session has no statement cache.
+func CachedStmt(ctx context.Context, query string) (*sql.Stmt, error) {
+	session, ok := ctx.Value(atomic.SessionContextKey).(interface{ remote() any })
+	if !ok {
+		return nil, ErrNoSession
+	}
+
+	for remote := session.remote(); remote != nil; remote, _ = UnwrapRemote(remote) {
+		if stmter, ok := remote.(CachedStmter); ok {
+			return stmter.CachedStmtContext(ctx, query) //nolint:wrapcheck // wrapped by stmter
+		}
+	}
+
+	return nil, ErrNoStmtCache
+}
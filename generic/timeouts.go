@@ -0,0 +1,39 @@
+package generic
+
+import (
+	"context"
+	"time"
+)
+
+type (
+	// Timeouts are the database timeouts of a transaction. Zero values keep the timeouts configured
+	// on the database. They are applied by the sql, sqlx and crdb executors at the beginning of
+	// every transaction opened with a context returned by [WithTimeouts].
+	Timeouts struct {
+		// Statement limits the duration of every statement of the transaction, ie
+		// statement_timeout on Postgres and max_execution_time (SELECT statements only) on MySQL.
+		Statement time.Duration
+		// Lock limits the time statements wait for locks, ie lock_timeout on Postgres and
+		// innodb_lock_wait_timeout (rounded up to seconds) on MySQL.
+		Lock time.Duration
+		// IdleInTransaction limits the time the transaction may idle between statements, ie
+		// idle_in_transaction_session_timeout on Postgres. It is not supported on MySQL.
+		IdleInTransaction time.Duration
+	}
+
+	timeoutsContextKey struct{}
+)
+
+// WithTimeouts returns a context applying timeouts to the transactions opened with it.
+// Errors caused by elapsed timeouts are returned as [atomic.TimeoutError] by the executors.
+func WithTimeouts(ctx context.Context, timeouts Timeouts) context.Context {
+	return context.WithValue(ctx, timeoutsContextKey{}, timeouts)
+}
+
+// TimeoutsFromContext returns the timeouts set with [WithTimeouts].
+// It returns false if ctx does not hold timeouts.
+func TimeoutsFromContext(ctx context.Context) (Timeouts, bool) {
+	timeouts, ok := ctx.Value(timeoutsContextKey{}).(Timeouts)
+
+	return timeouts, ok
+}
@@ -5,13 +5,19 @@ package generic
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
+	"go.uber.org/multierr"
 
 	"github.com/beeemT/go-atomic"
 )
 
+// ErrNoSession is returned by helpers needing the session of a [Transacter] if the context holds
+// no session, ie they are called outside of the run function of Transact.
+var ErrNoSession = errors.New("no session in context")
+
 type (
 	// Transacter implements the Transacter interface for sqlx compatible databases.
 	// It flattens statements on nested uses of the Transact method into one sqlx transaction.
@@ -27,12 +33,33 @@ type (
 		retry func(backoffs []time.Duration, run func() error) error
 
 		backoffs []time.Duration
+
+		verifyCommit func(ctx context.Context) (bool, error)
+
+		replicas *replicaSet[Remote]
+
+		initializers []func(ctx context.Context, tx Remote) error
+
+		clock atomic.Clock
+
+		guard func(Remote, StatementHook) Remote
+
+		// resourcesKey identifies the resources of the transacter cached in sessions, it is nil
+		// unless [WithSessionResources] is set.
+		resourcesKey *resourcesKey
 	}
 
 	// Session models all info passed from transacter through context to other nested
 	// Transact calls.
 	Session[Remote any] struct {
 		Tx Remote
+
+		deferred []func(context.Context) error
+
+		// resourcesMu guards resources, as nested calls of Transact may run concurrently in
+		// goroutines started by run.
+		resourcesMu sync.Mutex
+		resources   map[*resourcesKey]any
 	}
 
 	// Executer models the handler for the remote specific transaction logic.
@@ -49,6 +76,11 @@ type (
 
 	// TransacterOption is used to configure a Transacter.
 	TransacterOption[Remote any, Resources any] func(*Transacter[Remote, Resources])
+
+	// resourcesKey is not zero sized, so pointers to different keys differ.
+	resourcesKey struct {
+		_ byte
+	}
 )
 
 var _ atomic.Transacter[struct{}] = (*Transacter[struct{}, struct{}])(nil)
@@ -60,8 +92,10 @@ var _ atomic.Transacter[struct{}] = (*Transacter[struct{}, struct{}])(nil)
 // as it permits the use of eg repositories with both sql.DB and sql.Tx.
 //
 // By default sets:
-//   - [atomic.DefaultRetry] as the retry function.
+//   - [atomic.DefaultRetry] as the retry function, or [atomic.RetryWithClock] if a clock is set
+//     with [WithClock].
 //   - [atomic.DefaultBackoffs] as the backoffs to use on retry.
+//   - [atomic.SystemClock] as the clock.
 //
 // createResources is supposed to do any setup or new instantiation of members of Resources, ie
 // create new repositories using the provided Remote.
@@ -78,7 +112,6 @@ func NewTransacter[Remote any, Resources any](
 	transacter := Transacter[Remote, Resources]{
 		executer:        executer,
 		createResources: createResources,
-		retry:           atomic.DefaultRetry,
 		backoffs:        atomic.DefaultBackoffs,
 	}
 
@@ -86,9 +119,59 @@ func NewTransacter[Remote any, Resources any](
 		opt(&transacter)
 	}
 
+	switch {
+	case transacter.retry != nil:
+	case transacter.clock != nil:
+		transacter.retry = atomic.RetryWithClock(transacter.clock)
+	default:
+		transacter.retry = atomic.DefaultRetry
+	}
+
+	if transacter.clock == nil {
+		transacter.clock = atomic.SystemClock{}
+	}
+
 	return transacter
 }
 
+// Clock returns the clock of the transacter, eg to pass it to repositories in createResources.
+func (transacter Transacter[Remote, Resources]) Clock() atomic.Clock {
+	return transacter.clock
+}
+
+// SessionFromContext returns the session inserted into ctx by [Transacter.Transact].
+// It returns false if ctx does not contain a session for Remote.
+func SessionFromContext[Remote any](ctx context.Context) (*Session[Remote], bool) {
+	session, ok := ctx.Value(atomic.SessionContextKey).(*Session[Remote])
+
+	return session, ok
+}
+
+// RequireSession returns the session inserted into ctx by [Transacter.Transact].
+// It returns [ErrNoSession] if ctx does not contain a session for Remote.
+func RequireSession[Remote any](ctx context.Context) (*Session[Remote], error) {
+	session, ok := SessionFromContext[Remote](ctx)
+	if !ok {
+		return nil, ErrNoSession
+	}
+
+	return session, nil
+}
+
+// Defer registers fn to be called when the outermost Transact call of the session returns from
+// its run function, before the transaction is committed or rolled back. Deferred functions are
+// called in reverse order of registration, also if run failed, and can still use Tx.
+// Errors returned from deferred functions fail the transaction.
+// Defer is not safe for concurrent use.
+func (session *Session[Remote]) Defer(fn func(context.Context) error) {
+	session.deferred = append(session.deferred, fn)
+}
+
+// remote returns the Remote of the session without its type parameter.
+func (session *Session[Remote]) remote() any {
+	return session.Tx
+}
+
 // Transact will run run in a sqlx Session.
 // If a session is present in ctx at [atomic.SessionContextKey] it will use the existing session,
 // else it will create a new session and insert it into the context.
@@ -98,6 +181,8 @@ func NewTransacter[Remote any, Resources any](
 // the transacter to produce the resources for calling run.
 // If an error is returned from run the outermost call of Transact will handle the error with the
 // provided retry function.
+// New sessions for contexts marked with [ReadOnly] are opened on a read replica if the transacter
+// was configured with [WithReadReplicas].
 func (transacter Transacter[Remote, Resources]) Transact(
 	ctx context.Context,
 	run func(context.Context, Resources) error,
@@ -109,9 +194,9 @@ func (transacter Transacter[Remote, Resources]) Transact(
 			transacter.retry(
 				transacter.backoffs,
 				func() error {
-					return transacter.executer.Execute( //nolint:wrapcheck //done outside closure
+					return transacter.verified(
 						ctx,
-						transacter.inSession(ctx, run),
+						transacter.execute(ctx, run),
 					)
 				}),
 			"new transaction",
@@ -123,7 +208,7 @@ func (transacter Transacter[Remote, Resources]) Transact(
 		}
 
 		err = errors.Wrap(
-			transacter.inSession(ctx, run)(s.Tx),
+			transacter.inSession(ctx, s, run),
 			"using transaction from context",
 		)
 	}
@@ -131,29 +216,128 @@ func (transacter Transacter[Remote, Resources]) Transact(
 	return errors.Wrap(err, "running transaction")
 }
 
-func (transacter *Transacter[Remote, Resources]) inSession(
+// verified checks the outcome of commits that failed with [atomic.ErrCommitUnknown] using the
+// configured commit verifier.
+func (transacter *Transacter[Remote, Resources]) verified(ctx context.Context, err error) error {
+	var unknown *atomic.CommitUnknownError
+	if transacter.verifyCommit == nil || !errors.As(err, &unknown) {
+		return err
+	}
+
+	committed, verifyErr := transacter.verifyCommit(ctx)
+	switch {
+	case verifyErr != nil:
+		return multierr.Append( //nolint:wrapcheck //individual errors are wrapped
+			err,
+			errors.Wrap(verifyErr, "verifying commit"),
+		)
+	case committed:
+		return nil
+	default:
+		return errors.Wrap(unknown.Err, "commit verified as not applied")
+	}
+}
+
+// newSession returns the function executed by the executer, which runs run in a new session for
+// the transaction opened by the executer.
+func (transacter *Transacter[Remote, Resources]) newSession(
 	ctx context.Context,
 	run func(context.Context, Resources) error,
 ) func(Remote) error {
 	return func(tx Remote) error {
-		ctx = context.WithValue(
-			ctx,
-			atomic.SessionContextKey,
-			&Session[Remote]{
-				Tx: tx,
-			},
-		)
+		session := &Session[Remote]{
+			Tx: tx,
+		}
 
-		registry, err := transacter.createResources(ctx, transacter, tx)
-		if err != nil {
-			return fmt.Errorf("creating registry: %w", err)
+		if transacter.guard != nil {
+			guard := newSessionGuard()
+			defer guard.close()
+
+			session.Tx = transacter.guard(tx, guard.hook)
 		}
 
-		err = run(ctx, registry)
-		if err != nil {
-			return fmt.Errorf("executing run: %w", err)
+		for _, initialize := range transacter.initializers {
+			err := initialize(ctx, tx)
+			if err != nil {
+				return fmt.Errorf("initializing session: %w", err)
+			}
 		}
 
-		return nil
+		err := transacter.inSession(ctx, session, run)
+
+		sessionCtx := context.WithValue(ctx, atomic.SessionContextKey, session)
+		for i := len(session.deferred) - 1; i >= 0; i-- {
+			deferredErr := session.deferred[i](sessionCtx)
+			if deferredErr != nil {
+				err = multierr.Append(
+					err,
+					fmt.Errorf("executing deferred function: %w", deferredErr),
+				)
+			}
+		}
+
+		return err
+	}
+}
+
+func (transacter *Transacter[Remote, Resources]) inSession(
+	ctx context.Context,
+	session *Session[Remote],
+	run func(context.Context, Resources) error,
+) error {
+	ctx = context.WithValue(ctx, atomic.SessionContextKey, session)
+
+	registry, err := transacter.resources(ctx, session)
+	if err != nil {
+		return fmt.Errorf("creating registry: %w", err)
+	}
+
+	err = run(ctx, registry)
+	if err != nil {
+		return fmt.Errorf("executing run: %w", err)
 	}
+
+	return nil
+}
+
+// resources returns the resources of the transacter for session. They are created once per
+// session if the transacter was configured with [WithSessionResources].
+func (transacter *Transacter[Remote, Resources]) resources(
+	ctx context.Context,
+	session *Session[Remote],
+) (Resources, error) {
+	if transacter.resourcesKey == nil {
+		return transacter.createResources(ctx, transacter, session.Tx)
+	}
+
+	session.resourcesMu.Lock()
+	cached, ok := session.resources[transacter.resourcesKey].(Resources)
+	session.resourcesMu.Unlock()
+
+	if ok {
+		return cached, nil
+	}
+
+	// the lock is not held while creating the resources, as createResources may call other
+	// transacters in the same session
+	resources, err := transacter.createResources(ctx, transacter, session.Tx)
+	if err != nil {
+		return resources, err
+	}
+
+	session.resourcesMu.Lock()
+	defer session.resourcesMu.Unlock()
+
+	// resources created concurrently by another nested call are kept, so all calls share them
+	if cached, ok := session.resources[transacter.resourcesKey].(Resources); ok {
+		return cached, nil
+	}
+
+	if session.resources == nil {
+		session.resources = map[*resourcesKey]any{}
+	}
+
+	session.resources[transacter.resourcesKey] = resources
+
+	return resources, nil
 }
@@ -0,0 +1,256 @@
+package generic_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	goatomic "github.com/beeemT/go-atomic"
+	"github.com/beeemT/go-atomic/atomictest"
+	"github.com/beeemT/go-atomic/generic"
+)
+
+// sessionResources are the Resources of the session resources tests, every instance is numbered
+// by its creation.
+type sessionResources struct {
+	n int32
+}
+
+func newSessionTransacter(
+	created *atomic.Int32,
+	opts ...generic.TransacterOption[struct{}, *sessionResources],
+) generic.Transacter[struct{}, *sessionResources] {
+	return generic.NewTransacter[struct{}, *sessionResources](
+		atomictest.NewExecuter(struct{}{}),
+		func(
+			context.Context,
+			*generic.Transacter[struct{}, *sessionResources],
+			struct{},
+		) (*sessionResources, error) {
+			return &sessionResources{n: created.Add(1)}, nil
+		},
+		append(opts, generic.WithSessionResources[struct{}, *sessionResources]())...,
+	)
+}
+
+func TestSessionResourcesNested(t *testing.T) {
+	var created atomic.Int32
+
+	transacter := newSessionTransacter(&created)
+
+	err := transacter.Transact(
+		context.Background(),
+		func(ctx context.Context, outer *sessionResources) error {
+			return transacter.Transact(ctx, func(_ context.Context, inner *sessionResources) error {
+				if inner != outer {
+					t.Errorf("expected nested call to reuse resources %d, got %d", outer.n, inner.n)
+				}
+
+				return nil
+			})
+		},
+	)
+	if err != nil {
+		t.Fatalf("transacting: %v", err)
+	}
+
+	if created.Load() != 1 {
+		t.Fatalf("expected resources to be created once, got %d", created.Load())
+	}
+}
+
+// TestSessionResourcesConcurrentNested checks that nested calls of another transacter running
+// concurrently in the same session share its resources.
+func TestSessionResourcesConcurrentNested(t *testing.T) {
+	var created, otherCreated atomic.Int32
+
+	transacter := newSessionTransacter(&created)
+	other := newSessionTransacter(&otherCreated)
+
+	var (
+		mu    sync.Mutex
+		inner = map[*sessionResources]bool{}
+	)
+
+	err := transacter.Transact(
+		context.Background(),
+		func(ctx context.Context, _ *sessionResources) error {
+			var wg sync.WaitGroup
+
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+
+				go func() {
+					defer wg.Done()
+
+					err := other.Transact(ctx, func(_ context.Context, r *sessionResources) error {
+						mu.Lock()
+						defer mu.Unlock()
+
+						inner[r] = true
+
+						return nil
+					})
+					if err != nil {
+						t.Errorf("transacting: %v", err)
+					}
+				}()
+			}
+
+			wg.Wait()
+
+			return nil
+		},
+	)
+	if err != nil {
+		t.Fatalf("transacting: %v", err)
+	}
+
+	if len(inner) != 1 {
+		t.Fatalf("expected nested calls to share resources, got %d different ones", len(inner))
+	}
+}
+
+func TestSessionResourcesRetry(t *testing.T) {
+	var (
+		created  atomic.Int32
+		attempts []*sessionResources
+	)
+
+	transacter := newSessionTransacter(
+		&created,
+		generic.WithBackOffDelays[struct{}, *sessionResources](0),
+	)
+
+	err := transacter.Transact(
+		context.Background(),
+		func(_ context.Context, resources *sessionResources) error {
+			attempts = append(attempts, resources)
+			if len(attempts) == 1 {
+				return context.DeadlineExceeded
+			}
+
+			return nil
+		},
+	)
+	if err != nil {
+		t.Fatalf("transacting: %v", err)
+	}
+
+	if len(attempts) != 2 || attempts[0] == attempts[1] {
+		t.Fatalf("expected new resources for the retry, got %+v", attempts)
+	}
+}
+
+// TestRetryUsesClock checks that the backoffs between retries are awaited with the clock of the
+// transacter instead of sleeping.
+func TestRetryUsesClock(t *testing.T) {
+	start := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := atomictest.NewClock(start, atomictest.WithAutoAdvance())
+	executer := atomictest.NewExecuter(struct{}{})
+	transacter := generic.NewTransacter[struct{}, struct{}](
+		executer,
+		func(context.Context, *generic.Transacter[struct{}, struct{}], struct{}) (struct{}, error) {
+			return struct{}{}, nil
+		},
+		generic.WithClock[struct{}, struct{}](clock),
+	)
+
+	var total time.Duration
+
+	for _, backoff := range goatomic.DefaultBackoffs {
+		total += backoff
+
+		executer.FailRun(context.DeadlineExceeded)
+	}
+
+	began := time.Now()
+
+	err := transacter.Transact(context.Background(), func(context.Context, struct{}) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("transacting: %v", err)
+	}
+
+	if elapsed := time.Since(began); elapsed >= goatomic.DefaultBackoffs[0] {
+		t.Fatalf("expected retries not to sleep, took %v", elapsed)
+	}
+
+	if attempts := len(executer.Executions()); attempts != len(goatomic.DefaultBackoffs)+1 {
+		t.Fatalf("expected %d attempts, got %d", len(goatomic.DefaultBackoffs)+1, attempts)
+	}
+
+	if advanced := clock.Now().Sub(start); advanced != total {
+		t.Fatalf("expected clock to advance by the backoffs %v, got %v", total, advanced)
+	}
+}
+
+func TestCommitVerifier(t *testing.T) {
+	errVerify := errors.New("verification failed")
+
+	for _, tc := range []struct {
+		name       string
+		verify     func(context.Context) (bool, error)
+		executions int
+		unknown    bool
+		err        error
+	}{
+		{
+			name:       "committed",
+			verify:     func(context.Context) (bool, error) { return true, nil },
+			executions: 1,
+		},
+		{
+			name:       "not committed",
+			verify:     func(context.Context) (bool, error) { return false, nil },
+			executions: 2,
+		},
+		{
+			name:       "verifier error",
+			verify:     func(context.Context) (bool, error) { return false, errVerify },
+			executions: 1,
+			unknown:    true,
+			err:        errVerify,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			executer := atomictest.NewExecuter(struct{}{})
+			executer.FailCommit(&goatomic.CommitUnknownError{Err: net.ErrClosed})
+
+			transacter := generic.NewTransacter[struct{}, struct{}](
+				executer,
+				func(
+					context.Context,
+					*generic.Transacter[struct{}, struct{}],
+					struct{},
+				) (struct{}, error) {
+					return struct{}{}, nil
+				},
+				generic.WithCommitVerifier[struct{}, struct{}](tc.verify),
+				generic.WithBackOffDelays[struct{}, struct{}](0),
+			)
+
+			err := transacter.Transact(context.Background(), func(context.Context, struct{}) error {
+				return nil
+			})
+
+			switch {
+			case tc.err == nil && err != nil:
+				t.Fatalf("expected transaction to succeed, got %v", err)
+			case tc.err != nil && !errors.Is(err, tc.err):
+				t.Fatalf("expected %v, got %v", tc.err, err)
+			case errors.Is(err, goatomic.ErrCommitUnknown) != tc.unknown:
+				t.Fatalf("expected ErrCommitUnknown only if verification failed, got %v", err)
+			}
+
+			if executions := len(executer.Executions()); executions != tc.executions {
+				t.Fatalf("expected %d executions, got %d", tc.executions, executions)
+			}
+		})
+	}
+}
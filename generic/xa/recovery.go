@@ -0,0 +1,275 @@
+package xa
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/beeemT/go-atomic/generic/adapter"
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+)
+
+type logEntry struct {
+	status    string
+	createdAt time.Time
+}
+
+// Recover resolves the in doubt transactions of all participants, which were left prepared by a
+// crashed coordinator or failed commits.
+// Transactions with a logged commit decision are committed. Transactions without commit decision
+// are rolled back once their log entry is older than the grace period or if their log entry was
+// deleted, which prevents their coordinator from logging a commit decision. Log entries of
+// resolved transactions are deleted.
+func (executer Executer) Recover(ctx context.Context) error {
+	if executer.err != nil {
+		return executer.err
+	}
+
+	entries, err := executer.logEntries(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := executer.clock.Now().UTC()
+	unresolved := make(map[string]bool)
+	aborted := make(map[string]bool)
+
+	for _, participant := range executer.participants {
+		gids, err := executer.inDoubt(ctx, participant)
+		if err != nil {
+			return errors.Wrapf(
+				err,
+				"listing in doubt transactions of participant %s",
+				participant.name,
+			)
+		}
+
+		for _, gid := range gids {
+			txID, _ := executer.txID(gid, participant)
+
+			entry, logged := entries[txID]
+			if !logged {
+				// the transaction might have logged its prepare after the log was read. A gid
+				// is only prepared after its log entry was inserted, so an entry missing now
+				// was deleted and a commit decision can not be logged anymore.
+				entry, logged, err = executer.logEntry(ctx, txID)
+				if err != nil {
+					unresolved[txID] = true
+					executer.onError(err)
+
+					continue
+				}
+			}
+
+			switch {
+			case logged && entry.status == statusCommitting:
+				err = executer.resolve(ctx, participant, gid, true)
+			case logged && now.Sub(entry.createdAt) < executer.gracePeriod:
+				unresolved[txID] = true
+
+				continue
+			case logged && !aborted[txID]:
+				aborted[txID], err = executer.abort(ctx, txID)
+				if err == nil && !aborted[txID] {
+					// the coordinator logged its commit decision in the meantime
+					unresolved[txID] = true
+
+					continue
+				}
+
+				if err == nil {
+					err = executer.resolve(ctx, participant, gid, false)
+				}
+			default:
+				err = executer.resolve(ctx, participant, gid, false)
+			}
+
+			if err != nil {
+				unresolved[txID] = true
+				executer.onError(err)
+			}
+		}
+	}
+
+	var cleanupErr error
+
+	for txID, entry := range entries {
+		if unresolved[txID] || aborted[txID] || now.Sub(entry.createdAt) < executer.gracePeriod {
+			continue
+		}
+
+		cleanupErr = multierr.Append(cleanupErr, executer.forget(ctx, txID))
+	}
+
+	return errors.Wrap(cleanupErr, "cleaning up coordinator log")
+}
+
+// abort deletes the log entry of a transaction without commit decision, so its coordinator can
+// not log a commit decision anymore. It returns false if the commit decision was logged already.
+func (executer Executer) abort(ctx context.Context, txID string) (bool, error) {
+	deleted, err := executer.log.Exec(
+		ctx,
+		"DELETE FROM "+executer.table+" WHERE tx_id = ? AND status = ?",
+		txID, statusPreparing,
+	)
+	if err != nil {
+		return false, errors.Wrapf(err, "aborting transaction %s", txID)
+	}
+
+	return deleted == 1, nil
+}
+
+func (executer Executer) logEntries(ctx context.Context) (_ map[string]logEntry, err error) {
+	rows, err := executer.log.Query(
+		ctx,
+		"SELECT tx_id, status, created_at FROM "+executer.table,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "selecting coordinator log")
+	}
+	defer func() {
+		err = multierr.Append(err, errors.Wrap(rows.Close(), "closing rows"))
+	}()
+
+	entries := make(map[string]logEntry)
+
+	for rows.Next() {
+		var (
+			txID  string
+			entry logEntry
+		)
+
+		err = rows.Scan(&txID, &entry.status, &entry.createdAt)
+		if err != nil {
+			return nil, errors.Wrap(err, "scanning coordinator log")
+		}
+
+		entries[txID] = entry
+	}
+
+	return entries, errors.Wrap(rows.Err(), "iterating coordinator log")
+}
+
+// logEntry reads the log entry of a single transaction.
+func (executer Executer) logEntry(
+	ctx context.Context,
+	txID string,
+) (_ logEntry, _ bool, err error) {
+	rows, err := executer.log.Query(
+		ctx,
+		"SELECT status, created_at FROM "+executer.table+" WHERE tx_id = ?",
+		txID,
+	)
+	if err != nil {
+		return logEntry{}, false, errors.Wrapf(err, "selecting log of transaction %s", txID)
+	}
+	defer func() {
+		err = multierr.Append(err, errors.Wrap(rows.Close(), "closing rows"))
+	}()
+
+	var entry logEntry
+
+	if !rows.Next() {
+		return logEntry{}, false, errors.Wrapf(rows.Err(), "selecting log of transaction %s", txID)
+	}
+
+	err = rows.Scan(&entry.status, &entry.createdAt)
+	if err != nil {
+		return logEntry{}, false, errors.Wrapf(err, "scanning log of transaction %s", txID)
+	}
+
+	return entry, true, nil
+}
+
+// inDoubt lists the global transaction ids of the coordinator which are prepared on participant.
+// Global transaction ids of other coordinators or applications are skipped.
+func (executer Executer) inDoubt(
+	ctx context.Context,
+	participant Participant,
+) (_ []string, err error) {
+	query := "SELECT gid FROM pg_prepared_xacts WHERE database = current_database()"
+	if participant.dialect == adapter.MySQL {
+		query = "XA RECOVER"
+	}
+
+	rows, err := participant.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, errors.Wrap(err, "querying prepared transactions")
+	}
+	defer func() {
+		err = multierr.Append(err, errors.Wrap(rows.Close(), "closing rows"))
+	}()
+
+	var gids []string
+
+	for rows.Next() {
+		var gid string
+
+		if participant.dialect == adapter.MySQL {
+			var formatID, gtridLength, bqualLength int
+
+			err = rows.Scan(&formatID, &gtridLength, &bqualLength, &gid)
+		} else {
+			err = rows.Scan(&gid)
+		}
+
+		if err != nil {
+			return nil, errors.Wrap(err, "scanning prepared transaction")
+		}
+
+		if _, ok := executer.txID(gid, participant); ok {
+			gids = append(gids, gid)
+		}
+	}
+
+	return gids, errors.Wrap(rows.Err(), "iterating prepared transactions")
+}
+
+// resolve commits or rolls back the prepared transaction gid on participant.
+func (executer Executer) resolve(
+	ctx context.Context,
+	participant Participant,
+	gid string,
+	commit bool,
+) error {
+	var statement string
+
+	switch {
+	case participant.dialect == adapter.MySQL && commit:
+		statement = "XA COMMIT " + quote(gid)
+	case participant.dialect == adapter.MySQL:
+		statement = "XA ROLLBACK " + quote(gid)
+	case commit:
+		statement = "COMMIT PREPARED " + quote(gid)
+	default:
+		statement = "ROLLBACK PREPARED " + quote(gid)
+	}
+
+	_, err := participant.db.ExecContext(ctx, statement)
+
+	return errors.Wrapf(err, "resolving transaction %s of participant %s", gid, participant.name)
+}
+
+// txID extracts the transaction id from a global transaction id of the coordinator for
+// participant. It returns false if gid does not consist of the prefix of the coordinator, a
+// transaction id and the name of participant.
+func (executer Executer) txID(gid string, participant Participant) (string, bool) {
+	rest, ok := strings.CutPrefix(gid, executer.prefix+separator)
+	if !ok {
+		return "", false
+	}
+
+	txID, name, ok := strings.Cut(rest, separator)
+	if !ok || name != participant.name || len(txID) != 2*txIDBytes {
+		return "", false
+	}
+
+	for _, r := range txID {
+		if !('0' <= r && r <= '9' || 'a' <= r && r <= 'f') {
+			return "", false
+		}
+	}
+
+	return txID, true
+}
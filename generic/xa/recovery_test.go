@@ -0,0 +1,142 @@
+package xa_test
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beeemT/go-atomic/generic/xa"
+)
+
+// txID returns a transaction id of the coordinator made of c.
+func txID(c byte) string {
+	return strings.Repeat(string(c), 32)
+}
+
+// gid returns the global transaction id of the coordinator with the default prefix.
+func gid(txID, participant string) string {
+	return xa.DefaultPrefix + "_" + txID + "_" + participant
+}
+
+func (c *coordinator) logEntry(t *testing.T, txID, status string, createdAt time.Time) {
+	t.Helper()
+
+	_, err := c.log.Exec(
+		context.Background(),
+		"INSERT INTO "+xa.DefaultTable+" (tx_id, status, created_at) VALUES (?, ?, ?)",
+		txID, status, createdAt,
+	)
+	if err != nil {
+		t.Fatalf("logging %s: %v", txID, err)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	c := newCoordinator(t)
+	now := c.clock.Now()
+
+	var (
+		committing = txID('1')
+		fresh      = txID('2')
+		stale      = txID('3')
+		unlogged   = txID('4')
+	)
+
+	c.logEntry(t, committing, "committing", now)
+	c.logEntry(t, fresh, "preparing", now)
+	c.logEntry(t, stale, "preparing", now.Add(-2*xa.DefaultGracePeriod))
+
+	for _, name := range []string{"a", "b"} {
+		for _, txID := range []string{committing, fresh, stale} {
+			c.participants[name].prepare(gid(txID, name))
+		}
+	}
+
+	c.participants["a"].prepare(gid(unlogged, "a"))
+
+	// transactions of other coordinators or applications
+	foreign := []string{
+		"atomic_x_" + unlogged + "_a",
+		"other_" + unlogged + "_a",
+		"atomic_" + strings.ToUpper(txID('a')) + "_a",
+		"atomic_" + unlogged[1:] + "_a",
+		"atomic_" + unlogged,
+		gid(unlogged, "b"),
+	}
+	for _, gid := range foreign {
+		c.participants["a"].prepare(gid)
+	}
+
+	c.events.take()
+
+	err := c.executer.Recover(context.Background())
+	if err != nil {
+		t.Fatalf("recovering: %v", err)
+	}
+
+	if len(c.errs) != 0 {
+		t.Fatalf("expected no resolution errors, got %v", c.errs)
+	}
+
+	for _, name := range []string{"a", "b"} {
+		participant := c.participants[name]
+
+		for txID, expected := range map[string]string{
+			committing: "COMMIT PREPARED",
+			stale:      "ROLLBACK PREPARED",
+		} {
+			if resolution := participant.resolution(gid(txID, name)); resolution != expected {
+				t.Errorf("expected %s of %s to be resolved with %s, got %q",
+					txID, name, expected, resolution)
+			}
+		}
+	}
+
+	if resolution := c.participants["a"].resolution(gid(unlogged, "a")); resolution !=
+		"ROLLBACK PREPARED" {
+		t.Errorf("expected unlogged transaction to be rolled back, got %q", resolution)
+	}
+
+	expected := append([]string{gid(fresh, "a")}, foreign...)
+	if prepared := c.participants["a"].preparedGIDs(); !reflect.DeepEqual(prepared, expected) {
+		t.Errorf("expected %v to stay prepared on a, got %v", expected, prepared)
+	}
+
+	if prepared := c.participants["b"].preparedGIDs(); !reflect.DeepEqual(
+		prepared,
+		[]string{gid(fresh, "b")},
+	) {
+		t.Errorf("expected fresh transaction to stay prepared on b, got %v", prepared)
+	}
+
+	logged := c.logged(t)
+	if !reflect.DeepEqual(logged, map[string]string{
+		committing: "committing",
+		fresh:      "preparing",
+	}) {
+		t.Errorf("expected stale log entry to be deleted, got %v", logged)
+	}
+}
+
+func TestRecoverPrefix(t *testing.T) {
+	c := newCoordinator(t, xa.WithPrefix("atomic-x"))
+	unlogged := txID('4')
+
+	// a transaction of the coordinator with the default prefix
+	c.participants["a"].prepare(gid(unlogged, "a"))
+	c.participants["a"].prepare("atomic-x_" + unlogged + "_a")
+
+	err := c.executer.Recover(context.Background())
+	if err != nil {
+		t.Fatalf("recovering: %v", err)
+	}
+
+	if prepared := c.participants["a"].preparedGIDs(); !reflect.DeepEqual(
+		prepared,
+		[]string{gid(unlogged, "a")},
+	) {
+		t.Fatalf("expected only the transaction of the prefix to be rolled back, got %v", prepared)
+	}
+}
@@ -0,0 +1,30 @@
+package xa
+
+import "github.com/beeemT/go-atomic/generic/adapter"
+
+// Schema returns the statements creating the coordinator log table with the given name.
+func Schema(dialect adapter.Dialect, table string) []string {
+	switch dialect {
+	case adapter.MySQL:
+		return []string{
+			"CREATE TABLE IF NOT EXISTS " + table + " (" +
+				"tx_id VARCHAR(64) PRIMARY KEY, " +
+				"status VARCHAR(32) NOT NULL, " +
+				"created_at DATETIME(6) NOT NULL)",
+		}
+	case adapter.SQLite:
+		return []string{
+			"CREATE TABLE IF NOT EXISTS " + table + " (" +
+				"tx_id TEXT PRIMARY KEY, " +
+				"status TEXT NOT NULL, " +
+				"created_at TIMESTAMP NOT NULL)",
+		}
+	}
+
+	return []string{
+		"CREATE TABLE IF NOT EXISTS " + table + " (" +
+			"tx_id TEXT PRIMARY KEY, " +
+			"status TEXT NOT NULL, " +
+			"created_at TIMESTAMPTZ NOT NULL)",
+	}
+}
@@ -0,0 +1,503 @@
+// Package xa implements [generic.Executer] for atomic transactions across several Postgres or
+// MySQL databases using two-phase commit (PREPARE TRANSACTION / COMMIT PREPARED on Postgres,
+// XA transactions on MySQL).
+//
+// The coordinator persists its commit decisions in a log table, which can be created with the
+// statements returned by [Schema]. Transactions left in doubt by a crashed coordinator are
+// resolved by [Executer.Recover] based on the log: transactions with a logged commit decision are
+// committed, all others are rolled back (presumed abort).
+//
+// Postgres participants need max_prepared_transactions to be greater than zero.
+package xa
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/beeemT/go-atomic"
+	"github.com/beeemT/go-atomic/generic"
+	"github.com/beeemT/go-atomic/generic/adapter"
+	"github.com/beeemT/go-atomic/generic/multi"
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+)
+
+const (
+	// DefaultTable is the default name of the coordinator log table.
+	DefaultTable = "atomic_xa_log"
+	// DefaultPrefix is the default prefix of the global transaction ids.
+	DefaultPrefix = "atomic"
+	// DefaultGracePeriod is the default age of log entries after which in doubt transactions
+	// without commit decision are rolled back by [Executer.Recover].
+	DefaultGracePeriod = time.Minute
+
+	statusPreparing  = "preparing"
+	statusCommitting = "committing"
+
+	txIDBytes = 16
+	// separator separates the prefix, the transaction id and the participant name in global
+	// transaction ids.
+	separator = "_"
+)
+
+var (
+	_ generic.Executer[multi.Remotes] = Executer{}
+
+	// ErrAborted is returned if the transaction was aborted by [Executer.Recover] during its
+	// prepare phase, because the prepare phase took longer than the grace period.
+	ErrAborted = errors.New("transaction aborted by recovery")
+	// ErrUnsupportedDialect is returned if a participant uses a dialect without support for
+	// two-phase commit.
+	ErrUnsupportedDialect = errors.New("dialect does not support two-phase commit")
+	// ErrInvalidPrefix is returned by [Executer.Execute] and [Executer.Recover] if the prefix set
+	// with [WithPrefix] is empty or contains other characters than letters, digits and '-'.
+	ErrInvalidPrefix = errors.New("invalid global transaction id prefix")
+)
+
+type (
+	// Participant is a database taking part in the transactions of an [Executer].
+	Participant struct {
+		name    string
+		db      *sql.DB
+		dialect adapter.Dialect
+	}
+
+	// Executer implements the [generic.Executer] interface using two-phase commit across its
+	// participants.
+	// The Remote passed to the executed function is a [multi.Remotes] holding a
+	// [generic.SQLRemote] for every participant.
+	Executer struct {
+		participants []Participant
+		log          adapter.Conn
+		table        string
+		prefix       string
+		gracePeriod  time.Duration
+		onError      func(error)
+		clock        atomic.Clock
+		err          error
+	}
+
+	// ExecuterOption configures the [Executer] instance.
+	ExecuterOption func(*Executer)
+
+	// branch is the transaction of a participant within a global transaction.
+	branch struct {
+		Participant
+
+		conn  *sql.Conn
+		gid   string
+		state branchState
+	}
+
+	branchState int
+)
+
+const (
+	branchActive branchState = iota
+	branchEnded
+	branchPrepared
+	branchDone
+)
+
+// NewParticipant creates a new Participant named name for db using dialect, which has to be
+// either [adapter.Postgres] or [adapter.MySQL].
+// The name is part of the global transaction ids and may only contain letters, digits, '-'
+// and '_'. MySQL limits global transaction ids to 64 bytes, of which 34 are used by the
+// transaction id and separators, so the name and the prefix (see [WithPrefix]) of MySQL
+// participants may not be longer than 30 bytes together.
+func NewParticipant(name string, db *sql.DB, dialect adapter.Dialect) Participant {
+	return Participant{
+		name:    name,
+		db:      db,
+		dialect: dialect,
+	}
+}
+
+// WithTable sets the name of the coordinator log table.
+func WithTable(table string) ExecuterOption {
+	return func(e *Executer) {
+		e.table = table
+	}
+}
+
+// WithPrefix sets the prefix of the global transaction ids, which is used by [Executer.Recover]
+// to find the in doubt transactions of the coordinator. Coordinators with different logs must use
+// different prefixes. The prefix may only contain letters, digits and '-', as '_' separates it
+// from the transaction id, otherwise the executer fails with [ErrInvalidPrefix]. See
+// [NewParticipant] for the length limit of MySQL.
+func WithPrefix(prefix string) ExecuterOption {
+	return func(e *Executer) {
+		e.prefix = prefix
+	}
+}
+
+// WithGracePeriod sets the age of log entries after which in doubt transactions without commit
+// decision are rolled back by [Executer.Recover]. It has to be longer than the prepare phase of
+// running transactions.
+func WithGracePeriod(gracePeriod time.Duration) ExecuterOption {
+	return func(e *Executer) {
+		e.gracePeriod = gracePeriod
+	}
+}
+
+// WithErrorHandler sets a function which is called with errors which do not fail the transaction,
+// ie failed commits after the commit decision was logged, which are completed by
+// [Executer.Recover], failed rollbacks after a panic of run and failed resolutions during
+// recovery.
+func WithErrorHandler(onError func(error)) ExecuterOption {
+	return func(e *Executer) {
+		e.onError = onError
+	}
+}
+
+// WithClock sets the clock used for the age of log entries, eg a fake clock in tests.
+func WithClock(clock atomic.Clock) ExecuterOption {
+	return func(e *Executer) {
+		e.clock = clock
+	}
+}
+
+// NewExecuter creates a new Executer for participants, which persists its log using log.
+// The log should be stored in a database which is not a participant.
+//
+// By default:
+//   - uses [DefaultTable] as log table.
+//   - uses [DefaultPrefix] as prefix of the global transaction ids.
+//   - uses [DefaultGracePeriod] as grace period for recovery.
+//   - uses [atomic.SystemClock] as clock.
+func NewExecuter(log adapter.Conn, participants []Participant, opts ...ExecuterOption) Executer {
+	executer := Executer{
+		participants: participants,
+		log:          log,
+		table:        DefaultTable,
+		prefix:       DefaultPrefix,
+		gracePeriod:  DefaultGracePeriod,
+		onError:      func(error) {},
+		clock:        atomic.SystemClock{},
+	}
+
+	for _, opt := range opts {
+		opt(&executer)
+	}
+
+	if !validPrefix(executer.prefix) {
+		executer.err = errors.Wrapf(ErrInvalidPrefix, "prefix %q", executer.prefix)
+	}
+
+	return executer
+}
+
+// Execute executes the provided function in a global transaction spanning all participants.
+// After run succeeded all participants are prepared, the commit decision is logged and the
+// prepared transactions are committed.
+// If logging the decision fails, an error matching [atomic.ErrCommitUnknown] is returned and the
+// prepared transactions are left for [Executer.Recover].
+// Panics in run roll back the transactions of all participants.
+func (executer Executer) Execute(ctx context.Context, run func(multi.Remotes) error) error {
+	if executer.err != nil {
+		return executer.err
+	}
+
+	id := make([]byte, txIDBytes)
+
+	_, err := rand.Read(id)
+	if err != nil {
+		return errors.Wrap(err, "generating transaction id")
+	}
+
+	txID := hex.EncodeToString(id)
+
+	branches, err := executer.begin(ctx, txID)
+	defer func() {
+		for _, b := range branches {
+			b.release()
+		}
+	}()
+
+	if err != nil {
+		return multierr.Append(err, executer.rollback(ctx, branches))
+	}
+
+	err = executer.run(ctx, branches, run)
+	if err != nil {
+		return multierr.Append(
+			errors.Wrap(err, "executing run"),
+			executer.rollback(ctx, branches),
+		)
+	}
+
+	return executer.commit(ctx, txID, branches)
+}
+
+// run calls run with the connections of branches. Panics in run roll back the branches and are
+// propagated afterwards.
+func (executer Executer) run(
+	ctx context.Context,
+	branches []*branch,
+	run func(multi.Remotes) error,
+) error {
+	remotes := make(map[string]any, len(branches))
+	for _, b := range branches {
+		remotes[b.name] = b.conn
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			err := executer.rollback(ctx, branches)
+			if err != nil {
+				executer.onError(err)
+			}
+
+			panic(p)
+		}
+	}()
+
+	return run(multi.NewRemotes(remotes))
+}
+
+// begin opens a connection and starts a transaction branch for every participant.
+func (executer Executer) begin(ctx context.Context, txID string) ([]*branch, error) {
+	branches := make([]*branch, 0, len(executer.participants))
+
+	for _, participant := range executer.participants {
+		if participant.dialect != adapter.Postgres && participant.dialect != adapter.MySQL {
+			return branches, errors.Wrapf(
+				ErrUnsupportedDialect,
+				"participant %s uses %s",
+				participant.name,
+				participant.dialect,
+			)
+		}
+
+		conn, err := participant.db.Conn(ctx)
+		if err != nil {
+			return branches, errors.Wrapf(
+				err,
+				"opening connection of participant %s",
+				participant.name,
+			)
+		}
+
+		b := &branch{
+			Participant: participant,
+			conn:        conn,
+			gid:         executer.prefix + separator + txID + separator + participant.name,
+			state:       branchDone,
+		}
+		branches = append(branches, b)
+
+		statement := "BEGIN"
+		if participant.dialect == adapter.MySQL {
+			statement = "XA START " + quote(b.gid)
+		}
+
+		err = b.exec(ctx, statement)
+		if err != nil {
+			return branches, errors.Wrapf(err, "beginning transaction of participant %s", b.name)
+		}
+
+		b.state = branchActive
+	}
+
+	return branches, nil
+}
+
+// commit runs the two phases of the commit protocol.
+func (executer Executer) commit(ctx context.Context, txID string, branches []*branch) error {
+	now := executer.clock.Now().UTC()
+
+	_, err := executer.log.Exec(
+		ctx,
+		"INSERT INTO "+executer.table+" (tx_id, status, created_at) VALUES (?, ?, ?)",
+		txID, statusPreparing, now,
+	)
+	if err != nil {
+		return multierr.Append(
+			errors.Wrap(err, "logging prepare"),
+			executer.rollback(ctx, branches),
+		)
+	}
+
+	for _, b := range branches {
+		err = b.prepare(ctx)
+		if err != nil {
+			return multierr.Combine(
+				errors.Wrapf(err, "preparing transaction of participant %s", b.name),
+				executer.rollback(ctx, branches),
+				executer.forget(ctx, txID),
+			)
+		}
+	}
+
+	// the decision is only logged if recovery did not abort the transaction in the meantime
+	decided, err := executer.log.Exec(
+		ctx,
+		"UPDATE "+executer.table+" SET status = ? WHERE tx_id = ? AND status = ?",
+		statusCommitting, txID, statusPreparing,
+	)
+	if err != nil {
+		return &atomic.CommitUnknownError{Err: errors.Wrap(err, "logging commit decision")}
+	}
+
+	if decided == 0 {
+		return multierr.Append(ErrAborted, executer.rollback(ctx, branches))
+	}
+
+	committed := true
+
+	for _, b := range branches {
+		err = b.commitPrepared(ctx)
+		if err != nil {
+			committed = false
+			executer.onError(errors.Wrapf(err, "committing transaction of participant %s", b.name))
+		}
+	}
+
+	if committed {
+		err = executer.forget(ctx, txID)
+		if err != nil {
+			executer.onError(err)
+		}
+	}
+
+	return nil
+}
+
+// rollback rolls back all branches which are not done.
+func (executer Executer) rollback(ctx context.Context, branches []*branch) error {
+	ctx = context.WithoutCancel(ctx)
+
+	var err error
+
+	for _, b := range branches {
+		err = multierr.Append(
+			err,
+			errors.Wrapf(b.rollback(ctx), "rolling back transaction of participant %s", b.name),
+		)
+	}
+
+	return err
+}
+
+// forget deletes the log entry of the transaction.
+func (executer Executer) forget(ctx context.Context, txID string) error {
+	_, err := executer.log.Exec(
+		context.WithoutCancel(ctx),
+		"DELETE FROM "+executer.table+" WHERE tx_id = ?",
+		txID,
+	)
+
+	return errors.Wrapf(err, "deleting log of transaction %s", txID)
+}
+
+func (b *branch) prepare(ctx context.Context) error {
+	if b.dialect == adapter.MySQL {
+		err := b.exec(ctx, "XA END "+quote(b.gid))
+		if err != nil {
+			return err
+		}
+
+		b.state = branchEnded
+
+		err = b.exec(ctx, "XA PREPARE "+quote(b.gid))
+		if err != nil {
+			return err
+		}
+	} else {
+		err := b.exec(ctx, "PREPARE TRANSACTION "+quote(b.gid))
+		if err != nil {
+			return err
+		}
+	}
+
+	b.state = branchPrepared
+
+	return nil
+}
+
+func (b *branch) commitPrepared(ctx context.Context) error {
+	statement := "COMMIT PREPARED " + quote(b.gid)
+	if b.dialect == adapter.MySQL {
+		statement = "XA COMMIT " + quote(b.gid)
+	}
+
+	err := b.exec(ctx, statement)
+	if err != nil {
+		return err
+	}
+
+	b.state = branchDone
+
+	return nil
+}
+
+func (b *branch) rollback(ctx context.Context) error {
+	var statements []string
+
+	switch {
+	case b.state == branchDone:
+		return nil
+	case b.dialect == adapter.Postgres && b.state == branchActive:
+		statements = []string{"ROLLBACK"}
+	case b.dialect == adapter.Postgres:
+		statements = []string{"ROLLBACK PREPARED " + quote(b.gid)}
+	case b.state == branchActive:
+		statements = []string{"XA END " + quote(b.gid), "XA ROLLBACK " + quote(b.gid)}
+	default:
+		statements = []string{"XA ROLLBACK " + quote(b.gid)}
+	}
+
+	for _, statement := range statements {
+		err := b.exec(ctx, statement)
+		if err != nil {
+			return err
+		}
+	}
+
+	b.state = branchDone
+
+	return nil
+}
+
+func (b *branch) exec(ctx context.Context, statement string) error {
+	_, err := b.conn.ExecContext(ctx, statement)
+
+	return errors.Wrap(err, statement)
+}
+
+// release returns the connection of the branch to the pool. Connections of branches which are
+// not done are discarded, as they might still be within a transaction.
+func (b *branch) release() {
+	if b.state != branchDone {
+		_ = b.conn.Raw(func(any) error {
+			return driver.ErrBadConn
+		})
+	}
+
+	_ = b.conn.Close()
+}
+
+// validPrefix reports whether prefix is a valid prefix of global transaction ids.
+func validPrefix(prefix string) bool {
+	if prefix == "" {
+		return false
+	}
+
+	for _, r := range prefix {
+		if !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || r == '-') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// quote quotes s as SQL string literal.
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,493 @@
+package xa_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/beeemT/go-atomic"
+	"github.com/beeemT/go-atomic/atomictest"
+	"github.com/beeemT/go-atomic/generic/adapter"
+	"github.com/beeemT/go-atomic/generic/multi"
+	"github.com/beeemT/go-atomic/generic/xa"
+	"github.com/beeemT/go-atomic/internal/sqlitetest"
+)
+
+var errInjected = errors.New("injected")
+
+type (
+	// events records the statements of the participants and the coordinator log in the order
+	// they were executed, without their arguments.
+	events struct {
+		mu     sync.Mutex
+		events []string
+	}
+
+	// fakeParticipant is a Postgres participant which records the executed statements and keeps
+	// track of the prepared transactions instead of executing them.
+	fakeParticipant struct {
+		name   string
+		events *events
+
+		mu       sync.Mutex
+		prepared []string
+		resolved map[string]string
+		fail     map[string]error
+	}
+
+	fakeConn struct {
+		participant *fakeParticipant
+	}
+
+	fakeRows struct {
+		gids []string
+	}
+
+	// recordingLog records the statements of the coordinator log and fails the statements
+	// starting with fail.
+	recordingLog struct {
+		adapter.Conn
+
+		events *events
+		fail   string
+	}
+
+	// coordinator is an executer with fake participants and a SQLite log.
+	coordinator struct {
+		executer     xa.Executer
+		participants map[string]*fakeParticipant
+		events       *events
+		log          *recordingLog
+		clock        *atomictest.Clock
+		errs         []error
+	}
+)
+
+func (e *events) record(event string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.events = append(e.events, event)
+}
+
+func (e *events) take() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	events := e.events
+	e.events = nil
+
+	return events
+}
+
+// failOn fails the next statement starting with verb.
+func (p *fakeParticipant) failOn(verb string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.fail[verb] = err
+}
+
+// preparedGIDs returns the gids of the transactions prepared on the participant.
+func (p *fakeParticipant) preparedGIDs() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]string(nil), p.prepared...)
+}
+
+// resolution returns the statement which resolved the prepared transaction gid.
+func (p *fakeParticipant) resolution(gid string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.resolved[gid]
+}
+
+// prepare marks gid as prepared, eg as left by a crashed coordinator.
+func (p *fakeParticipant) prepare(gid string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.prepared = append(p.prepared, gid)
+}
+
+func (p *fakeParticipant) exec(statement string) error {
+	verb, quoted, _ := strings.Cut(statement, " '")
+	gid := strings.TrimSuffix(quoted, "'")
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.events.record(p.name + " " + verb)
+
+	if err, ok := p.fail[verb]; ok {
+		delete(p.fail, verb)
+
+		return err
+	}
+
+	switch verb {
+	case "PREPARE TRANSACTION":
+		p.prepared = append(p.prepared, gid)
+	case "COMMIT PREPARED", "ROLLBACK PREPARED":
+		for i, prepared := range p.prepared {
+			if prepared == gid {
+				p.prepared = append(p.prepared[:i], p.prepared[i+1:]...)
+				p.resolved[gid] = verb
+
+				return nil
+			}
+		}
+
+		return errors.New("unknown prepared transaction " + gid)
+	}
+
+	return nil
+}
+
+func (p *fakeParticipant) Connect(context.Context) (driver.Conn, error) {
+	return fakeConn{participant: p}, nil
+}
+
+func (p *fakeParticipant) Driver() driver.Driver {
+	return nil
+}
+
+func (c fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported")
+}
+
+func (c fakeConn) Close() error {
+	return nil
+}
+
+func (c fakeConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions are not supported")
+}
+
+func (c fakeConn) ExecContext(
+	_ context.Context,
+	query string,
+	_ []driver.NamedValue,
+) (driver.Result, error) {
+	err := c.participant.exec(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return driver.RowsAffected(0), nil
+}
+
+func (c fakeConn) QueryContext(
+	_ context.Context,
+	query string,
+	_ []driver.NamedValue,
+) (driver.Rows, error) {
+	if !strings.Contains(query, "pg_prepared_xacts") {
+		return nil, errors.New("unexpected query " + query)
+	}
+
+	return &fakeRows{gids: c.participant.preparedGIDs()}, nil
+}
+
+func (r *fakeRows) Columns() []string {
+	return []string{"gid"}
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.gids) == 0 {
+		return io.EOF
+	}
+
+	dest[0], r.gids = r.gids[0], r.gids[1:]
+
+	return nil
+}
+
+func (l *recordingLog) Exec(ctx context.Context, query string, args ...any) (int64, error) {
+	verb, _, _ := strings.Cut(query, " ")
+	l.events.record("log " + verb)
+
+	if l.fail != "" && verb == l.fail {
+		return 0, errInjected
+	}
+
+	return l.Conn.Exec(ctx, query, args...) //nolint:wrapcheck // the fake is transparent
+}
+
+func newCoordinator(t *testing.T, opts ...xa.ExecuterOption) *coordinator {
+	t.Helper()
+
+	c := &coordinator{
+		participants: make(map[string]*fakeParticipant),
+		events:       &events{},
+		clock:        atomictest.NewClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)),
+	}
+
+	c.log = &recordingLog{
+		Conn: adapter.SQL(
+			sqlitetest.Open(t, xa.Schema(adapter.SQLite, xa.DefaultTable)...),
+			adapter.SQLite,
+		),
+		events: c.events,
+	}
+
+	participants := make([]xa.Participant, 0, 2)
+
+	for _, name := range []string{"a", "b"} {
+		participant := &fakeParticipant{
+			name:     name,
+			events:   c.events,
+			resolved: make(map[string]string),
+			fail:     make(map[string]error),
+		}
+		c.participants[name] = participant
+
+		db := sql.OpenDB(participant)
+		t.Cleanup(func() {
+			_ = db.Close()
+		})
+
+		participants = append(participants, xa.NewParticipant(name, db, adapter.Postgres))
+	}
+
+	c.executer = xa.NewExecuter(
+		c.log,
+		participants,
+		append([]xa.ExecuterOption{
+			xa.WithClock(c.clock),
+			xa.WithErrorHandler(func(err error) {
+				c.errs = append(c.errs, err)
+			}),
+		}, opts...)...,
+	)
+
+	return c
+}
+
+// logged returns the status of the logged transactions.
+func (c *coordinator) logged(t *testing.T) map[string]string {
+	t.Helper()
+
+	rows, err := c.log.Query(context.Background(), "SELECT tx_id, status FROM "+xa.DefaultTable)
+	if err != nil {
+		t.Fatalf("querying log: %v", err)
+	}
+	defer rows.Close()
+
+	logged := make(map[string]string)
+
+	for rows.Next() {
+		var txID, status string
+
+		err = rows.Scan(&txID, &status)
+		if err != nil {
+			t.Fatalf("scanning log: %v", err)
+		}
+
+		logged[txID] = status
+	}
+
+	if rows.Err() != nil {
+		t.Fatalf("iterating log: %v", rows.Err())
+	}
+
+	return logged
+}
+
+func (c *coordinator) execute() error {
+	return c.executer.Execute(context.Background(), func(remotes multi.Remotes) error {
+		for _, name := range []string{"a", "b"} {
+			_, err := multi.Remote[*sql.Conn](remotes, name)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func assertEvents(t *testing.T, events *events, expected ...string) {
+	t.Helper()
+
+	if actual := events.take(); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected events\n%s\ngot\n%s",
+			strings.Join(expected, "\n"), strings.Join(actual, "\n"))
+	}
+}
+
+func TestExecuteCommit(t *testing.T) {
+	c := newCoordinator(t)
+
+	err := c.execute()
+	if err != nil {
+		t.Fatalf("executing: %v", err)
+	}
+
+	assertEvents(t, c.events,
+		"a BEGIN",
+		"b BEGIN",
+		"log INSERT",
+		"a PREPARE TRANSACTION",
+		"b PREPARE TRANSACTION",
+		"log UPDATE",
+		"a COMMIT PREPARED",
+		"b COMMIT PREPARED",
+		"log DELETE",
+	)
+
+	if logged := c.logged(t); len(logged) != 0 {
+		t.Fatalf("expected log entry to be deleted, got %v", logged)
+	}
+}
+
+func TestExecutePrepareFails(t *testing.T) {
+	c := newCoordinator(t)
+	c.participants["b"].failOn("PREPARE TRANSACTION", errInjected)
+
+	err := c.execute()
+	if !errors.Is(err, errInjected) {
+		t.Fatalf("expected prepare error, got %v", err)
+	}
+
+	assertEvents(t, c.events,
+		"a BEGIN",
+		"b BEGIN",
+		"log INSERT",
+		"a PREPARE TRANSACTION",
+		"b PREPARE TRANSACTION",
+		"a ROLLBACK PREPARED",
+		"b ROLLBACK",
+		"log DELETE",
+	)
+
+	if logged := c.logged(t); len(logged) != 0 {
+		t.Fatalf("expected log entry to be deleted, got %v", logged)
+	}
+}
+
+func TestExecuteCommitFails(t *testing.T) {
+	c := newCoordinator(t)
+	c.participants["b"].failOn("COMMIT PREPARED", errInjected)
+
+	err := c.execute()
+	if err != nil {
+		t.Fatalf("expected commit after logged decision to succeed, got %v", err)
+	}
+
+	if len(c.errs) != 1 || !errors.Is(c.errs[0], errInjected) {
+		t.Fatalf("expected commit error to be handled, got %v", c.errs)
+	}
+
+	logged := c.logged(t)
+	if len(logged) != 1 {
+		t.Fatalf("expected commit decision to stay logged, got %v", logged)
+	}
+
+	for _, status := range logged {
+		if status != "committing" {
+			t.Fatalf("expected logged commit decision, got %s", status)
+		}
+	}
+
+	c.events.take()
+
+	// the commit decision is applied by recovery regardless of the grace period
+	err = c.executer.Recover(context.Background())
+	if err != nil {
+		t.Fatalf("recovering: %v", err)
+	}
+
+	assertEvents(t, c.events, "b COMMIT PREPARED")
+
+	if prepared := c.participants["b"].preparedGIDs(); len(prepared) != 0 {
+		t.Fatalf("expected recovery to commit %v", prepared)
+	}
+
+	// the log entry is deleted once it is older than the grace period
+	c.clock.Advance(xa.DefaultGracePeriod)
+
+	err = c.executer.Recover(context.Background())
+	if err != nil {
+		t.Fatalf("recovering: %v", err)
+	}
+
+	if logged := c.logged(t); len(logged) != 0 {
+		t.Fatalf("expected log entry to be deleted, got %v", logged)
+	}
+}
+
+func TestExecuteDecisionNotLogged(t *testing.T) {
+	c := newCoordinator(t)
+	c.log.fail = "UPDATE"
+
+	err := c.execute()
+	if !errors.Is(err, atomic.ErrCommitUnknown) {
+		t.Fatalf("expected ErrCommitUnknown, got %v", err)
+	}
+
+	assertEvents(t, c.events,
+		"a BEGIN",
+		"b BEGIN",
+		"log INSERT",
+		"a PREPARE TRANSACTION",
+		"b PREPARE TRANSACTION",
+		"log UPDATE",
+	)
+
+	for name, participant := range c.participants {
+		if len(participant.preparedGIDs()) != 1 {
+			t.Fatalf("expected transaction of %s to be left prepared", name)
+		}
+	}
+
+	// without commit decision the transaction is rolled back once the grace period passed
+	c.log.fail = ""
+	c.clock.Advance(xa.DefaultGracePeriod)
+
+	err = c.executer.Recover(context.Background())
+	if err != nil {
+		t.Fatalf("recovering: %v", err)
+	}
+
+	for name, participant := range c.participants {
+		if prepared := participant.preparedGIDs(); len(prepared) != 0 {
+			t.Fatalf("expected recovery to roll back %v of %s", prepared, name)
+		}
+	}
+
+	if logged := c.logged(t); len(logged) != 0 {
+		t.Fatalf("expected log entry to be deleted, got %v", logged)
+	}
+}
+
+func TestInvalidPrefix(t *testing.T) {
+	for _, prefix := range []string{"", "atomic_x", "atomic x"} {
+		c := newCoordinator(t, xa.WithPrefix(prefix))
+
+		err := c.execute()
+		if !errors.Is(err, xa.ErrInvalidPrefix) {
+			t.Fatalf("expected ErrInvalidPrefix executing with prefix %q, got %v", prefix, err)
+		}
+
+		err = c.executer.Recover(context.Background())
+		if !errors.Is(err, xa.ErrInvalidPrefix) {
+			t.Fatalf("expected ErrInvalidPrefix recovering with prefix %q, got %v", prefix, err)
+		}
+
+		assertEvents(t, c.events)
+	}
+}
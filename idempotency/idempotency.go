@@ -0,0 +1,262 @@
+// Package idempotency deduplicates [generic.Transacter.Transact] blocks by idempotency keys.
+// The first successful completion of a block stores its key and serialized result within the
+// same transaction, later calls with the same key return the stored result without running the
+// block again.
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/beeemT/go-atomic"
+	"github.com/beeemT/go-atomic/generic"
+	"github.com/beeemT/go-atomic/generic/adapter"
+	"github.com/beeemT/go-atomic/internal/sqlgen"
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+)
+
+const (
+	// DefaultTable is the default name of the idempotency key table.
+	DefaultTable = "atomic_idempotency_keys"
+	// DefaultTTL is the default duration for which results are stored.
+	DefaultTTL = 24 * time.Hour
+	// MaxKeyLength is the maximum length of idempotency keys in bytes, the size of the key column
+	// on MySQL.
+	MaxKeyLength = 255
+)
+
+// ErrKeyTooLong is returned by [Transacter.Transact] if the key exceeds [MaxKeyLength].
+var ErrKeyTooLong = errors.New("idempotency key too long")
+
+type (
+	// Transacter wraps a [generic.Transacter] and runs blocks at most once per idempotency key.
+	Transacter[Remote any, Resources any, Result any] struct {
+		transacter generic.Transacter[Remote, Resources]
+		conn       func(Remote) adapter.Conn
+		config     config
+	}
+
+	// Option configures the [Transacter] instance.
+	Option func(*config)
+
+	config struct {
+		table string
+		ttl   time.Duration
+		clock atomic.Clock
+	}
+)
+
+// WithTable sets the name of the idempotency key table.
+func WithTable(table string) Option {
+	return func(c *config) {
+		c.table = table
+	}
+}
+
+// WithTTL sets the duration for which keys and results are stored. Calls with an expired key
+// run the block again.
+func WithTTL(ttl time.Duration) Option {
+	return func(c *config) {
+		c.ttl = ttl
+	}
+}
+
+// WithClock sets the clock used for the expiry of keys, eg a fake clock in tests.
+func WithClock(clock atomic.Clock) Option {
+	return func(c *config) {
+		c.clock = clock
+	}
+}
+
+// NewTransacter creates a new Transacter.
+// conn creates the connection used to store the keys from the Remote of the transaction, eg with
+// [adapter.SQL]. Results are serialized with encoding/json.
+//
+// By default:
+//   - uses [DefaultTable] as key table.
+//   - uses [DefaultTTL] as time to live of keys.
+//   - uses the clock of transacter, see [generic.Transacter.Clock].
+func NewTransacter[Remote any, Resources any, Result any](
+	transacter generic.Transacter[Remote, Resources],
+	conn func(Remote) adapter.Conn,
+	opts ...Option,
+) Transacter[Remote, Resources, Result] {
+	idempotent := Transacter[Remote, Resources, Result]{
+		transacter: transacter,
+		conn:       conn,
+		config: config{
+			table: DefaultTable,
+			ttl:   DefaultTTL,
+			clock: transacter.Clock(),
+		},
+	}
+
+	for _, opt := range opts {
+		opt(&idempotent.config)
+	}
+
+	return idempotent
+}
+
+// Transact runs run within [generic.Transacter.Transact] unless a result for key is already stored.
+// If run succeeds its result is stored for key in the same transaction.
+// If a result is stored for key, it is returned without calling run.
+// Concurrent calls with the same key are serialized by row locks on the key, so only one of them
+// runs the block.
+// Keys longer than [MaxKeyLength] are rejected with [ErrKeyTooLong] before a transaction is
+// started.
+func (t Transacter[Remote, Resources, Result]) Transact(
+	ctx context.Context,
+	key string,
+	run func(context.Context, Resources) (Result, error),
+) (Result, error) {
+	var result Result
+
+	if len(key) > MaxKeyLength {
+		return result, errors.Wrapf(ErrKeyTooLong, "key has %d bytes", len(key))
+	}
+
+	err := t.transacter.Transact(ctx, func(ctx context.Context, resources Resources) error {
+		session, err := generic.RequireSession[Remote](ctx)
+		if err != nil {
+			return err //nolint:wrapcheck // sentinel
+		}
+
+		conn := t.conn(session.Tx)
+
+		stored, found, err := t.claim(ctx, conn, key)
+		if err != nil {
+			return err
+		}
+
+		if found {
+			return errors.Wrapf(json.Unmarshal(stored, &result), "decoding result of key %s", key)
+		}
+
+		result, err = run(ctx, resources)
+		if err != nil {
+			return err
+		}
+
+		encoded, err := json.Marshal(result)
+		if err != nil {
+			return errors.Wrapf(err, "encoding result of key %s", key)
+		}
+
+		_, err = conn.Exec(
+			ctx,
+			"UPDATE "+t.config.table+" SET result = ? WHERE idempotency_key = ?",
+			string(encoded), key,
+		)
+
+		return errors.Wrapf(err, "storing result of key %s", key)
+	})
+
+	return result, errors.Wrap(err, "running idempotent transaction")
+}
+
+// Cleanup deletes all expired keys and returns the number of deleted keys.
+func (t Transacter[Remote, Resources, Result]) Cleanup(ctx context.Context) (int64, error) {
+	var deleted int64
+
+	err := t.transacter.Transact(ctx, func(ctx context.Context, _ Resources) error {
+		session, err := generic.RequireSession[Remote](ctx)
+		if err != nil {
+			return err //nolint:wrapcheck // sentinel
+		}
+
+		deleted, err = t.conn(session.Tx).Exec(
+			ctx,
+			"DELETE FROM "+t.config.table+" WHERE expires_at <= ?",
+			t.config.clock.Now().UTC(),
+		)
+
+		return errors.Wrap(err, "deleting expired keys")
+	})
+
+	return deleted, errors.Wrap(err, "cleaning up idempotency keys")
+}
+
+// claim locks key for the current transaction.
+// It returns the stored result if key was already completed and is not expired.
+func (t Transacter[Remote, Resources, Result]) claim(
+	ctx context.Context,
+	conn adapter.Conn,
+	key string,
+) ([]byte, bool, error) {
+	now := t.config.clock.Now().UTC()
+
+	// inserting blocks until concurrent transactions holding the key finish
+	inserted, err := conn.Exec(
+		ctx,
+		sqlgen.InsertIgnore(
+			conn.Dialect(),
+			t.config.table,
+			"idempotency_key", "created_at", "expires_at",
+		),
+		key, now, now.Add(t.config.ttl),
+	)
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "inserting key %s", key)
+	}
+
+	if inserted == 1 {
+		return nil, false, nil
+	}
+
+	result, expiresAt, err := t.lock(ctx, conn, key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if result.Valid && expiresAt.After(now) {
+		return []byte(result.String), true, nil
+	}
+
+	_, err = conn.Exec(
+		ctx,
+		"UPDATE "+t.config.table+" SET result = NULL, created_at = ?, expires_at = ? "+
+			"WHERE idempotency_key = ?",
+		now, now.Add(t.config.ttl), key,
+	)
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "renewing expired key %s", key)
+	}
+
+	return nil, false, nil
+}
+
+func (t Transacter[Remote, Resources, Result]) lock(
+	ctx context.Context,
+	conn adapter.Conn,
+	key string,
+) (result sql.NullString, expiresAt time.Time, err error) {
+	rows, err := conn.Query(
+		ctx,
+		"SELECT result, expires_at FROM "+t.config.table+" WHERE idempotency_key = ?"+
+			sqlgen.ForUpdate(conn.Dialect(), false),
+		key,
+	)
+	if err != nil {
+		return result, expiresAt, errors.Wrapf(err, "selecting key %s", key)
+	}
+	defer func() {
+		err = multierr.Append(err, errors.Wrap(rows.Close(), "closing rows"))
+	}()
+
+	if !rows.Next() {
+		err = rows.Err()
+		if err == nil {
+			err = errors.Errorf("key %s was deleted concurrently", key)
+		}
+
+		return result, expiresAt, errors.Wrapf(err, "selecting key %s", key)
+	}
+
+	err = rows.Scan(&result, &expiresAt)
+
+	return result, expiresAt, errors.Wrapf(err, "scanning key %s", key)
+}
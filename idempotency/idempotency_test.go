@@ -0,0 +1,214 @@
+package idempotency_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/beeemT/go-atomic/atomictest"
+	"github.com/beeemT/go-atomic/generic"
+	"github.com/beeemT/go-atomic/generic/adapter"
+	gsql "github.com/beeemT/go-atomic/generic/sql"
+	"github.com/beeemT/go-atomic/idempotency"
+	"github.com/beeemT/go-atomic/internal/sqlitetest"
+)
+
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	return sqlitetest.Open(t, idempotency.Schema(adapter.SQLite, idempotency.DefaultTable)...)
+}
+
+func newTransacter(
+	db *sql.DB,
+	clock *atomictest.Clock,
+	opts ...idempotency.Option,
+) idempotency.Transacter[generic.SQLRemote, struct{}, int] {
+	return idempotency.NewTransacter[generic.SQLRemote, struct{}, int](
+		generic.NewTransacter[generic.SQLRemote, struct{}](
+			gsql.NewExecuter(db),
+			func(
+				context.Context,
+				*generic.Transacter[generic.SQLRemote, struct{}],
+				generic.SQLRemote,
+			) (struct{}, error) {
+				return struct{}{}, nil
+			},
+			generic.WithClock[generic.SQLRemote, struct{}](clock),
+		),
+		func(tx generic.SQLRemote) adapter.Conn {
+			return adapter.SQL(tx, adapter.SQLite)
+		},
+		opts...,
+	)
+}
+
+func keys(t *testing.T, db *sql.DB) int {
+	t.Helper()
+
+	var n int
+
+	err := db.QueryRow("SELECT COUNT(*) FROM " + idempotency.DefaultTable).Scan(&n)
+	if err != nil {
+		t.Fatalf("counting keys: %v", err)
+	}
+
+	return n
+}
+
+func TestStoredResult(t *testing.T) {
+	ctx := context.Background()
+	transacter := newTransacter(openDB(t), atomictest.NewClock(time.Now()))
+
+	result, err := transacter.Transact(ctx, "key", func(context.Context, struct{}) (int, error) {
+		return 42, nil
+	})
+	if err != nil || result != 42 {
+		t.Fatalf("expected result 42, got %d, %v", result, err)
+	}
+
+	result, err = transacter.Transact(ctx, "key", func(context.Context, struct{}) (int, error) {
+		t.Error("expected run not to be called for a stored key")
+
+		return 0, nil
+	})
+	if err != nil || result != 42 {
+		t.Fatalf("expected stored result 42, got %d, %v", result, err)
+	}
+}
+
+func TestFailedRunNotStored(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	transacter := newTransacter(db, atomictest.NewClock(time.Now()))
+	errRun := errors.New("run failed")
+
+	_, err := transacter.Transact(ctx, "key", func(context.Context, struct{}) (int, error) {
+		return 0, errRun
+	})
+	if !errors.Is(err, errRun) {
+		t.Fatalf("expected run error, got %v", err)
+	}
+
+	if n := keys(t, db); n != 0 {
+		t.Fatalf("expected key of the failed run to be rolled back, got %d keys", n)
+	}
+
+	result, err := transacter.Transact(ctx, "key", func(context.Context, struct{}) (int, error) {
+		return 42, nil
+	})
+	if err != nil || result != 42 {
+		t.Fatalf("expected run to be called again, got %d, %v", result, err)
+	}
+}
+
+func TestConcurrentDuplicates(t *testing.T) {
+	transacter := newTransacter(openDB(t), atomictest.NewClock(time.Now()))
+
+	var (
+		runs atomic.Int32
+		wg   sync.WaitGroup
+	)
+
+	results := make([]int, 10)
+	errs := make([]error, len(results))
+
+	for i := range results {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			results[i], errs[i] = transacter.Transact(
+				context.Background(),
+				"key",
+				func(context.Context, struct{}) (int, error) {
+					return int(runs.Add(1)), nil
+				},
+			)
+		}(i)
+	}
+
+	wg.Wait()
+
+	if n := runs.Load(); n != 1 {
+		t.Fatalf("expected run to be called once, got %d runs", n)
+	}
+
+	for i := range results {
+		if errs[i] != nil || results[i] != 1 {
+			t.Fatalf("expected every call to return the result of the run, got %d, %v",
+				results[i], errs[i])
+		}
+	}
+}
+
+func TestKeyTooLong(t *testing.T) {
+	executer := atomictest.NewExecuter(struct{}{})
+	transacter := idempotency.NewTransacter[struct{}, struct{}, int](
+		generic.NewTransacter[struct{}, struct{}](
+			executer,
+			func(
+				context.Context,
+				*generic.Transacter[struct{}, struct{}],
+				struct{},
+			) (struct{}, error) {
+				return struct{}{}, nil
+			},
+		),
+		func(struct{}) adapter.Conn {
+			t.Error("expected no connection to be used")
+
+			return nil
+		},
+	)
+
+	_, err := transacter.Transact(
+		context.Background(),
+		strings.Repeat("k", idempotency.MaxKeyLength+1),
+		func(context.Context, struct{}) (int, error) {
+			t.Error("expected run not to be called")
+
+			return 0, nil
+		},
+	)
+	if !errors.Is(err, idempotency.ErrKeyTooLong) {
+		t.Fatalf("expected ErrKeyTooLong, got %v", err)
+	}
+
+	if executions := len(executer.Executions()); executions != 0 {
+		t.Fatalf("expected no transaction to be started, got %d executions", executions)
+	}
+}
+
+// TestExpiryUsesTransacterClock checks that keys expire on the clock of the wrapped transacter.
+func TestExpiryUsesTransacterClock(t *testing.T) {
+	ctx := context.Background()
+	clock := atomictest.NewClock(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+	transacter := newTransacter(openDB(t), clock, idempotency.WithTTL(time.Hour))
+
+	runs := 0
+	run := func(context.Context, struct{}) (int, error) {
+		runs++
+
+		return runs, nil
+	}
+
+	for _, advance := range []time.Duration{0, time.Hour - time.Second, time.Second} {
+		clock.Advance(advance)
+
+		_, err := transacter.Transact(ctx, "key", run)
+		if err != nil {
+			t.Fatalf("transacting: %v", err)
+		}
+	}
+
+	if runs != 2 {
+		t.Fatalf("expected run to be repeated once the key expired, got %d runs", runs)
+	}
+}
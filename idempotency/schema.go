@@ -0,0 +1,37 @@
+package idempotency
+
+import "github.com/beeemT/go-atomic/generic/adapter"
+
+// Schema returns the statements creating the idempotency key table with the given name and its
+// indexes.
+func Schema(dialect adapter.Dialect, table string) []string {
+	switch dialect {
+	case adapter.MySQL:
+		return []string{
+			"CREATE TABLE IF NOT EXISTS " + table + " (" +
+				"idempotency_key VARCHAR(255) PRIMARY KEY, " +
+				"result LONGTEXT, " +
+				"created_at DATETIME(6) NOT NULL, " +
+				"expires_at DATETIME(6) NOT NULL, " +
+				"INDEX " + table + "_expires_idx (expires_at))",
+		}
+	case adapter.SQLite:
+		return []string{
+			"CREATE TABLE IF NOT EXISTS " + table + " (" +
+				"idempotency_key TEXT PRIMARY KEY, " +
+				"result TEXT, " +
+				"created_at TIMESTAMP NOT NULL, " +
+				"expires_at TIMESTAMP NOT NULL)",
+			"CREATE INDEX IF NOT EXISTS " + table + "_expires_idx ON " + table + " (expires_at)",
+		}
+	}
+
+	return []string{
+		"CREATE TABLE IF NOT EXISTS " + table + " (" +
+			"idempotency_key TEXT PRIMARY KEY, " +
+			"result TEXT, " +
+			"created_at TIMESTAMPTZ NOT NULL, " +
+			"expires_at TIMESTAMPTZ NOT NULL)",
+		"CREATE INDEX IF NOT EXISTS " + table + "_expires_idx ON " + table + " (expires_at)",
+	}
+}
@@ -0,0 +1,164 @@
+// Package inbox implements exactly-once message consumption on top of [generic.Transacter].
+// The ids of processed messages are recorded per consumer group within the same transaction as the
+// side effects of the handler, so redelivered messages are skipped.
+package inbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/beeemT/go-atomic"
+	"github.com/beeemT/go-atomic/generic"
+	"github.com/beeemT/go-atomic/generic/adapter"
+	"github.com/beeemT/go-atomic/internal/sqlgen"
+	"github.com/pkg/errors"
+)
+
+// DefaultTable is the default name of the inbox table.
+const DefaultTable = "atomic_inbox"
+
+type (
+	// Inbox handles messages of type Msg at most once per consumer group.
+	Inbox[Remote any, Resources any, Msg any] struct {
+		transacter generic.Transacter[Remote, Resources]
+		conn       func(Remote) adapter.Conn
+		group      string
+		id         func(Msg) string
+		table      string
+		clock      atomic.Clock
+	}
+
+	// Option configures the [Inbox] instance.
+	Option func(*config)
+
+	config struct {
+		table string
+		clock atomic.Clock
+	}
+)
+
+// WithTable sets the name of the inbox table.
+func WithTable(table string) Option {
+	return func(c *config) {
+		c.table = table
+	}
+}
+
+// WithClock sets the clock used for the processing time of messages, eg a fake clock in tests.
+func WithClock(clock atomic.Clock) Option {
+	return func(c *config) {
+		c.clock = clock
+	}
+}
+
+// New creates a new Inbox for the consumer group group.
+// conn creates the connection used to record the messages from the Remote of the transaction, eg
+// with [adapter.SQL]. id extracts the unique id from a message.
+//
+// By default:
+//   - uses [DefaultTable] as inbox table.
+//   - uses the clock of transacter, see [generic.Transacter.Clock].
+func New[Remote any, Resources any, Msg any](
+	transacter generic.Transacter[Remote, Resources],
+	conn func(Remote) adapter.Conn,
+	group string,
+	id func(Msg) string,
+	opts ...Option,
+) Inbox[Remote, Resources, Msg] {
+	cfg := config{
+		table: DefaultTable,
+		clock: transacter.Clock(),
+	}
+
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return Inbox[Remote, Resources, Msg]{
+		transacter: transacter,
+		conn:       conn,
+		group:      group,
+		id:         id,
+		table:      cfg.table,
+		clock:      cfg.clock,
+	}
+}
+
+// Handle runs handler for msg within [generic.Transacter.Transact] and records msg as processed in
+// the same transaction. If msg was already processed by the consumer group, handler is not called
+// and Handle returns nil.
+// Concurrent deliveries of the same message wait for each other, so only one of them is handled.
+func (i Inbox[Remote, Resources, Msg]) Handle(
+	ctx context.Context,
+	msg Msg,
+	handler func(context.Context, Msg, Resources) error,
+) error {
+	id := i.id(msg)
+
+	err := i.transacter.Transact(ctx, func(ctx context.Context, resources Resources) error {
+		session, err := generic.RequireSession[Remote](ctx)
+		if err != nil {
+			return err //nolint:wrapcheck // sentinel
+		}
+
+		conn := i.conn(session.Tx)
+
+		inserted, err := conn.Exec(
+			ctx,
+			sqlgen.InsertIgnore(
+				conn.Dialect(),
+				i.table,
+				"consumer_group", "message_id", "processed_at",
+			),
+			i.group, id, i.clock.Now().UTC(),
+		)
+		if err != nil {
+			return errors.Wrapf(err, "recording message %s", id)
+		}
+
+		if inserted == 0 {
+			return nil
+		}
+
+		return handler(ctx, msg, resources)
+	})
+
+	return errors.Wrapf(err, "handling message %s in consumer group %s", id, i.group)
+}
+
+// Handler adapts handler to a function which can be called from any consumer loop for every
+// received message. See [Inbox.Handle].
+func (i Inbox[Remote, Resources, Msg]) Handler(
+	handler func(context.Context, Msg, Resources) error,
+) func(context.Context, Msg) error {
+	return func(ctx context.Context, msg Msg) error {
+		return i.Handle(ctx, msg, handler)
+	}
+}
+
+// Purge deletes the records of messages processed by the consumer group before olderThan and
+// returns the number of deleted records. Messages redelivered after their record was purged are
+// handled again.
+func (i Inbox[Remote, Resources, Msg]) Purge(
+	ctx context.Context,
+	olderThan time.Duration,
+) (int64, error) {
+	var deleted int64
+
+	err := i.transacter.Transact(ctx, func(ctx context.Context, _ Resources) error {
+		session, err := generic.RequireSession[Remote](ctx)
+		if err != nil {
+			return err //nolint:wrapcheck // sentinel
+		}
+
+		deleted, err = i.conn(session.Tx).Exec(
+			ctx,
+			"DELETE FROM "+i.table+" WHERE consumer_group = ? AND processed_at < ?",
+			i.group, i.clock.Now().UTC().Add(-olderThan),
+		)
+
+		return errors.Wrap(err, "deleting processed messages")
+	})
+
+	return deleted, errors.Wrapf(err, "purging inbox of consumer group %s", i.group)
+}
@@ -0,0 +1,178 @@
+package inbox_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/beeemT/go-atomic/atomictest"
+	"github.com/beeemT/go-atomic/generic"
+	"github.com/beeemT/go-atomic/generic/adapter"
+	gsql "github.com/beeemT/go-atomic/generic/sql"
+	"github.com/beeemT/go-atomic/inbox"
+	"github.com/beeemT/go-atomic/internal/sqlitetest"
+)
+
+type message struct {
+	ID string
+}
+
+// consumer is an inbox whose handler records the handled messages in the markers table.
+type consumer struct {
+	inbox   inbox.Inbox[generic.SQLRemote, generic.SQLRemote, message]
+	handled []string
+	fail    error
+}
+
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	return sqlitetest.Open(t, append(
+		inbox.Schema(adapter.SQLite, inbox.DefaultTable),
+		"CREATE TABLE markers (message_id TEXT NOT NULL)",
+	)...)
+}
+
+func newConsumer(t *testing.T, db *sql.DB, group string, opts ...inbox.Option) *consumer {
+	t.Helper()
+
+	return &consumer{
+		inbox: inbox.New(
+			generic.NewTransacter[generic.SQLRemote, generic.SQLRemote](
+				gsql.NewExecuter(db),
+				func(
+					_ context.Context,
+					_ *generic.Transacter[generic.SQLRemote, generic.SQLRemote],
+					tx generic.SQLRemote,
+				) (generic.SQLRemote, error) {
+					return tx, nil
+				},
+			),
+			func(tx generic.SQLRemote) adapter.Conn {
+				return adapter.SQL(tx, adapter.SQLite)
+			},
+			group,
+			func(msg message) string {
+				return msg.ID
+			},
+			opts...,
+		),
+	}
+}
+
+func (c *consumer) handle(ctx context.Context, msg message, tx generic.SQLRemote) error {
+	_, err := tx.ExecContext(ctx, "INSERT INTO markers (message_id) VALUES (?)", msg.ID)
+	if err != nil {
+		return err
+	}
+
+	c.handled = append(c.handled, msg.ID)
+
+	return c.fail
+}
+
+func (c *consumer) deliver(t *testing.T, ids ...string) {
+	t.Helper()
+
+	for _, id := range ids {
+		err := c.inbox.Handle(context.Background(), message{ID: id}, c.handle)
+		if err != nil {
+			t.Fatalf("handling message %s: %v", id, err)
+		}
+	}
+}
+
+func markers(t *testing.T, db *sql.DB) int {
+	t.Helper()
+
+	var n int
+
+	err := db.QueryRow("SELECT COUNT(*) FROM markers").Scan(&n)
+	if err != nil {
+		t.Fatalf("counting markers: %v", err)
+	}
+
+	return n
+}
+
+func TestDuplicatesSkipped(t *testing.T) {
+	db := openDB(t)
+	c := newConsumer(t, db, "billing")
+
+	c.deliver(t, "1", "2", "1")
+
+	err := c.inbox.Handler(c.handle)(context.Background(), message{ID: "2"})
+	if err != nil {
+		t.Fatalf("handling redelivered message: %v", err)
+	}
+
+	if len(c.handled) != 2 || markers(t, db) != 2 {
+		t.Fatalf("expected 2 messages to be handled once, got %v", c.handled)
+	}
+}
+
+func TestConsumerGroups(t *testing.T) {
+	db := openDB(t)
+	billing, shipping := newConsumer(t, db, "billing"), newConsumer(t, db, "shipping")
+
+	billing.deliver(t, "1", "1")
+	shipping.deliver(t, "1", "1")
+
+	if len(billing.handled) != 1 || len(shipping.handled) != 1 {
+		t.Fatalf(
+			"expected every group to handle the message once, got %v and %v",
+			billing.handled, shipping.handled,
+		)
+	}
+}
+
+func TestRollback(t *testing.T) {
+	db := openDB(t)
+	c := newConsumer(t, db, "billing")
+	c.fail = errors.New("handler failed")
+
+	err := c.inbox.Handle(context.Background(), message{ID: "1"}, c.handle)
+	if !errors.Is(err, c.fail) {
+		t.Fatalf("expected handler error, got %v", err)
+	}
+
+	if markers(t, db) != 0 {
+		t.Fatal("expected side effects of the failed handler to be rolled back")
+	}
+
+	c.fail = nil
+	c.deliver(t, "1")
+
+	if len(c.handled) != 2 || markers(t, db) != 1 {
+		t.Fatalf("expected message to be handled again after the rollback, got %v", c.handled)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	db := openDB(t)
+	clock := atomictest.NewClock(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+	c := newConsumer(t, db, "billing", inbox.WithClock(clock))
+	other := newConsumer(t, db, "shipping", inbox.WithClock(clock))
+
+	c.deliver(t, "1")
+	other.deliver(t, "1")
+	clock.Advance(2 * time.Hour)
+	c.deliver(t, "2")
+
+	deleted, err := c.inbox.Purge(context.Background(), time.Hour)
+	if err != nil || deleted != 1 {
+		t.Fatalf("expected 1 purged message, got %d, %v", deleted, err)
+	}
+
+	c.deliver(t, "1", "2")
+	other.deliver(t, "1")
+
+	if len(c.handled) != 3 || len(other.handled) != 1 {
+		t.Fatalf(
+			"expected only the purged message of the group to be handled again, got %v and %v",
+			c.handled, other.handled,
+		)
+	}
+}
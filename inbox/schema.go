@@ -0,0 +1,38 @@
+package inbox
+
+import "github.com/beeemT/go-atomic/generic/adapter"
+
+// Schema returns the statements creating the inbox table with the given name and its indexes.
+func Schema(dialect adapter.Dialect, table string) []string {
+	switch dialect {
+	case adapter.MySQL:
+		return []string{
+			"CREATE TABLE IF NOT EXISTS " + table + " (" +
+				"consumer_group VARCHAR(255) NOT NULL, " +
+				"message_id VARCHAR(255) NOT NULL, " +
+				"processed_at DATETIME(6) NOT NULL, " +
+				"PRIMARY KEY (consumer_group, message_id), " +
+				"INDEX " + table + "_processed_idx (consumer_group, processed_at))",
+		}
+	case adapter.SQLite:
+		return []string{
+			"CREATE TABLE IF NOT EXISTS " + table + " (" +
+				"consumer_group TEXT NOT NULL, " +
+				"message_id TEXT NOT NULL, " +
+				"processed_at TIMESTAMP NOT NULL, " +
+				"PRIMARY KEY (consumer_group, message_id))",
+			"CREATE INDEX IF NOT EXISTS " + table + "_processed_idx ON " + table +
+				" (consumer_group, processed_at)",
+		}
+	}
+
+	return []string{
+		"CREATE TABLE IF NOT EXISTS " + table + " (" +
+			"consumer_group TEXT NOT NULL, " +
+			"message_id TEXT NOT NULL, " +
+			"processed_at TIMESTAMPTZ NOT NULL, " +
+			"PRIMARY KEY (consumer_group, message_id))",
+		"CREATE INDEX IF NOT EXISTS " + table + "_processed_idx ON " + table +
+			" (consumer_group, processed_at)",
+	}
+}
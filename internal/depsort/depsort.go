@@ -0,0 +1,62 @@
+// Package depsort orders values after their dependencies, for the constructors of atomicgen and
+// generic.AutoResources.
+package depsort
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Sort returns nodes ordered so that every node follows its dependencies. Independent nodes keep
+// their order. It fails with the path of the cycle, built with name, if the dependencies are
+// cyclic.
+func Sort[T comparable](
+	nodes []T,
+	dependencies func(T) []T,
+	name func(T) string,
+) ([]T, error) {
+	const (
+		visiting = iota + 1
+		visited
+	)
+
+	ordered := make([]T, 0, len(nodes))
+	state := make(map[T]int, len(nodes))
+
+	var visit func(node T, path []string) error
+
+	visit = func(node T, path []string) error {
+		path = append(path, name(node))
+
+		switch state[node] {
+		case visiting:
+			return errors.Errorf("dependency cycle %s", strings.Join(path, " -> "))
+		case visited:
+			return nil
+		}
+
+		state[node] = visiting
+
+		for _, dependency := range dependencies(node) {
+			err := visit(dependency, path)
+			if err != nil {
+				return err
+			}
+		}
+
+		state[node] = visited
+		ordered = append(ordered, node)
+
+		return nil
+	}
+
+	for _, node := range nodes {
+		err := visit(node, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return ordered, nil
+}
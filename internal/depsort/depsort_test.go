@@ -0,0 +1,52 @@
+package depsort_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/beeemT/go-atomic/internal/depsort"
+)
+
+func sort(graph map[string][]string, nodes ...string) ([]string, error) {
+	return depsort.Sort(
+		nodes,
+		func(node string) []string {
+			return graph[node]
+		},
+		func(node string) string {
+			return node
+		},
+	)
+}
+
+func TestSort(t *testing.T) {
+	ordered, err := sort(
+		map[string][]string{"orders": {"users", "clock"}, "users": {"clock"}},
+		"outbox", "orders", "users", "clock",
+	)
+	if err != nil {
+		t.Fatalf("sorting: %v", err)
+	}
+
+	expected := []string{"outbox", "clock", "users", "orders"}
+	if !reflect.DeepEqual(ordered, expected) {
+		t.Fatalf("expected %v, got %v", expected, ordered)
+	}
+}
+
+func TestSortCycle(t *testing.T) {
+	_, err := sort(
+		map[string][]string{"a": {"b"}, "b": {"c"}, "c": {"a"}},
+		"a", "b", "c",
+	)
+	if err == nil || err.Error() != "dependency cycle a -> b -> c -> a" {
+		t.Fatalf("expected dependency cycle, got %v", err)
+	}
+}
+
+func TestSortSelfDependency(t *testing.T) {
+	_, err := sort(map[string][]string{"a": {"a"}}, "a")
+	if err == nil || err.Error() != "dependency cycle a -> a" {
+		t.Fatalf("expected dependency cycle, got %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+// Package sqlgen builds the dialect specific parts of the statements used by the helper packages.
+package sqlgen
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/beeemT/go-atomic/generic"
+	"github.com/beeemT/go-atomic/generic/adapter"
+)
+
+// InsertIgnore returns an insert statement for columns into table which does nothing if the row
+// conflicts with an existing one.
+func InsertIgnore(dialect adapter.Dialect, table string, columns ...string) string {
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+	values := "(" + strings.Join(columns, ", ") + ") VALUES (" + placeholders + ")"
+
+	if dialect == adapter.MySQL {
+		return "INSERT IGNORE INTO " + table + " " + values
+	}
+
+	return "INSERT INTO " + table + " " + values + " ON CONFLICT DO NOTHING"
+}
+
+// ForUpdate returns the locking clause for a select of rows which are going to be updated.
+// SQLite locks the whole database on writes and does not support locking clauses.
+func ForUpdate(dialect adapter.Dialect, skipLocked bool) string {
+	switch {
+	case dialect == adapter.SQLite:
+		return ""
+	case skipLocked:
+		return " FOR UPDATE SKIP LOCKED"
+	}
+
+	return " FOR UPDATE"
+}
+
+// SetTimeouts returns the statements applying timeouts to the current transaction.
+// On MySQL the timeouts are set for the session and have to be reset with the statements returned
+// by [ResetTimeouts] before the transaction ends.
+func SetTimeouts(dialect adapter.Dialect, timeouts generic.Timeouts) []string {
+	var statements []string
+
+	switch dialect {
+	case adapter.Postgres:
+		for _, setting := range []struct {
+			name    string
+			timeout time.Duration
+		}{
+			{"statement_timeout", timeouts.Statement},
+			{"lock_timeout", timeouts.Lock},
+			{"idle_in_transaction_session_timeout", timeouts.IdleInTransaction},
+		} {
+			if setting.timeout > 0 {
+				value := strconv.FormatInt(milliseconds(setting.timeout), 10)
+				statements = append(statements, "SET LOCAL "+setting.name+" = "+value)
+			}
+		}
+	case adapter.MySQL:
+		if timeouts.Statement > 0 {
+			statements = append(
+				statements,
+				"SET SESSION max_execution_time = "+
+					strconv.FormatInt(milliseconds(timeouts.Statement), 10),
+			)
+		}
+
+		if timeouts.Lock > 0 {
+			seconds := int64(math.Ceil(timeouts.Lock.Seconds()))
+			statements = append(
+				statements,
+				"SET SESSION innodb_lock_wait_timeout = "+strconv.FormatInt(seconds, 10),
+			)
+		}
+	}
+
+	return statements
+}
+
+// ResetTimeouts returns the statements resetting the session timeouts set by the statements of
+// [SetTimeouts] to their global values.
+func ResetTimeouts(dialect adapter.Dialect, timeouts generic.Timeouts) []string {
+	var statements []string
+
+	if dialect != adapter.MySQL {
+		return statements
+	}
+
+	if timeouts.Statement > 0 {
+		statements = append(statements, "SET SESSION max_execution_time = DEFAULT")
+	}
+
+	if timeouts.Lock > 0 {
+		statements = append(statements, "SET SESSION innodb_lock_wait_timeout = DEFAULT")
+	}
+
+	return statements
+}
+
+// milliseconds returns d in milliseconds, rounded up to at least one millisecond.
+func milliseconds(d time.Duration) int64 {
+	return max(1, int64(math.Ceil(float64(d)/float64(time.Millisecond))))
+}
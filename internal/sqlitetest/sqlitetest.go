@@ -0,0 +1,99 @@
+// Package sqlitetest opens SQLite databases for the tests of go-atomic.
+package sqlitetest
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	// registers the sqlite3 driver
+	_ "github.com/mattn/go-sqlite3"
+	"gorm.io/gorm"
+	"gorm.io/gorm/callbacks"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+)
+
+// dialector is a minimal gorm dialector for raw statements on an open SQLite database. It does
+// not support migrations.
+type dialector struct {
+	db *sql.DB
+}
+
+// Open opens a SQLite database in a temporary directory of t and executes statements in it.
+// Transactions lock the database on begin, so concurrent transactions are serialized instead of
+// failing with SQLITE_BUSY. The database is closed when t finishes.
+func Open(t testing.TB, statements ...string) *sql.DB {
+	t.Helper()
+
+	dsn := "file:" + filepath.Join(t.TempDir(), "test.db") + "?_txlock=immediate&_busy_timeout=5000"
+
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatalf("opening sqlite: %v", err)
+	}
+
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	for _, statement := range statements {
+		_, err = db.Exec(statement)
+		if err != nil {
+			t.Fatalf("executing %q: %v", statement, err)
+		}
+	}
+
+	return db
+}
+
+// OpenGorm opens a SQLite database like [Open] and returns it as gorm database, which supports
+// raw statements and transactions.
+func OpenGorm(t testing.TB, statements ...string) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(dialector{db: Open(t, statements...)}, &gorm.Config{
+		Logger: logger.Discard,
+	})
+	if err != nil {
+		t.Fatalf("opening gorm: %v", err)
+	}
+
+	return db
+}
+
+func (d dialector) Name() string {
+	return "sqlite"
+}
+
+func (d dialector) Initialize(db *gorm.DB) error {
+	callbacks.RegisterDefaultCallbacks(db, &callbacks.Config{})
+	db.ConnPool = d.db
+
+	return nil
+}
+
+func (d dialector) Migrator(*gorm.DB) gorm.Migrator {
+	return nil
+}
+
+func (d dialector) DataTypeOf(*schema.Field) string {
+	return ""
+}
+
+func (d dialector) DefaultValueOf(*schema.Field) clause.Expression {
+	return clause.Expr{SQL: "NULL"}
+}
+
+func (d dialector) BindVarTo(writer clause.Writer, _ *gorm.Statement, _ any) {
+	_ = writer.WriteByte('?')
+}
+
+func (d dialector) QuoteTo(writer clause.Writer, str string) {
+	_, _ = writer.WriteString(`"` + str + `"`)
+}
+
+func (d dialector) Explain(sql string, vars ...any) string {
+	return logger.ExplainSQL(sql, nil, `'`, vars...)
+}
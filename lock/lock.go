@@ -0,0 +1,419 @@
+// Package lock provides named leases stored in a database table, eg for leader election or to
+// run singleton jobs across several processes.
+//
+// A lease is held until it is released or its TTL expires. Every acquisition increments the
+// fencing token of the lock, which can be passed to other systems to reject writes of owners whose
+// lease expired in the meantime. Leases held by [Manager.WithLock] are renewed in the background.
+//
+// Expiry is based on the clocks of the processes acquiring the lock, so the TTL has to be
+// considerably longer than the expected clock skew.
+package lock
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"time"
+
+	"github.com/beeemT/go-atomic"
+	"github.com/beeemT/go-atomic/generic"
+	"github.com/beeemT/go-atomic/generic/adapter"
+	"github.com/beeemT/go-atomic/internal/sqlgen"
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+)
+
+const (
+	// DefaultTable is the default name of the lock table.
+	DefaultTable = "atomic_locks"
+	// DefaultTTL is the default duration for which a lease is held without renewal.
+	DefaultTTL = 30 * time.Second
+	// DefaultPollInterval is the default interval in which [Manager.Acquire] retries to acquire a
+	// lock held by another owner.
+	DefaultPollInterval = time.Second
+
+	ownerBytes        = 16
+	heartbeatFraction = 3
+)
+
+var (
+	// ErrNotAcquired is returned by [Manager.TryAcquire] if the lock is held by another owner.
+	ErrNotAcquired = errors.New("lock not acquired")
+	// ErrLeaseLost is returned if the lease expired and the lock was acquired by another owner.
+	ErrLeaseLost = errors.New("lock lease lost")
+)
+
+type (
+	// Lease is a held lock.
+	Lease struct {
+		// Name is the name of the lock.
+		Name string
+		// Token is the fencing token of the lease. It increases with every acquisition of the lock.
+		Token int64
+		// ExpiresAt is the time at which the lease expires unless it is renewed.
+		ExpiresAt time.Time
+	}
+
+	// Manager acquires and renews leases using the transactions of an executer.
+	Manager[Remote any] struct {
+		executer generic.Executer[Remote]
+		conn     func(Remote) adapter.Conn
+		config   config
+	}
+
+	// Option configures the [Manager] instance.
+	Option func(*config)
+
+	config struct {
+		table        string
+		owner        string
+		ttl          time.Duration
+		heartbeat    time.Duration
+		pollInterval time.Duration
+		clock        atomic.Clock
+	}
+)
+
+// WithTable sets the name of the lock table.
+func WithTable(table string) Option {
+	return func(c *config) {
+		c.table = table
+	}
+}
+
+// WithOwner sets the owner stored with acquired leases, eg the hostname. It is informational only.
+func WithOwner(owner string) Option {
+	return func(c *config) {
+		c.owner = owner
+	}
+}
+
+// WithTTL sets the duration for which a lease is held without renewal.
+func WithTTL(ttl time.Duration) Option {
+	return func(c *config) {
+		c.ttl = ttl
+	}
+}
+
+// WithHeartbeat sets the interval in which [Manager.WithLock] renews its lease. It has to be
+// shorter than the TTL and defaults to a third of it.
+func WithHeartbeat(interval time.Duration) Option {
+	return func(c *config) {
+		c.heartbeat = interval
+	}
+}
+
+// WithPollInterval sets the interval in which [Manager.Acquire] retries to acquire a lock held by
+// another owner.
+func WithPollInterval(interval time.Duration) Option {
+	return func(c *config) {
+		c.pollInterval = interval
+	}
+}
+
+// WithClock sets the clock used for the expiry of leases and for waiting, eg a fake clock in tests.
+func WithClock(clock atomic.Clock) Option {
+	return func(c *config) {
+		c.clock = clock
+	}
+}
+
+// NewManager creates a new Manager storing leases in transactions opened by executer.
+// conn creates the connection used to persist the leases from the Remote of the transaction, eg
+// with [adapter.SQL].
+//
+// By default:
+//   - uses [DefaultTable] as lock table.
+//   - uses a random owner.
+//   - uses [DefaultTTL] as TTL and renews leases after a third of it.
+//   - uses [DefaultPollInterval] as poll interval.
+//   - uses [atomic.SystemClock] as clock.
+func NewManager[Remote any](
+	executer generic.Executer[Remote],
+	conn func(Remote) adapter.Conn,
+	opts ...Option,
+) (Manager[Remote], error) {
+	owner := make([]byte, ownerBytes)
+
+	_, err := rand.Read(owner)
+	if err != nil {
+		return Manager[Remote]{}, errors.Wrap(err, "generating owner id")
+	}
+
+	manager := Manager[Remote]{
+		executer: executer,
+		conn:     conn,
+		config: config{
+			table:        DefaultTable,
+			owner:        hex.EncodeToString(owner),
+			ttl:          DefaultTTL,
+			pollInterval: DefaultPollInterval,
+			clock:        atomic.SystemClock{},
+		},
+	}
+
+	for _, opt := range opts {
+		opt(&manager.config)
+	}
+
+	if manager.config.heartbeat <= 0 {
+		manager.config.heartbeat = manager.config.ttl / heartbeatFraction
+	}
+
+	return manager, nil
+}
+
+// TryAcquire acquires the lock name if it is free or its lease expired.
+// It returns [ErrNotAcquired] if the lock is held by another lease.
+func (m Manager[Remote]) TryAcquire(ctx context.Context, name string) (Lease, error) {
+	var lease Lease
+
+	err := m.inTx(ctx, func(conn adapter.Conn) error {
+		now := m.config.clock.Now().UTC()
+		lease = Lease{Name: name, Token: 1, ExpiresAt: now.Add(m.config.ttl)}
+
+		inserted, err := conn.Exec(
+			ctx,
+			sqlgen.InsertIgnore(
+				conn.Dialect(),
+				m.config.table,
+				"name", "owner", "token", "acquired_at", "expires_at",
+			),
+			name, m.config.owner, lease.Token, now, lease.ExpiresAt,
+		)
+		if err != nil {
+			return errors.Wrap(err, "inserting lock")
+		}
+
+		if inserted == 1 {
+			return nil
+		}
+
+		acquired, err := conn.Exec(
+			ctx,
+			"UPDATE "+m.config.table+" SET owner = ?, token = token + 1, acquired_at = ?, "+
+				"expires_at = ? WHERE name = ? AND expires_at <= ?",
+			m.config.owner, now, lease.ExpiresAt, name, now,
+		)
+		if err != nil {
+			return errors.Wrap(err, "taking over expired lock")
+		}
+
+		if acquired == 0 {
+			return ErrNotAcquired
+		}
+
+		lease.Token, err = m.token(ctx, conn, name)
+
+		return err
+	})
+	if err != nil {
+		return Lease{}, errors.Wrapf(err, "acquiring lock %s", name)
+	}
+
+	return lease, nil
+}
+
+// Acquire acquires the lock name, waiting until it is free or its lease expired.
+func (m Manager[Remote]) Acquire(ctx context.Context, name string) (Lease, error) {
+	for {
+		lease, err := m.TryAcquire(ctx, name)
+		if !errors.Is(err, ErrNotAcquired) {
+			return lease, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return Lease{}, errors.Wrapf(ctx.Err(), "waiting for lock %s", name)
+		case <-m.config.clock.After(m.config.pollInterval):
+		}
+	}
+}
+
+// Renew extends lease by the TTL and returns the renewed lease.
+// It returns [ErrLeaseLost] if the lock was acquired by another lease in the meantime.
+func (m Manager[Remote]) Renew(ctx context.Context, lease Lease) (Lease, error) {
+	renewed := lease
+
+	err := m.inTx(ctx, func(conn adapter.Conn) error {
+		renewed.ExpiresAt = m.config.clock.Now().UTC().Add(m.config.ttl)
+
+		return m.update(ctx, conn, lease, renewed.ExpiresAt)
+	})
+	if err != nil {
+		return Lease{}, errors.Wrapf(err, "renewing lock %s", lease.Name)
+	}
+
+	return renewed, nil
+}
+
+// Release releases lease, so the lock can be acquired by other owners right away.
+// It returns [ErrLeaseLost] if the lock was acquired by another lease in the meantime.
+func (m Manager[Remote]) Release(ctx context.Context, lease Lease) error {
+	err := m.inTx(ctx, func(conn adapter.Conn) error {
+		// the row is kept, so the fencing token keeps increasing
+		return m.update(ctx, conn, lease, m.config.clock.Now().UTC())
+	})
+
+	return errors.Wrapf(err, "releasing lock %s", lease.Name)
+}
+
+// WithLock acquires the lock name, waiting until it is available, and calls fn while holding it.
+// The lease is renewed in the background and released after fn returned. If the lease is lost,
+// the context passed to fn is canceled and an error matching [ErrLeaseLost] is returned.
+func (m Manager[Remote]) WithLock(
+	ctx context.Context,
+	name string,
+	fn func(ctx context.Context, lease Lease) error,
+) error {
+	lease, err := m.Acquire(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	fnCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	done := make(chan struct{})
+	heartbeat := make(chan Lease, 1)
+
+	go func() {
+		heartbeat <- m.heartbeat(fnCtx, done, lease, cancel)
+	}()
+
+	err = fn(fnCtx, lease)
+
+	close(done)
+
+	lease = <-heartbeat
+
+	if cause := context.Cause(fnCtx); errors.Is(cause, ErrLeaseLost) {
+		return multierr.Append(err, cause)
+	}
+
+	return multierr.Append(err, m.Release(context.WithoutCancel(ctx), lease))
+}
+
+// WithLockTransact acquires the lock name with m and runs run within a transaction of transacter
+// while holding the lock. The fencing token of the lease is passed to run, which can store it
+// along with its changes to detect writes of expired leases.
+func WithLockTransact[Remote any, Resources any](
+	ctx context.Context,
+	m Manager[Remote],
+	transacter atomic.Transacter[Resources],
+	name string,
+	run func(ctx context.Context, lease Lease, resources Resources) error,
+) error {
+	return m.WithLock(ctx, name, func(ctx context.Context, lease Lease) error {
+		//nolint:wrapcheck // the error of run is returned as is
+		return transacter.Transact(ctx, func(ctx context.Context, resources Resources) error {
+			return run(ctx, lease, resources)
+		})
+	})
+}
+
+// heartbeat renews lease until done is closed and returns the last renewed lease. If the lease is
+// lost, cancel is called with an error matching [ErrLeaseLost].
+func (m Manager[Remote]) heartbeat(
+	ctx context.Context,
+	done <-chan struct{},
+	lease Lease,
+	cancel context.CancelCauseFunc,
+) Lease {
+	for {
+		select {
+		case <-done:
+			return lease
+		case <-ctx.Done():
+			return lease
+		case <-m.config.clock.After(m.config.heartbeat):
+		}
+
+		renewed, err := m.Renew(ctx, lease)
+
+		switch {
+		case err == nil:
+			lease = renewed
+		case errors.Is(err, ErrLeaseLost):
+			cancel(err)
+
+			return lease
+		case !m.config.clock.Now().Before(lease.ExpiresAt):
+			// the lease could not be renewed before it expired
+			cancel(multierr.Append(errors.Wrapf(ErrLeaseLost, "lock %s", lease.Name), err))
+
+			return lease
+		}
+	}
+}
+
+// update sets the expiry of lease if it is still the current lease of its lock.
+func (m Manager[Remote]) update(
+	ctx context.Context,
+	conn adapter.Conn,
+	lease Lease,
+	expiresAt time.Time,
+) error {
+	// the token is compared instead of counting the updated rows, as MySQL does not count rows
+	// which are unchanged, eg by a renewal within the resolution of the clock
+	token, err := m.token(ctx, conn, lease.Name)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && token != lease.Token) {
+		return ErrLeaseLost
+	}
+
+	if err != nil {
+		return err
+	}
+
+	_, err = conn.Exec(
+		ctx,
+		"UPDATE "+m.config.table+" SET expires_at = ? WHERE name = ?",
+		expiresAt, lease.Name,
+	)
+
+	return errors.Wrap(err, "updating lease")
+}
+
+// token locks the row of the lock name and returns its fencing token.
+func (m Manager[Remote]) token(
+	ctx context.Context,
+	conn adapter.Conn,
+	name string,
+) (_ int64, err error) {
+	rows, err := conn.Query(
+		ctx,
+		"SELECT token FROM "+m.config.table+" WHERE name = ?"+
+			sqlgen.ForUpdate(conn.Dialect(), false),
+		name,
+	)
+	if err != nil {
+		return 0, errors.Wrap(err, "selecting fencing token")
+	}
+	defer func() {
+		err = multierr.Append(err, errors.Wrap(rows.Close(), "closing rows"))
+	}()
+
+	var token int64
+
+	if !rows.Next() {
+		return 0, multierr.Append(sql.ErrNoRows, errors.Wrap(rows.Err(), "selecting lock"))
+	}
+
+	err = rows.Scan(&token)
+	if err != nil {
+		return 0, errors.Wrap(err, "scanning fencing token")
+	}
+
+	return token, errors.Wrap(rows.Err(), "iterating fencing token")
+}
+
+// inTx runs run in a transaction of the executer.
+func (m Manager[Remote]) inTx(ctx context.Context, run func(adapter.Conn) error) error {
+	return errors.Wrap(
+		m.executer.Execute(ctx, func(remote Remote) error {
+			return run(m.conn(remote))
+		}),
+		"running lock transaction",
+	)
+}
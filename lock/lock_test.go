@@ -0,0 +1,223 @@
+package lock_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/beeemT/go-atomic/atomictest"
+	"github.com/beeemT/go-atomic/generic"
+	"github.com/beeemT/go-atomic/generic/adapter"
+	gsql "github.com/beeemT/go-atomic/generic/sql"
+	"github.com/beeemT/go-atomic/internal/sqlitetest"
+	"github.com/beeemT/go-atomic/lock"
+)
+
+const ttl = 30 * time.Second
+
+func openDB(t *testing.T) (*sql.DB, *atomictest.Clock) {
+	t.Helper()
+
+	return sqlitetest.Open(t, lock.Schema(adapter.SQLite, lock.DefaultTable)...),
+		atomictest.NewClock(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+}
+
+func newManager(
+	t *testing.T,
+	db *sql.DB,
+	clock *atomictest.Clock,
+	opts ...lock.Option,
+) lock.Manager[generic.SQLRemote] {
+	t.Helper()
+
+	manager, err := lock.NewManager[generic.SQLRemote](
+		gsql.NewExecuter(db),
+		func(tx generic.SQLRemote) adapter.Conn {
+			return adapter.SQL(tx, adapter.SQLite)
+		},
+		append([]lock.Option{lock.WithClock(clock), lock.WithTTL(ttl)}, opts...)...,
+	)
+	if err != nil {
+		t.Fatalf("creating manager: %v", err)
+	}
+
+	return manager
+}
+
+func TestTryAcquireContention(t *testing.T) {
+	ctx := context.Background()
+	db, clock := openDB(t)
+	first, second := newManager(t, db, clock), newManager(t, db, clock)
+
+	lease, err := first.TryAcquire(ctx, "job")
+	if err != nil || lease.Token != 1 || !lease.ExpiresAt.Equal(clock.Now().Add(ttl)) {
+		t.Fatalf("expected first lease with token 1, got %+v, %v", lease, err)
+	}
+
+	_, err = second.TryAcquire(ctx, "job")
+	if !errors.Is(err, lock.ErrNotAcquired) {
+		t.Fatalf("expected ErrNotAcquired while the lock is held, got %v", err)
+	}
+
+	_, err = first.TryAcquire(ctx, "other")
+	if err != nil {
+		t.Fatalf("expected other lock to be free, got %v", err)
+	}
+
+	err = first.Release(ctx, lease)
+	if err != nil {
+		t.Fatalf("releasing: %v", err)
+	}
+
+	lease, err = second.TryAcquire(ctx, "job")
+	if err != nil || lease.Token != 2 {
+		t.Fatalf("expected released lock to be acquired with token 2, got %+v, %v", lease, err)
+	}
+}
+
+func TestExpiredLeaseTakeover(t *testing.T) {
+	ctx := context.Background()
+	db, clock := openDB(t)
+	first, second := newManager(t, db, clock), newManager(t, db, clock)
+
+	expired, err := first.TryAcquire(ctx, "job")
+	if err != nil {
+		t.Fatalf("acquiring: %v", err)
+	}
+
+	clock.Advance(ttl - time.Second)
+
+	_, err = second.TryAcquire(ctx, "job")
+	if !errors.Is(err, lock.ErrNotAcquired) {
+		t.Fatalf("expected ErrNotAcquired before the lease expired, got %v", err)
+	}
+
+	clock.Advance(time.Second)
+
+	lease, err := second.TryAcquire(ctx, "job")
+	if err != nil || lease.Token != expired.Token+1 {
+		t.Fatalf("expected takeover with incremented token, got %+v, %v", lease, err)
+	}
+
+	_, err = first.Renew(ctx, expired)
+	if !errors.Is(err, lock.ErrLeaseLost) {
+		t.Fatalf("expected ErrLeaseLost renewing expired lease, got %v", err)
+	}
+
+	err = first.Release(ctx, expired)
+	if !errors.Is(err, lock.ErrLeaseLost) {
+		t.Fatalf("expected ErrLeaseLost releasing expired lease, got %v", err)
+	}
+
+	clock.Advance(time.Second)
+
+	renewed, err := second.Renew(ctx, lease)
+	if err != nil || !renewed.ExpiresAt.Equal(clock.Now().Add(ttl)) {
+		t.Fatalf("expected current lease to be renewed, got %+v, %v", renewed, err)
+	}
+}
+
+func TestWithLockHeartbeat(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	db, clock := openDB(t)
+	holder, other := newManager(t, db, clock), newManager(t, db, clock)
+	release := make(chan struct{})
+	done := make(chan error, 1)
+
+	go func() {
+		done <- holder.WithLock(ctx, "job", func(ctx context.Context, _ lock.Lease) error {
+			select {
+			case <-release:
+				return nil
+			case <-ctx.Done():
+				return context.Cause(ctx)
+			}
+		})
+	}()
+
+	// the lease is renewed every third of the TTL, so it is held beyond its initial expiry
+	for i := 0; i < 3; i++ {
+		err := clock.BlockUntil(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		clock.Advance(ttl / 3)
+	}
+
+	err := clock.BlockUntil(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = other.TryAcquire(ctx, "job")
+	if !errors.Is(err, lock.ErrNotAcquired) {
+		t.Fatalf("expected renewed lease to be held after its initial expiry, got %v", err)
+	}
+
+	close(release)
+
+	err = <-done
+	if err != nil {
+		t.Fatalf("running with lock: %v", err)
+	}
+
+	lease, err := other.TryAcquire(ctx, "job")
+	if err != nil || lease.Token != 2 {
+		t.Fatalf("expected lease to be released, got %+v, %v", lease, err)
+	}
+}
+
+func TestWithLockTransactLeaseLost(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	db, clock := openDB(t)
+	// the heartbeat is longer than the TTL, so the lease expires before it is renewed
+	holder := newManager(t, db, clock, lock.WithHeartbeat(2*ttl))
+	other := newManager(t, db, clock)
+	transacter := atomictest.NewTransacter(struct{}{})
+	done := make(chan error, 1)
+
+	go func() {
+		done <- lock.WithLockTransact(ctx, holder, transacter, "job",
+			func(ctx context.Context, _ lock.Lease, _ struct{}) error {
+				<-ctx.Done()
+
+				return context.Cause(ctx)
+			},
+		)
+	}()
+
+	err := clock.BlockUntil(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Advance(ttl)
+
+	_, err = other.TryAcquire(ctx, "job")
+	if err != nil {
+		t.Fatalf("taking over expired lease: %v", err)
+	}
+
+	clock.Advance(ttl)
+
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		t.Fatal("expected run to be canceled once the lease was lost")
+	}
+
+	if !errors.Is(err, lock.ErrLeaseLost) {
+		t.Fatalf("expected ErrLeaseLost, got %v", err)
+	}
+
+	if calls := transacter.Calls(); len(calls) != 1 || !calls[0].RolledBack {
+		t.Fatalf("expected transaction of run to be rolled back, got %+v", calls)
+	}
+}
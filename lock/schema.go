@@ -0,0 +1,36 @@
+package lock
+
+import "github.com/beeemT/go-atomic/generic/adapter"
+
+// Schema returns the statements creating the lock table with the given name.
+func Schema(dialect adapter.Dialect, table string) []string {
+	switch dialect {
+	case adapter.MySQL:
+		return []string{
+			"CREATE TABLE IF NOT EXISTS " + table + " (" +
+				"name VARCHAR(255) PRIMARY KEY, " +
+				"owner VARCHAR(255) NOT NULL, " +
+				"token BIGINT NOT NULL, " +
+				"acquired_at DATETIME(6) NOT NULL, " +
+				"expires_at DATETIME(6) NOT NULL)",
+		}
+	case adapter.SQLite:
+		return []string{
+			"CREATE TABLE IF NOT EXISTS " + table + " (" +
+				"name TEXT PRIMARY KEY, " +
+				"owner TEXT NOT NULL, " +
+				"token INTEGER NOT NULL, " +
+				"acquired_at TIMESTAMP NOT NULL, " +
+				"expires_at TIMESTAMP NOT NULL)",
+		}
+	}
+
+	return []string{
+		"CREATE TABLE IF NOT EXISTS " + table + " (" +
+			"name TEXT PRIMARY KEY, " +
+			"owner TEXT NOT NULL, " +
+			"token BIGINT NOT NULL, " +
+			"acquired_at TIMESTAMPTZ NOT NULL, " +
+			"expires_at TIMESTAMPTZ NOT NULL)",
+	}
+}
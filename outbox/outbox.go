@@ -0,0 +1,240 @@
+// Package outbox implements the transactional outbox pattern on top of go-atomic.
+// Messages are enqueued with a [Repository] inside [atomic.Transacter.Transact] blocks and are
+// therefore only persisted if the rest of the block commits. A [Relay] polls the outbox table,
+// dispatches the messages to a [Publisher] and marks them as delivered.
+package outbox
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/beeemT/go-atomic"
+	"github.com/beeemT/go-atomic/generic/adapter"
+	"github.com/beeemT/go-atomic/internal/sqlgen"
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+)
+
+// DefaultTable is the default name of the outbox table.
+const DefaultTable = "atomic_outbox"
+
+const idBytes = 16
+
+type (
+	// Message is a message stored in the outbox.
+	Message struct {
+		// ID uniquely identifies the message, it is generated on enqueue if empty.
+		ID string
+		// Topic is the destination of the message.
+		Topic string
+		// Key is the ordering key of the message. Messages with the same non empty key are
+		// delivered in the order they were enqueued. A message is not delivered before all
+		// previous messages with the same key are either delivered or dead-lettered.
+		Key string
+		// Payload is the body of the message.
+		Payload []byte
+		// Headers are additional attributes of the message.
+		Headers map[string]string
+		// CreatedAt is the time the message was enqueued, it is set on enqueue if zero.
+		CreatedAt time.Time
+	}
+
+	// Repository stores messages in the outbox table.
+	// Create it with the Remote provided to the createResources function of the transacter to
+	// enqueue messages within the transaction.
+	Repository struct {
+		conn  adapter.Conn
+		table string
+		clock atomic.Clock
+	}
+
+	// RepositoryOption configures the [Repository] instance.
+	RepositoryOption func(*Repository)
+
+	// record is a message read from the outbox table.
+	record struct {
+		Message
+
+		seq      int64
+		attempts int
+	}
+)
+
+// WithTable sets the name of the outbox table.
+func WithTable(table string) RepositoryOption {
+	return func(r *Repository) {
+		r.table = table
+	}
+}
+
+// WithClock sets the clock used for the creation time of enqueued messages, eg a fake clock in
+// tests.
+func WithClock(clock atomic.Clock) RepositoryOption {
+	return func(r *Repository) {
+		r.clock = clock
+	}
+}
+
+// NewRepository creates a new Repository using conn, which is usually created from the Remote
+// passed to createResources, eg with [adapter.SQL].
+//
+// By default:
+//   - uses [DefaultTable] as outbox table.
+//   - uses [atomic.SystemClock] as clock.
+func NewRepository(conn adapter.Conn, opts ...RepositoryOption) Repository {
+	repository := Repository{
+		conn:  conn,
+		table: DefaultTable,
+		clock: atomic.SystemClock{},
+	}
+
+	for _, opt := range opts {
+		opt(&repository)
+	}
+
+	return repository
+}
+
+// Enqueue stores msgs in the outbox table.
+func (r Repository) Enqueue(ctx context.Context, msgs ...Message) error {
+	now := r.clock.Now().UTC()
+
+	for _, msg := range msgs {
+		if msg.ID == "" {
+			id, err := newID()
+			if err != nil {
+				return err
+			}
+
+			msg.ID = id
+		}
+
+		if msg.CreatedAt.IsZero() {
+			msg.CreatedAt = now
+		}
+
+		headers, err := json.Marshal(msg.Headers)
+		if err != nil {
+			return errors.Wrap(err, "encoding headers")
+		}
+
+		_, err = r.conn.Exec(
+			ctx,
+			"INSERT INTO "+r.table+" (message_id, topic, ordering_key, payload, headers, "+
+				"created_at, attempts, next_attempt_at) VALUES (?, ?, ?, ?, ?, ?, 0, ?)",
+			msg.ID, msg.Topic, msg.Key, msg.Payload, string(headers), msg.CreatedAt, msg.CreatedAt,
+		)
+		if err != nil {
+			return errors.Wrapf(err, "inserting outbox message %s", msg.ID)
+		}
+	}
+
+	return nil
+}
+
+// claim locks and returns up to limit messages which are due at now.
+// Only the oldest pending message of every ordering key is returned, rows locked by other
+// relays are skipped.
+func (r Repository) claim(ctx context.Context, now time.Time, limit int) (_ []record, err error) {
+	rows, err := r.conn.Query(
+		ctx,
+		"SELECT o.id, o.message_id, o.topic, o.ordering_key, o.payload, o.headers, o.created_at, "+
+			"o.attempts FROM "+r.table+" o "+
+			"WHERE o.delivered_at IS NULL AND o.dead_lettered_at IS NULL "+
+			"AND o.next_attempt_at <= ? "+
+			"AND (o.ordering_key = '' OR NOT EXISTS (SELECT 1 FROM "+r.table+" p "+
+			"WHERE p.ordering_key = o.ordering_key AND p.id < o.id "+
+			"AND p.delivered_at IS NULL AND p.dead_lettered_at IS NULL)) "+
+			"ORDER BY o.id LIMIT ?"+sqlgen.ForUpdate(r.conn.Dialect(), true),
+		now, limit,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "selecting outbox messages")
+	}
+	defer func() {
+		err = multierr.Append(err, errors.Wrap(rows.Close(), "closing rows"))
+	}()
+
+	var records []record
+
+	for rows.Next() {
+		var (
+			rec     record
+			headers sql.NullString
+		)
+
+		err = rows.Scan(
+			&rec.seq, &rec.ID, &rec.Topic, &rec.Key, &rec.Payload, &headers, &rec.CreatedAt,
+			&rec.attempts,
+		)
+		if err != nil {
+			return nil, errors.Wrap(err, "scanning outbox message")
+		}
+
+		if headers.Valid {
+			err = json.Unmarshal([]byte(headers.String), &rec.Headers)
+			if err != nil {
+				return nil, errors.Wrapf(err, "decoding headers of outbox message %s", rec.ID)
+			}
+		}
+
+		records = append(records, rec)
+	}
+
+	return records, errors.Wrap(rows.Err(), "iterating outbox messages")
+}
+
+func (r Repository) markDelivered(ctx context.Context, rec record, now time.Time) error {
+	_, err := r.conn.Exec(
+		ctx,
+		"UPDATE "+r.table+" SET delivered_at = ?, attempts = ? WHERE id = ?",
+		now, rec.attempts+1, rec.seq,
+	)
+
+	return errors.Wrapf(err, "marking outbox message %s as delivered", rec.ID)
+}
+
+func (r Repository) markFailed(
+	ctx context.Context,
+	rec record,
+	nextAttempt time.Time,
+	cause error,
+) error {
+	_, err := r.conn.Exec(
+		ctx,
+		"UPDATE "+r.table+" SET attempts = ?, next_attempt_at = ?, last_error = ? WHERE id = ?",
+		rec.attempts+1, nextAttempt, cause.Error(), rec.seq,
+	)
+
+	return errors.Wrapf(err, "marking outbox message %s as failed", rec.ID)
+}
+
+func (r Repository) markDeadLettered(
+	ctx context.Context,
+	rec record,
+	now time.Time,
+	cause error,
+) error {
+	_, err := r.conn.Exec(
+		ctx,
+		"UPDATE "+r.table+" SET attempts = ?, dead_lettered_at = ?, last_error = ? WHERE id = ?",
+		rec.attempts+1, now, cause.Error(), rec.seq,
+	)
+
+	return errors.Wrapf(err, "marking outbox message %s as dead-lettered", rec.ID)
+}
+
+func newID() (string, error) {
+	id := make([]byte, idBytes)
+
+	_, err := rand.Read(id)
+	if err != nil {
+		return "", errors.Wrap(err, "generating message id")
+	}
+
+	return hex.EncodeToString(id), nil
+}
@@ -0,0 +1,230 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/beeemT/go-atomic/atomictest"
+	"github.com/beeemT/go-atomic/generic"
+	"github.com/beeemT/go-atomic/generic/adapter"
+	gsql "github.com/beeemT/go-atomic/generic/sql"
+	"github.com/beeemT/go-atomic/internal/sqlitetest"
+	"github.com/beeemT/go-atomic/outbox"
+)
+
+var errPublish = errors.New("publish failed")
+
+func newRelay(
+	t *testing.T,
+	publisher outbox.Publisher,
+	opts ...outbox.RelayOption[generic.SQLRemote],
+) (gsql.Executer, outbox.Relay[generic.SQLRemote]) {
+	t.Helper()
+
+	db := sqlitetest.Open(t, outbox.Schema(adapter.SQLite, outbox.DefaultTable)...)
+	executer := gsql.NewExecuter(db, gsql.WithDialect(adapter.SQLite))
+
+	relay := outbox.NewRelay[generic.SQLRemote](
+		executer,
+		func(tx generic.SQLRemote) outbox.Repository {
+			return outbox.NewRepository(adapter.SQL(tx, adapter.SQLite))
+		},
+		publisher,
+		opts...,
+	)
+
+	return executer, relay
+}
+
+func enqueue(t *testing.T, executer gsql.Executer, runErr error, msgs ...outbox.Message) {
+	t.Helper()
+
+	err := executer.Execute(context.Background(), func(tx generic.SQLRemote) error {
+		err := outbox.NewRepository(adapter.SQL(tx, adapter.SQLite)).
+			Enqueue(context.Background(), msgs...)
+		if err != nil {
+			t.Fatalf("enqueueing: %v", err)
+		}
+
+		return runErr
+	})
+	if !errors.Is(err, runErr) {
+		t.Fatalf("expected %v, got %v", runErr, err)
+	}
+}
+
+func runOnce(t *testing.T, relay outbox.Relay[generic.SQLRemote]) int {
+	t.Helper()
+
+	processed, err := relay.RunOnce(context.Background())
+	if err != nil {
+		t.Fatalf("relaying: %v", err)
+	}
+
+	return processed
+}
+
+func TestEnqueue(t *testing.T) {
+	var published []outbox.Message
+
+	executer, relay := newRelay(t, outbox.PublisherFunc(
+		func(_ context.Context, msg outbox.Message) error {
+			published = append(published, msg)
+
+			return nil
+		},
+	))
+
+	enqueue(t, executer, errors.New("rolled back"), outbox.Message{Topic: "rolled back"})
+	enqueue(t, executer, nil, outbox.Message{
+		Topic:   "committed",
+		Payload: []byte("payload"),
+		Headers: map[string]string{"header": "value"},
+	})
+
+	if processed := runOnce(t, relay); processed != 1 {
+		t.Fatalf("expected 1 processed message, got %d", processed)
+	}
+
+	if len(published) != 1 {
+		t.Fatalf("expected 1 published message, got %d", len(published))
+	}
+
+	msg := published[0]
+	if msg.Topic != "committed" || string(msg.Payload) != "payload" ||
+		msg.Headers["header"] != "value" || msg.ID == "" || msg.CreatedAt.IsZero() {
+		t.Fatalf("unexpected message %+v", msg)
+	}
+
+	if processed := runOnce(t, relay); processed != 0 {
+		t.Fatalf("expected delivered message not to be relayed again, got %d", processed)
+	}
+}
+
+func TestRelayOrdering(t *testing.T) {
+	var topics []string
+
+	executer, relay := newRelay(t, outbox.PublisherFunc(
+		func(_ context.Context, msg outbox.Message) error {
+			topics = append(topics, msg.Topic)
+
+			return nil
+		},
+	))
+
+	enqueue(t, executer, nil,
+		outbox.Message{Topic: "a1", Key: "a"},
+		outbox.Message{Topic: "a2", Key: "a"},
+		outbox.Message{Topic: "b1", Key: "b"},
+	)
+
+	runOnce(t, relay)
+	runOnce(t, relay)
+
+	expected := []string{"a1", "b1", "a2"}
+	if len(topics) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, topics)
+	}
+
+	for i := range expected {
+		if topics[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, topics)
+		}
+	}
+}
+
+func TestRelayRetryAndDeadLetter(t *testing.T) {
+	var (
+		attempts     int
+		deadLettered []outbox.Message
+		errs         []error
+	)
+
+	executer, relay := newRelay(
+		t,
+		outbox.PublisherFunc(func(context.Context, outbox.Message) error {
+			attempts++
+
+			return errPublish
+		}),
+		outbox.WithBackoffs[generic.SQLRemote](0),
+		outbox.WithMaxAttempts[generic.SQLRemote](3),
+		outbox.WithDeadLetterPublisher[generic.SQLRemote](outbox.PublisherFunc(
+			func(_ context.Context, msg outbox.Message) error {
+				deadLettered = append(deadLettered, msg)
+
+				return nil
+			},
+		)),
+		outbox.WithErrorHandler[generic.SQLRemote](func(err error) {
+			errs = append(errs, err)
+		}),
+	)
+
+	enqueue(t, executer, nil, outbox.Message{Topic: "failing"})
+
+	for i := 0; i < 4; i++ {
+		runOnce(t, relay)
+	}
+
+	if attempts != 3 {
+		t.Fatalf("expected 3 attempts, got %d", attempts)
+	}
+
+	if len(deadLettered) != 1 || deadLettered[0].Topic != "failing" {
+		t.Fatalf("expected message to be dead-lettered, got %v", deadLettered)
+	}
+
+	if len(errs) != 3 || !errors.Is(errs[0], errPublish) {
+		t.Fatalf("expected 3 publish errors, got %v", errs)
+	}
+}
+
+func TestRelayClock(t *testing.T) {
+	var published []outbox.Message
+
+	clock := atomictest.NewClock(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+	executer, relay := newRelay(
+		t,
+		outbox.PublisherFunc(func(_ context.Context, msg outbox.Message) error {
+			published = append(published, msg)
+			if len(published) == 1 {
+				return errPublish
+			}
+
+			return nil
+		}),
+		outbox.WithBackoffs[generic.SQLRemote](time.Hour),
+		outbox.WithRelayClock[generic.SQLRemote](clock),
+	)
+
+	err := executer.Execute(context.Background(), func(tx generic.SQLRemote) error {
+		return outbox.NewRepository(adapter.SQL(tx, adapter.SQLite), outbox.WithClock(clock)).
+			Enqueue(context.Background(), outbox.Message{Topic: "delayed"})
+	})
+	if err != nil {
+		t.Fatalf("enqueueing: %v", err)
+	}
+
+	if processed := runOnce(t, relay); processed != 1 {
+		t.Fatalf("expected 1 processed message, got %d", processed)
+	}
+
+	if !published[0].CreatedAt.Equal(clock.Now()) {
+		t.Fatalf("expected creation time %s, got %s", clock.Now(), published[0].CreatedAt)
+	}
+
+	clock.Advance(time.Hour - time.Second)
+
+	if processed := runOnce(t, relay); processed != 0 {
+		t.Fatalf("expected message not to be due before its backoff, got %d", processed)
+	}
+
+	clock.Advance(time.Second)
+
+	if processed := runOnce(t, relay); processed != 1 || len(published) != 2 {
+		t.Fatalf("expected message to be redelivered after its backoff, got %d", processed)
+	}
+}
@@ -0,0 +1,243 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/beeemT/go-atomic"
+	"github.com/beeemT/go-atomic/generic"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultBatchSize    = 100
+	defaultPollInterval = time.Second
+	defaultMaxAttempts  = 10
+)
+
+type (
+	// Publisher dispatches messages from the outbox to the message broker.
+	// Messages are delivered at least once, Publish has to be safe to be called multiple times for
+	// the same message.
+	Publisher interface {
+		Publish(ctx context.Context, msg Message) error
+	}
+
+	// PublisherFunc implements [Publisher] with a function.
+	PublisherFunc func(ctx context.Context, msg Message) error
+
+	// Relay polls the outbox table for due messages and dispatches them to a [Publisher].
+	// Messages are locked while being published, so multiple relays can poll the same table
+	// concurrently.
+	Relay[Remote any] struct {
+		executer   generic.Executer[Remote]
+		repository func(Remote) Repository
+		publisher  Publisher
+		deadLetter Publisher
+		onError    func(error)
+		clock      atomic.Clock
+
+		batchSize    int
+		pollInterval time.Duration
+		backoffs     []time.Duration
+		maxAttempts  int
+	}
+
+	// RelayOption configures the [Relay] instance.
+	RelayOption[Remote any] func(*Relay[Remote])
+)
+
+var _ Publisher = PublisherFunc(nil)
+
+// Publish implements [Publisher].
+func (f PublisherFunc) Publish(ctx context.Context, msg Message) error {
+	return f(ctx, msg)
+}
+
+// WithBatchSize sets the maximum number of messages dispatched per poll.
+func WithBatchSize[Remote any](batchSize int) RelayOption[Remote] {
+	return func(r *Relay[Remote]) {
+		r.batchSize = batchSize
+	}
+}
+
+// WithPollInterval sets the interval between polls if the previous poll did not fill a batch.
+func WithPollInterval[Remote any](interval time.Duration) RelayOption[Remote] {
+	return func(r *Relay[Remote]) {
+		r.pollInterval = interval
+	}
+}
+
+// WithBackoffs sets the delays before redelivering a message after failed attempts.
+// The delay after the nth failed attempt is backoffs[n-1], the last backoff is used for all
+// further attempts.
+func WithBackoffs[Remote any](backoffs ...time.Duration) RelayOption[Remote] {
+	return func(r *Relay[Remote]) {
+		r.backoffs = backoffs
+	}
+}
+
+// WithMaxAttempts sets the number of failed attempts after which a message is dead-lettered.
+func WithMaxAttempts[Remote any](maxAttempts int) RelayOption[Remote] {
+	return func(r *Relay[Remote]) {
+		r.maxAttempts = maxAttempts
+	}
+}
+
+// WithDeadLetterPublisher sets a publisher which receives messages when they are dead-lettered.
+// Dead-lettered messages are marked in the outbox table regardless of this option.
+func WithDeadLetterPublisher[Remote any](publisher Publisher) RelayOption[Remote] {
+	return func(r *Relay[Remote]) {
+		r.deadLetter = publisher
+	}
+}
+
+// WithErrorHandler sets a function which is called with errors of failed publishes and polls.
+// Errors do not stop [Relay.Run].
+func WithErrorHandler[Remote any](onError func(error)) RelayOption[Remote] {
+	return func(r *Relay[Remote]) {
+		r.onError = onError
+	}
+}
+
+// WithRelayClock sets the clock used for polling and for scheduling redeliveries, eg a fake clock
+// in tests.
+func WithRelayClock[Remote any](clock atomic.Clock) RelayOption[Remote] {
+	return func(r *Relay[Remote]) {
+		r.clock = clock
+	}
+}
+
+// NewRelay creates a new Relay.
+// repository creates the outbox repository from the Remote of the transaction opened by executer.
+//
+// By default:
+//   - dispatches up to 100 messages per poll.
+//   - polls every second.
+//   - uses [atomic.DefaultBackoffs] as delays between attempts.
+//   - dead-letters messages after 10 failed attempts.
+//   - uses [atomic.SystemClock] as clock.
+func NewRelay[Remote any](
+	executer generic.Executer[Remote],
+	repository func(Remote) Repository,
+	publisher Publisher,
+	opts ...RelayOption[Remote],
+) Relay[Remote] {
+	relay := Relay[Remote]{
+		executer:     executer,
+		repository:   repository,
+		publisher:    publisher,
+		onError:      func(error) {},
+		clock:        atomic.SystemClock{},
+		batchSize:    defaultBatchSize,
+		pollInterval: defaultPollInterval,
+		backoffs:     atomic.DefaultBackoffs,
+		maxAttempts:  defaultMaxAttempts,
+	}
+
+	for _, opt := range opts {
+		opt(&relay)
+	}
+
+	return relay
+}
+
+// Run polls the outbox until ctx is done.
+// The next poll starts immediately if the previous one filled a batch.
+func (r Relay[Remote]) Run(ctx context.Context) error {
+	for {
+		processed, err := r.RunOnce(ctx)
+		if err != nil {
+			r.onError(err)
+		}
+
+		if processed >= r.batchSize && err == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "running outbox relay")
+		case <-r.clock.After(r.pollInterval):
+		}
+	}
+}
+
+// RunOnce dispatches one batch of due messages and returns the number of processed messages.
+// Messages failing to publish are rescheduled according to the backoffs or dead-lettered.
+func (r Relay[Remote]) RunOnce(ctx context.Context) (int, error) {
+	var processed int
+
+	err := r.executer.Execute(ctx, func(tx Remote) error {
+		repository := r.repository(tx)
+		now := r.clock.Now().UTC()
+
+		records, err := repository.claim(ctx, now, r.batchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, rec := range records {
+			err = r.dispatch(ctx, repository, rec, now)
+			if err != nil {
+				return err
+			}
+		}
+
+		processed = len(records)
+
+		return nil
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "relaying outbox messages")
+	}
+
+	return processed, nil
+}
+
+func (r Relay[Remote]) dispatch(
+	ctx context.Context,
+	repository Repository,
+	rec record,
+	now time.Time,
+) error {
+	err := r.publisher.Publish(ctx, rec.Message)
+	if err == nil {
+		return repository.markDelivered(ctx, rec, now)
+	}
+
+	err = errors.Wrapf(err, "publishing outbox message %s", rec.ID)
+	r.onError(err)
+
+	if rec.attempts+1 < r.maxAttempts {
+		return repository.markFailed(ctx, rec, now.Add(r.backoff(rec.attempts)), err)
+	}
+
+	if r.deadLetter != nil {
+		dlqErr := r.deadLetter.Publish(ctx, rec.Message)
+		if dlqErr != nil {
+			// keep the message pending, so dead-lettering is attempted again on the next poll
+			return repository.markFailed(
+				ctx,
+				rec,
+				now.Add(r.backoff(rec.attempts)),
+				errors.Wrapf(dlqErr, "dead-lettering outbox message %s", rec.ID),
+			)
+		}
+	}
+
+	return repository.markDeadLettered(ctx, rec, now, err)
+}
+
+// backoff returns the delay after the given number of previous attempts has failed once more.
+func (r Relay[Remote]) backoff(previousAttempts int) time.Duration {
+	if len(r.backoffs) == 0 {
+		return 0
+	}
+
+	if previousAttempts >= len(r.backoffs) {
+		return r.backoffs[len(r.backoffs)-1]
+	}
+
+	return r.backoffs[previousAttempts]
+}
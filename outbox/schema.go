@@ -0,0 +1,68 @@
+package outbox
+
+import "github.com/beeemT/go-atomic/generic/adapter"
+
+// Schema returns the statements creating the outbox table with the given name and its indexes.
+func Schema(dialect adapter.Dialect, table string) []string {
+	switch dialect {
+	case adapter.MySQL:
+		return []string{
+			"CREATE TABLE IF NOT EXISTS " + table + " (" +
+				"id BIGINT AUTO_INCREMENT PRIMARY KEY, " +
+				"message_id VARCHAR(255) NOT NULL, " +
+				"topic VARCHAR(255) NOT NULL, " +
+				"ordering_key VARCHAR(255) NOT NULL DEFAULT '', " +
+				"payload LONGBLOB, " +
+				"headers TEXT, " +
+				"created_at DATETIME(6) NOT NULL, " +
+				"attempts INT NOT NULL DEFAULT 0, " +
+				"next_attempt_at DATETIME(6) NOT NULL, " +
+				"delivered_at DATETIME(6) NULL, " +
+				"dead_lettered_at DATETIME(6) NULL, " +
+				"last_error TEXT, " +
+				"INDEX " + table + "_pending_idx " +
+				"(delivered_at, dead_lettered_at, next_attempt_at), " +
+				"INDEX " + table + "_ordering_idx (ordering_key, id))",
+		}
+	case adapter.SQLite:
+		return append([]string{
+			"CREATE TABLE IF NOT EXISTS " + table + " (" +
+				"id INTEGER PRIMARY KEY AUTOINCREMENT, " +
+				"message_id TEXT NOT NULL, " +
+				"topic TEXT NOT NULL, " +
+				"ordering_key TEXT NOT NULL DEFAULT '', " +
+				"payload BLOB, " +
+				"headers TEXT, " +
+				"created_at TIMESTAMP NOT NULL, " +
+				"attempts INTEGER NOT NULL DEFAULT 0, " +
+				"next_attempt_at TIMESTAMP NOT NULL, " +
+				"delivered_at TIMESTAMP, " +
+				"dead_lettered_at TIMESTAMP, " +
+				"last_error TEXT)",
+		}, indexes(table)...)
+	}
+
+	return append([]string{
+		"CREATE TABLE IF NOT EXISTS " + table + " (" +
+			"id BIGSERIAL PRIMARY KEY, " +
+			"message_id TEXT NOT NULL, " +
+			"topic TEXT NOT NULL, " +
+			"ordering_key TEXT NOT NULL DEFAULT '', " +
+			"payload BYTEA, " +
+			"headers TEXT, " +
+			"created_at TIMESTAMPTZ NOT NULL, " +
+			"attempts INTEGER NOT NULL DEFAULT 0, " +
+			"next_attempt_at TIMESTAMPTZ NOT NULL, " +
+			"delivered_at TIMESTAMPTZ, " +
+			"dead_lettered_at TIMESTAMPTZ, " +
+			"last_error TEXT)",
+	}, indexes(table)...)
+}
+
+func indexes(table string) []string {
+	return []string{
+		"CREATE INDEX IF NOT EXISTS " + table + "_pending_idx ON " + table +
+			" (next_attempt_at) WHERE delivered_at IS NULL AND dead_lettered_at IS NULL",
+		"CREATE INDEX IF NOT EXISTS " + table + "_ordering_idx ON " + table + " (ordering_key, id)",
+	}
+}
@@ -26,8 +26,24 @@ var DefaultBackoffs = []time.Duration{
 // - context.DeadlineExceeded
 // - net.ErrClosed
 // - os.ErrDeadlineExceeded
+// - a [TimeoutError] which is retryable, ie lock timeouts
+// Errors with [ErrCommitUnknown] in their chain are never retried, neither are statement and idle
+// in transaction timeouts. Errors in the chain implementing Retryable() bool, like [TimeoutError],
+// decide themselves whether they are retried.
 // It retries for a maximum of len(backoffs) times.
 func DefaultRetry(backoffs []time.Duration, run func() error) error {
+	return retry(SystemClock{}, backoffs, run)
+}
+
+// RetryWithClock returns [DefaultRetry] waiting for the backoffs with clock instead of the system
+// clock.
+func RetryWithClock(clock Clock) func(backoffs []time.Duration, run func() error) error {
+	return func(backoffs []time.Duration, run func() error) error {
+		return retry(clock, backoffs, run)
+	}
+}
+
+func retry(clock Clock, backoffs []time.Duration, run func() error) error {
 	var (
 		i    int
 		merr error
@@ -36,7 +52,7 @@ func DefaultRetry(backoffs []time.Duration, run func() error) error {
 	err := run()
 	for i = 0; isRetryable(err) && i < len(backoffs); i++ {
 		merr = multierr.Append(merr, errors.Wrapf(err, "try %d", i))
-		time.Sleep(backoffs[i])
+		<-clock.After(backoffs[i])
 		err = run()
 	}
 
@@ -50,7 +66,13 @@ func DefaultRetry(backoffs []time.Duration, run func() error) error {
 }
 
 func isRetryable(err error) bool {
+	var retryable interface{ Retryable() bool }
+
 	switch {
+	case errors.Is(err, ErrCommitUnknown):
+		return false
+	case errors.As(err, &retryable):
+		return retryable.Retryable()
 	case errors.Is(err, context.DeadlineExceeded),
 		errors.Is(err, net.ErrClosed),
 		errors.Is(err, os.ErrDeadlineExceeded):
@@ -0,0 +1,484 @@
+// Package saga orchestrates flows spanning remote systems which do not take part in transactions.
+// A saga consists of steps declaring an action and a compensation. The state of a saga is
+// persisted through a [generic.Transacter] after every step. If an action fails, the compensations
+// of all completed steps are run in reverse order. Sagas of crashed processes are resumed by
+// [Orchestrator.Recover].
+//
+// Actions and compensations are executed at least once, as a crash between running a step and
+// persisting the state reruns the step on recovery. They should therefore be idempotent.
+package saga
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/beeemT/go-atomic"
+	"github.com/beeemT/go-atomic/generic"
+	"github.com/beeemT/go-atomic/generic/adapter"
+	"github.com/beeemT/go-atomic/internal/sqlgen"
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+)
+
+const (
+	// DefaultTable is the default name of the saga table.
+	DefaultTable = "atomic_sagas"
+	// DefaultLease is the default duration for which an orchestrator owns a saga without
+	// persisting progress.
+	DefaultLease = 5 * time.Minute
+
+	ownerBytes       = 16
+	recoverBatchSize = 100
+)
+
+const (
+	// StatusRunning is the status of sagas executing their actions.
+	StatusRunning Status = "running"
+	// StatusCompensating is the status of sagas executing compensations after a failed action.
+	StatusCompensating Status = "compensating"
+	// StatusCompleted is the status of sagas which executed all actions successfully.
+	StatusCompleted Status = "completed"
+	// StatusCompensated is the status of sagas which executed all compensations after a failed
+	// action.
+	StatusCompensated Status = "compensated"
+)
+
+var (
+	// ErrExists is returned by [Orchestrator.Start] if a saga with the same id exists.
+	ErrExists = errors.New("saga exists")
+	// ErrNotClaimable is returned by [Orchestrator.Resume] if the saga does not exist, is
+	// finished or is owned by another orchestrator.
+	ErrNotClaimable = errors.New("saga not claimable")
+	// ErrLeaseLost is returned if another orchestrator took over a saga, because its lease
+	// expired.
+	ErrLeaseLost = errors.New("saga lease lost")
+	// ErrCompensated is returned if an action of a saga failed and the saga was compensated.
+	ErrCompensated = errors.New("saga compensated")
+)
+
+type (
+	// Status is the status of a saga.
+	Status string
+
+	// Step is a step of a saga.
+	Step[Data any] struct {
+		// Name identifies the step in errors.
+		Name string
+		// Action performs the step. Changes to data are persisted after Action succeeded.
+		Action func(ctx context.Context, data *Data) error
+		// Compensate undoes Action. It is optional for steps which need no compensation.
+		Compensate func(ctx context.Context, data *Data) error
+	}
+
+	// Orchestrator runs the sagas of one kind, which share the same steps.
+	Orchestrator[Remote any, Resources any, Data any] struct {
+		transacter generic.Transacter[Remote, Resources]
+		conn       func(Remote) adapter.Conn
+		name       string
+		steps      []Step[Data]
+		config     config
+	}
+
+	// Option configures the [Orchestrator] instance.
+	Option func(*config)
+
+	config struct {
+		table   string
+		lease   time.Duration
+		owner   string
+		onError func(error)
+		clock   atomic.Clock
+	}
+
+	state[Data any] struct {
+		id     string
+		status Status
+		step   int
+		data   Data
+		err    string
+	}
+)
+
+// WithTable sets the name of the saga table.
+func WithTable(table string) Option {
+	return func(c *config) {
+		c.table = table
+	}
+}
+
+// WithLease sets the duration for which an orchestrator owns a saga after persisting progress.
+// Sagas whose lease expired are resumed by [Orchestrator.Recover], so the lease has to be longer
+// than the longest running action or compensation.
+func WithLease(lease time.Duration) Option {
+	return func(c *config) {
+		c.lease = lease
+	}
+}
+
+// WithErrorHandler sets a function which is called with errors of sagas resumed by
+// [Orchestrator.Recover] and [Orchestrator.RunRecovery].
+func WithErrorHandler(onError func(error)) Option {
+	return func(c *config) {
+		c.onError = onError
+	}
+}
+
+// WithClock sets the clock used for the leases of sagas and for waiting between recoveries, eg a
+// fake clock in tests.
+func WithClock(clock atomic.Clock) Option {
+	return func(c *config) {
+		c.clock = clock
+	}
+}
+
+// NewOrchestrator creates a new Orchestrator for the sagas named name consisting of steps.
+// conn creates the connection used to persist the sagas from the Remote of the transaction, eg
+// with [adapter.SQL]. Data is serialized with encoding/json.
+//
+// By default:
+//   - uses [DefaultTable] as saga table.
+//   - uses [DefaultLease] as lease.
+//   - uses the clock of transacter, see [generic.Transacter.Clock].
+func NewOrchestrator[Remote any, Resources any, Data any](
+	transacter generic.Transacter[Remote, Resources],
+	conn func(Remote) adapter.Conn,
+	name string,
+	steps []Step[Data],
+	opts ...Option,
+) (Orchestrator[Remote, Resources, Data], error) {
+	owner := make([]byte, ownerBytes)
+
+	_, err := rand.Read(owner)
+	if err != nil {
+		return Orchestrator[Remote, Resources, Data]{}, errors.Wrap(err, "generating owner id")
+	}
+
+	orchestrator := Orchestrator[Remote, Resources, Data]{
+		transacter: transacter,
+		conn:       conn,
+		name:       name,
+		steps:      steps,
+		config: config{
+			table:   DefaultTable,
+			lease:   DefaultLease,
+			owner:   hex.EncodeToString(owner),
+			onError: func(error) {},
+			clock:   transacter.Clock(),
+		},
+	}
+
+	for _, opt := range opts {
+		opt(&orchestrator.config)
+	}
+
+	return orchestrator, nil
+}
+
+// Start persists a new saga with the given id and data and executes it.
+// It returns an error matching [ErrCompensated] and the error of the failed action if the saga was
+// compensated.
+// Start should not be called within a Transact block, as the progress of the saga has to be
+// committed after every step.
+func (o Orchestrator[Remote, Resources, Data]) Start(
+	ctx context.Context,
+	id string,
+	data Data,
+) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return errors.Wrapf(err, "encoding data of saga %s", id)
+	}
+
+	err = o.inTx(ctx, func(ctx context.Context, conn adapter.Conn) error {
+		now := o.config.clock.Now().UTC()
+
+		inserted, err := conn.Exec(
+			ctx,
+			sqlgen.InsertIgnore(
+				conn.Dialect(),
+				o.config.table,
+				"id", "saga_name", "status", "step", "data", "locked_by", "locked_until",
+				"created_at", "updated_at",
+			),
+			id, o.name, string(StatusRunning), 0, string(encoded), o.config.owner,
+			now.Add(o.config.lease), now, now,
+		)
+		if err != nil {
+			return errors.Wrap(err, "inserting saga")
+		}
+
+		if inserted == 0 {
+			return ErrExists
+		}
+
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "starting saga %s", id)
+	}
+
+	return o.execute(ctx, &state[Data]{
+		id:     id,
+		status: StatusRunning,
+		data:   data,
+	})
+}
+
+// Resume claims the unfinished saga with the given id and continues executing it.
+// It returns [ErrNotClaimable] if the saga is owned by another orchestrator whose lease did not
+// expire yet.
+func (o Orchestrator[Remote, Resources, Data]) Resume(ctx context.Context, id string) error {
+	s := state[Data]{id: id}
+
+	err := o.inTx(ctx, func(ctx context.Context, conn adapter.Conn) error {
+		now := o.config.clock.Now().UTC()
+
+		claimed, err := conn.Exec(
+			ctx,
+			"UPDATE "+o.config.table+" SET locked_by = ?, locked_until = ? "+
+				"WHERE id = ? AND saga_name = ? AND status IN (?, ?) "+
+				"AND (locked_until < ? OR locked_by = ?)",
+			o.config.owner, now.Add(o.config.lease),
+			id, o.name, string(StatusRunning), string(StatusCompensating),
+			now, o.config.owner,
+		)
+		if err != nil {
+			return errors.Wrap(err, "claiming saga")
+		}
+
+		if claimed == 0 {
+			return ErrNotClaimable
+		}
+
+		return o.load(ctx, conn, &s)
+	})
+	if err != nil {
+		return errors.Wrapf(err, "resuming saga %s", id)
+	}
+
+	return o.execute(ctx, &s)
+}
+
+// Recover resumes all unfinished sagas whose lease expired, eg because the orchestrator executing
+// them crashed. It returns the number of resumed sagas. Errors of individual sagas are passed to
+// the error handler.
+func (o Orchestrator[Remote, Resources, Data]) Recover(ctx context.Context) (int, error) {
+	var ids []string
+
+	err := o.inTx(ctx, func(ctx context.Context, conn adapter.Conn) (err error) {
+		rows, err := conn.Query(
+			ctx,
+			"SELECT id FROM "+o.config.table+" WHERE saga_name = ? AND status IN (?, ?) "+
+				"AND locked_until < ? ORDER BY updated_at LIMIT ?",
+			o.name, string(StatusRunning), string(StatusCompensating), o.config.clock.Now().UTC(),
+			recoverBatchSize,
+		)
+		if err != nil {
+			return errors.Wrap(err, "selecting stale sagas")
+		}
+		defer func() {
+			err = multierr.Append(err, errors.Wrap(rows.Close(), "closing rows"))
+		}()
+
+		for rows.Next() {
+			var id string
+
+			err = rows.Scan(&id)
+			if err != nil {
+				return errors.Wrap(err, "scanning saga id")
+			}
+
+			ids = append(ids, id)
+		}
+
+		return errors.Wrap(rows.Err(), "iterating stale sagas")
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "recovering sagas")
+	}
+
+	for _, id := range ids {
+		err = o.Resume(ctx, id)
+		if err != nil && !errors.Is(err, ErrCompensated) {
+			o.config.onError(err)
+		}
+	}
+
+	return len(ids), nil
+}
+
+// RunRecovery calls [Orchestrator.Recover] every interval until ctx is done.
+func (o Orchestrator[Remote, Resources, Data]) RunRecovery(
+	ctx context.Context,
+	interval time.Duration,
+) error {
+	for {
+		_, err := o.Recover(ctx)
+		if err != nil {
+			o.config.onError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "running saga recovery")
+		case <-o.config.clock.After(interval):
+		}
+	}
+}
+
+// execute runs the remaining actions or compensations of s and persists the progress after
+// every step.
+func (o Orchestrator[Remote, Resources, Data]) execute(ctx context.Context, s *state[Data]) error {
+	var actionErr error
+
+	for s.status == StatusRunning && s.step < len(o.steps) {
+		step := o.steps[s.step]
+
+		err := step.Action(ctx, &s.data)
+		if err != nil {
+			actionErr = errors.Wrapf(err, "running action of step %s", step.Name)
+			s.status = StatusCompensating
+			s.err = actionErr.Error()
+		} else {
+			s.step++
+		}
+
+		err = o.persist(ctx, s)
+		if err != nil {
+			return multierr.Append(actionErr, err)
+		}
+	}
+
+	if s.status == StatusRunning {
+		s.status = StatusCompleted
+
+		return o.persist(ctx, s)
+	}
+
+	for s.status == StatusCompensating && s.step > 0 {
+		step := o.steps[s.step-1]
+
+		if step.Compensate != nil {
+			err := step.Compensate(ctx, &s.data)
+			if err != nil {
+				// the saga stays in compensation and is resumed by recovery once the lease expired
+				return multierr.Append(
+					actionErr,
+					errors.Wrapf(err, "running compensation of step %s", step.Name),
+				)
+			}
+		}
+
+		s.step--
+
+		err := o.persist(ctx, s)
+		if err != nil {
+			return multierr.Append(actionErr, err)
+		}
+	}
+
+	s.status = StatusCompensated
+
+	err := o.persist(ctx, s)
+	if err != nil {
+		return multierr.Append(actionErr, err)
+	}
+
+	if actionErr == nil {
+		actionErr = errors.New(s.err)
+	}
+
+	return multierr.Append(errors.Wrapf(ErrCompensated, "saga %s", s.id), actionErr)
+}
+
+// persist stores s and renews the lease if the orchestrator still owns the saga.
+func (o Orchestrator[Remote, Resources, Data]) persist(ctx context.Context, s *state[Data]) error {
+	encoded, err := json.Marshal(s.data)
+	if err != nil {
+		return errors.Wrapf(err, "encoding data of saga %s", s.id)
+	}
+
+	err = o.inTx(ctx, func(ctx context.Context, conn adapter.Conn) error {
+		now := o.config.clock.Now().UTC()
+
+		updated, err := conn.Exec(
+			ctx,
+			"UPDATE "+o.config.table+" SET status = ?, step = ?, data = ?, error = ?, "+
+				"locked_until = ?, updated_at = ? WHERE id = ? AND locked_by = ?",
+			string(s.status), s.step, string(encoded),
+			sql.NullString{String: s.err, Valid: s.err != ""},
+			now.Add(o.config.lease), now, s.id, o.config.owner,
+		)
+		if err != nil {
+			return errors.Wrap(err, "updating saga")
+		}
+
+		if updated == 0 {
+			return ErrLeaseLost
+		}
+
+		return nil
+	})
+
+	return errors.Wrapf(err, "persisting saga %s", s.id)
+}
+
+func (o Orchestrator[Remote, Resources, Data]) load(
+	ctx context.Context,
+	conn adapter.Conn,
+	s *state[Data],
+) (err error) {
+	rows, err := conn.Query(
+		ctx,
+		"SELECT status, step, data, error FROM "+o.config.table+" WHERE id = ?",
+		s.id,
+	)
+	if err != nil {
+		return errors.Wrap(err, "selecting saga")
+	}
+	defer func() {
+		err = multierr.Append(err, errors.Wrap(rows.Close(), "closing rows"))
+	}()
+
+	if !rows.Next() {
+		return multierr.Append(ErrNotClaimable, errors.Wrap(rows.Err(), "selecting saga"))
+	}
+
+	var (
+		status  string
+		data    string
+		failure sql.NullString
+	)
+
+	err = rows.Scan(&status, &s.step, &data, &failure)
+	if err != nil {
+		return errors.Wrap(err, "scanning saga")
+	}
+
+	s.status = Status(status)
+	s.err = failure.String
+
+	return errors.Wrap(json.Unmarshal([]byte(data), &s.data), "decoding saga data")
+}
+
+// inTx runs run in a transaction of the transacter with the connection of its session.
+func (o Orchestrator[Remote, Resources, Data]) inTx(
+	ctx context.Context,
+	run func(context.Context, adapter.Conn) error,
+) error {
+	return errors.Wrap(
+		o.transacter.Transact(ctx, func(ctx context.Context, _ Resources) error {
+			session, err := generic.RequireSession[Remote](ctx)
+			if err != nil {
+				return err //nolint:wrapcheck // sentinel
+			}
+
+			return run(ctx, o.conn(session.Tx))
+		}),
+		"running saga transaction",
+	)
+}
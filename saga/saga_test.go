@@ -0,0 +1,285 @@
+package saga_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/beeemT/go-atomic/atomictest"
+	"github.com/beeemT/go-atomic/generic"
+	"github.com/beeemT/go-atomic/generic/adapter"
+	gsql "github.com/beeemT/go-atomic/generic/sql"
+	"github.com/beeemT/go-atomic/internal/sqlitetest"
+	"github.com/beeemT/go-atomic/saga"
+)
+
+type (
+	// trip is the data of the test sagas, its steps record their actions in Booked.
+	trip struct {
+		Booked []string
+	}
+
+	// sagaRow is the persisted state of a saga.
+	sagaRow struct {
+		status string
+		step   int
+		data   trip
+		err    sql.NullString
+	}
+)
+
+var errAction = errors.New("action failed")
+
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	return sqlitetest.Open(t, saga.Schema(adapter.SQLite, saga.DefaultTable)...)
+}
+
+func newOrchestrator(
+	t *testing.T,
+	db *sql.DB,
+	steps []saga.Step[trip],
+	opts ...saga.Option,
+) saga.Orchestrator[generic.SQLRemote, struct{}, trip] {
+	t.Helper()
+
+	orchestrator, err := saga.NewOrchestrator(
+		generic.NewTransacter[generic.SQLRemote, struct{}](
+			gsql.NewExecuter(db),
+			func(
+				context.Context,
+				*generic.Transacter[generic.SQLRemote, struct{}],
+				generic.SQLRemote,
+			) (struct{}, error) {
+				return struct{}{}, nil
+			},
+		),
+		func(tx generic.SQLRemote) adapter.Conn {
+			return adapter.SQL(tx, adapter.SQLite)
+		},
+		"trip",
+		steps,
+		opts...,
+	)
+	if err != nil {
+		t.Fatalf("creating orchestrator: %v", err)
+	}
+
+	return orchestrator
+}
+
+// book returns a step booking name and recording its actions and compensations in calls.
+func book(name string, calls *[]string) saga.Step[trip] {
+	return saga.Step[trip]{
+		Name: name,
+		Action: func(_ context.Context, data *trip) error {
+			*calls = append(*calls, "book "+name)
+			data.Booked = append(data.Booked, name)
+
+			return nil
+		},
+		Compensate: func(_ context.Context, data *trip) error {
+			*calls = append(*calls, "cancel "+name)
+			data.Booked = slices.DeleteFunc(data.Booked, func(booked string) bool {
+				return booked == name
+			})
+
+			return nil
+		},
+	}
+}
+
+func load(t *testing.T, db *sql.DB, id string) sagaRow {
+	t.Helper()
+
+	var (
+		row  sagaRow
+		data string
+	)
+
+	err := db.QueryRow(
+		"SELECT status, step, data, error FROM "+saga.DefaultTable+" WHERE id = ?",
+		id,
+	).Scan(&row.status, &row.step, &data, &row.err)
+	if err != nil {
+		t.Fatalf("loading saga %s: %v", id, err)
+	}
+
+	err = json.Unmarshal([]byte(data), &row.data)
+	if err != nil {
+		t.Fatalf("decoding saga %s: %v", id, err)
+	}
+
+	return row
+}
+
+func TestStepsPersisted(t *testing.T) {
+	db := openDB(t)
+
+	var calls []string
+
+	steps := []saga.Step[trip]{book("flight", &calls), book("hotel", &calls)}
+	steps = append(steps, saga.Step[trip]{
+		Name: "check",
+		Action: func(context.Context, *trip) error {
+			row := load(t, db, "1")
+			if row.status != "running" || row.step != 2 ||
+				!reflect.DeepEqual(row.data.Booked, []string{"flight", "hotel"}) {
+				t.Errorf("expected progress of previous steps to be persisted, got %+v", row)
+			}
+
+			return nil
+		},
+	})
+
+	err := newOrchestrator(t, db, steps).Start(context.Background(), "1", trip{})
+	if err != nil {
+		t.Fatalf("starting saga: %v", err)
+	}
+
+	row := load(t, db, "1")
+	if row.status != "completed" || row.step != 3 || row.err.Valid {
+		t.Fatalf("expected completed saga, got %+v", row)
+	}
+
+	err = newOrchestrator(t, db, steps).Start(context.Background(), "1", trip{})
+	if !errors.Is(err, saga.ErrExists) {
+		t.Fatalf("expected ErrExists starting saga twice, got %v", err)
+	}
+}
+
+func TestCompensation(t *testing.T) {
+	db := openDB(t)
+
+	var calls []string
+
+	steps := []saga.Step[trip]{
+		book("flight", &calls),
+		{
+			Name: "insurance",
+			Action: func(_ context.Context, data *trip) error {
+				calls = append(calls, "book insurance")
+				data.Booked = append(data.Booked, "insurance")
+
+				return nil
+			},
+		},
+		book("hotel", &calls),
+		{
+			Name: "car",
+			Action: func(context.Context, *trip) error {
+				calls = append(calls, "book car")
+
+				return errAction
+			},
+			Compensate: func(context.Context, *trip) error {
+				t.Error("expected compensation of the failed step not to run")
+
+				return nil
+			},
+		},
+	}
+
+	err := newOrchestrator(t, db, steps).Start(context.Background(), "1", trip{})
+	if !errors.Is(err, saga.ErrCompensated) || !errors.Is(err, errAction) {
+		t.Fatalf("expected ErrCompensated and the action error, got %v", err)
+	}
+
+	expected := []string{"book flight", "book insurance", "book hotel", "book car",
+		"cancel hotel", "cancel flight"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Fatalf("expected calls %v, got %v", expected, calls)
+	}
+
+	row := load(t, db, "1")
+	if row.status != "compensated" || row.step != 0 || !row.err.Valid ||
+		!reflect.DeepEqual(row.data.Booked, []string{"insurance"}) {
+		t.Fatalf("expected compensated saga with error, got %+v", row)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	db := openDB(t)
+	clock := atomictest.NewClock(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+	ctx, crash := context.WithCancel(context.Background())
+
+	var calls []string
+
+	// the first orchestrator crashes after the action of the hotel step, before persisting it
+	crashing := []saga.Step[trip]{book("flight", &calls), {
+		Name: "hotel",
+		Action: func(context.Context, *trip) error {
+			calls = append(calls, "book hotel")
+			crash()
+
+			return nil
+		},
+	}}
+
+	err := newOrchestrator(t, db, crashing, saga.WithClock(clock)).Start(ctx, "1", trip{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected crash, got %v", err)
+	}
+
+	var recoverErrs []error
+
+	steps := []saga.Step[trip]{book("flight", &calls), book("hotel", &calls), book("car", &calls)}
+	orchestrator := newOrchestrator(t, db, steps,
+		saga.WithClock(clock),
+		saga.WithErrorHandler(func(err error) {
+			recoverErrs = append(recoverErrs, err)
+		}),
+	)
+
+	resumed, err := orchestrator.Recover(context.Background())
+	if err != nil || resumed != 0 {
+		t.Fatalf("expected saga with lease not to be recovered, got %d, %v", resumed, err)
+	}
+
+	err = orchestrator.Resume(context.Background(), "1")
+	if !errors.Is(err, saga.ErrNotClaimable) {
+		t.Fatalf("expected ErrNotClaimable resuming leased saga, got %v", err)
+	}
+
+	clock.Advance(saga.DefaultLease + time.Second)
+
+	resumed, err = orchestrator.Recover(context.Background())
+	if err != nil || resumed != 1 || len(recoverErrs) != 0 {
+		t.Fatalf("expected saga to be recovered, got %d, %v, %v", resumed, err, recoverErrs)
+	}
+
+	// the hotel step is repeated as its progress was not persisted
+	expected := []string{"book flight", "book hotel", "book hotel", "book car"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Fatalf("expected calls %v, got %v", expected, calls)
+	}
+
+	row := load(t, db, "1")
+	if row.status != "completed" ||
+		!reflect.DeepEqual(row.data.Booked, []string{"flight", "hotel", "car"}) {
+		t.Fatalf("expected completed saga, got %+v", row)
+	}
+
+	// finished sagas are neither recovered nor resumed again
+	clock.Advance(saga.DefaultLease + time.Second)
+
+	resumed, err = orchestrator.Recover(context.Background())
+	if err != nil || resumed != 0 {
+		t.Fatalf("expected finished saga not to be recovered, got %d, %v", resumed, err)
+	}
+
+	err = orchestrator.Resume(context.Background(), "1")
+	if !errors.Is(err, saga.ErrNotClaimable) {
+		t.Fatalf("expected ErrNotClaimable resuming finished saga, got %v", err)
+	}
+
+	if !reflect.DeepEqual(calls, expected) {
+		t.Fatalf("expected no further calls, got %v", calls)
+	}
+}
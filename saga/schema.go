@@ -0,0 +1,56 @@
+package saga
+
+import "github.com/beeemT/go-atomic/generic/adapter"
+
+// Schema returns the statements creating the saga table with the given name and its indexes.
+func Schema(dialect adapter.Dialect, table string) []string {
+	switch dialect {
+	case adapter.MySQL:
+		return []string{
+			"CREATE TABLE IF NOT EXISTS " + table + " (" +
+				"id VARCHAR(255) PRIMARY KEY, " +
+				"saga_name VARCHAR(255) NOT NULL, " +
+				"status VARCHAR(32) NOT NULL, " +
+				"step INT NOT NULL, " +
+				"data LONGTEXT NOT NULL, " +
+				"error TEXT, " +
+				"locked_by VARCHAR(64) NOT NULL, " +
+				"locked_until DATETIME(6) NOT NULL, " +
+				"created_at DATETIME(6) NOT NULL, " +
+				"updated_at DATETIME(6) NOT NULL, " +
+				"INDEX " + table + "_pending_idx (saga_name, status, locked_until))",
+		}
+	case adapter.SQLite:
+		return []string{
+			"CREATE TABLE IF NOT EXISTS " + table + " (" +
+				"id TEXT PRIMARY KEY, " +
+				"saga_name TEXT NOT NULL, " +
+				"status TEXT NOT NULL, " +
+				"step INTEGER NOT NULL, " +
+				"data TEXT NOT NULL, " +
+				"error TEXT, " +
+				"locked_by TEXT NOT NULL, " +
+				"locked_until TIMESTAMP NOT NULL, " +
+				"created_at TIMESTAMP NOT NULL, " +
+				"updated_at TIMESTAMP NOT NULL)",
+			"CREATE INDEX IF NOT EXISTS " + table + "_pending_idx ON " + table +
+				" (saga_name, status, locked_until)",
+		}
+	}
+
+	return []string{
+		"CREATE TABLE IF NOT EXISTS " + table + " (" +
+			"id TEXT PRIMARY KEY, " +
+			"saga_name TEXT NOT NULL, " +
+			"status TEXT NOT NULL, " +
+			"step INTEGER NOT NULL, " +
+			"data TEXT NOT NULL, " +
+			"error TEXT, " +
+			"locked_by TEXT NOT NULL, " +
+			"locked_until TIMESTAMPTZ NOT NULL, " +
+			"created_at TIMESTAMPTZ NOT NULL, " +
+			"updated_at TIMESTAMPTZ NOT NULL)",
+		"CREATE INDEX IF NOT EXISTS " + table + "_pending_idx ON " + table +
+			" (saga_name, status, locked_until)",
+	}
+}
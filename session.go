@@ -0,0 +1,27 @@
+package atomic
+
+import (
+	"github.com/pkg/errors"
+)
+
+// ErrSessionClosed is matched by [SessionClosedError].
+var ErrSessionClosed = errors.New("session closed")
+
+// SessionClosedError is returned by Remotes which were used after the session of their
+// transaction ended, eg because a repository leaked the Remote into a long-lived struct.
+// It matches [ErrSessionClosed] with errors.Is.
+type SessionClosedError struct {
+	// Stack is the stack trace of the creation of the session.
+	Stack []byte
+}
+
+// Error implements the error interface.
+func (e *SessionClosedError) Error() string {
+	return ErrSessionClosed.Error() + ", remote used after its transaction ended; " +
+		"session created at:\n" + string(e.Stack)
+}
+
+// Is reports whether target is [ErrSessionClosed].
+func (e *SessionClosedError) Is(target error) bool {
+	return target == ErrSessionClosed //nolint:errorlint // sentinel comparison
+}
@@ -0,0 +1,95 @@
+// Package tenant carries the tenant of a request in the context and provides session
+// initializers for [generic.WithSessionInitializer], which set up every transaction for the
+// tenant, eg for Postgres row level security or schema per tenant setups.
+package tenant
+
+import (
+	"context"
+	"strings"
+
+	"github.com/beeemT/go-atomic/generic/adapter"
+	"github.com/pkg/errors"
+)
+
+// DefaultSetting is the default name of the setting holding the tenant for row level security
+// policies, eg USING (tenant_id = current_setting('app.tenant_id')).
+const DefaultSetting = "app.tenant_id"
+
+var (
+	// ErrNoTenant is returned by the session initializers if the context does not hold a tenant.
+	ErrNoTenant = errors.New("no tenant in context")
+	// ErrUnsupportedDialect is returned by the session initializers if the connection does not
+	// use Postgres.
+	ErrUnsupportedDialect = errors.New("dialect does not support session settings")
+)
+
+type contextKey struct{}
+
+// WithTenant returns a context holding tenant.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, contextKey{}, tenant)
+}
+
+// FromContext returns the tenant held by ctx. It returns false if ctx does not hold a tenant.
+func FromContext(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(contextKey{}).(string)
+
+	return tenant, ok
+}
+
+// RowLevelSecurity returns a session initializer setting the Postgres setting with the given
+// name to the tenant of the context for the duration of the transaction.
+// conn creates the connection from the transaction, eg with [adapter.SQL].
+// Transactions without tenant in the context fail with [ErrNoTenant].
+func RowLevelSecurity[Remote any](
+	conn func(Remote) adapter.Conn,
+	setting string,
+) func(ctx context.Context, tx Remote) error {
+	return func(ctx context.Context, tx Remote) error {
+		return set(ctx, conn(tx), setting, func(tenant string) string {
+			return tenant
+		})
+	}
+}
+
+// SearchPath returns a session initializer setting the Postgres search_path to the schema of the
+// tenant of the context for the duration of the transaction.
+// schema maps the tenant to its schema, the schema is quoted as identifier.
+// conn creates the connection from the transaction, eg with [adapter.SQL].
+// Transactions without tenant in the context fail with [ErrNoTenant].
+func SearchPath[Remote any](
+	conn func(Remote) adapter.Conn,
+	schema func(tenant string) string,
+) func(ctx context.Context, tx Remote) error {
+	return func(ctx context.Context, tx Remote) error {
+		return set(ctx, conn(tx), "search_path", func(tenant string) string {
+			return QuoteIdentifier(schema(tenant))
+		})
+	}
+}
+
+// QuoteIdentifier quotes name as SQL identifier.
+func QuoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// set sets setting to the value derived from the tenant of ctx, local to the transaction.
+func set(
+	ctx context.Context,
+	conn adapter.Conn,
+	setting string,
+	value func(tenant string) string,
+) error {
+	if conn.Dialect() != adapter.Postgres {
+		return ErrUnsupportedDialect
+	}
+
+	tenant, ok := FromContext(ctx)
+	if !ok {
+		return ErrNoTenant
+	}
+
+	_, err := conn.Exec(ctx, "SELECT set_config(?, ?, true)", setting, value(tenant))
+
+	return errors.Wrapf(err, "setting %s for tenant %s", setting, tenant)
+}
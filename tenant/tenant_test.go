@@ -0,0 +1,168 @@
+package tenant_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/beeemT/go-atomic/atomictest"
+	"github.com/beeemT/go-atomic/generic"
+	"github.com/beeemT/go-atomic/generic/adapter"
+	"github.com/beeemT/go-atomic/tenant"
+)
+
+// recorder is a Remote recording the arguments of the executed statements.
+type recorder struct {
+	dialect adapter.Dialect
+
+	mu   sync.Mutex
+	args [][]any
+}
+
+func (r *recorder) Exec(_ context.Context, _ string, args ...any) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.args = append(r.args, args)
+
+	return 0, nil
+}
+
+func (r *recorder) Query(context.Context, string, ...any) (*sql.Rows, error) {
+	return nil, errors.New("queries are not supported")
+}
+
+func (r *recorder) Dialect() adapter.Dialect {
+	return r.dialect
+}
+
+func (r *recorder) executed() [][]any {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.args
+}
+
+func newTransacter(
+	executer *atomictest.Executer[*recorder],
+	initializer func(context.Context, *recorder) error,
+) generic.Transacter[*recorder, struct{}] {
+	return generic.NewTransacter[*recorder, struct{}](
+		executer,
+		func(
+			context.Context,
+			*generic.Transacter[*recorder, struct{}],
+			*recorder,
+		) (struct{}, error) {
+			return struct{}{}, nil
+		},
+		generic.WithSessionInitializer[*recorder, struct{}](initializer),
+		generic.WithBackOffDelays[*recorder, struct{}](0),
+	)
+}
+
+func conn(r *recorder) adapter.Conn {
+	return r
+}
+
+func TestRowLevelSecurity(t *testing.T) {
+	remote := &recorder{dialect: adapter.Postgres}
+	executer := atomictest.NewExecuter(remote)
+	transacter := newTransacter(executer, tenant.RowLevelSecurity(conn, tenant.DefaultSetting))
+	ctx := tenant.WithTenant(context.Background(), "acme")
+
+	// the first attempt fails, so the retry runs in a new session
+	executer.FailRun(context.DeadlineExceeded)
+
+	for i := 0; i < 2; i++ {
+		err := transacter.Transact(ctx, func(ctx context.Context, _ struct{}) error {
+			return transacter.Transact(ctx, func(context.Context, struct{}) error {
+				return nil
+			})
+		})
+		if err != nil {
+			t.Fatalf("transacting: %v", err)
+		}
+	}
+
+	setting := []any{tenant.DefaultSetting, "acme"}
+	if executed := remote.executed(); !reflect.DeepEqual(
+		executed,
+		[][]any{setting, setting, setting},
+	) {
+		t.Fatalf("expected tenant to be set for every attempt and session, got %v", executed)
+	}
+}
+
+func TestSearchPath(t *testing.T) {
+	remote := &recorder{dialect: adapter.Postgres}
+	transacter := newTransacter(
+		atomictest.NewExecuter(remote),
+		tenant.SearchPath(conn, func(tenant string) string {
+			return "tenant_" + tenant
+		}),
+	)
+
+	err := transacter.Transact(
+		tenant.WithTenant(context.Background(), `a"b`),
+		func(context.Context, struct{}) error {
+			return nil
+		},
+	)
+	if err != nil {
+		t.Fatalf("transacting: %v", err)
+	}
+
+	if executed := remote.executed(); !reflect.DeepEqual(
+		executed,
+		[][]any{{"search_path", `"tenant_a""b"`}},
+	) {
+		t.Fatalf("expected quoted schema of the tenant, got %v", executed)
+	}
+}
+
+func TestInitializerErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		dialect adapter.Dialect
+		ctx     context.Context
+		err     error
+	}{
+		{
+			name:    "no tenant",
+			dialect: adapter.Postgres,
+			ctx:     context.Background(),
+			err:     tenant.ErrNoTenant,
+		},
+		{
+			name:    "unsupported dialect",
+			dialect: adapter.MySQL,
+			ctx:     tenant.WithTenant(context.Background(), "acme"),
+			err:     tenant.ErrUnsupportedDialect,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			remote := &recorder{dialect: tc.dialect}
+			transacter := newTransacter(
+				atomictest.NewExecuter(remote),
+				tenant.RowLevelSecurity(conn, tenant.DefaultSetting),
+			)
+
+			err := transacter.Transact(tc.ctx, func(context.Context, struct{}) error {
+				t.Error("expected run not to be called")
+
+				return nil
+			})
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("expected %v, got %v", tc.err, err)
+			}
+
+			if executed := remote.executed(); len(executed) != 0 {
+				t.Fatalf("expected no statements, got %v", executed)
+			}
+		})
+	}
+}
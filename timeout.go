@@ -0,0 +1,120 @@
+package atomic
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrTimeout is matched by [TimeoutError].
+var ErrTimeout = errors.New("database timeout")
+
+const (
+	// StatementTimeout is the kind of timeouts of statements running too long.
+	StatementTimeout TimeoutKind = iota + 1
+	// LockTimeout is the kind of timeouts while waiting for a lock.
+	LockTimeout
+	// IdleInTransactionTimeout is the kind of timeouts of transactions idling too long between
+	// statements.
+	IdleInTransactionTimeout
+)
+
+type (
+	// TimeoutKind is the kind of a [TimeoutError].
+	TimeoutKind int
+
+	// TimeoutError wraps an error returned by the database because a timeout configured for the
+	// transaction elapsed. It matches [ErrTimeout] with errors.Is and unwraps to the original
+	// error.
+	TimeoutError struct {
+		Kind TimeoutKind
+		Err  error
+	}
+
+	sqlStateError interface {
+		SQLState() string
+	}
+)
+
+// String returns the name of the timeout kind.
+func (k TimeoutKind) String() string {
+	switch k {
+	case StatementTimeout:
+		return "statement timeout"
+	case LockTimeout:
+		return "lock timeout"
+	case IdleInTransactionTimeout:
+		return "idle in transaction timeout"
+	}
+
+	return "unknown timeout"
+}
+
+// Error implements the error interface.
+func (e *TimeoutError) Error() string {
+	return e.Kind.String() + ": " + e.Err.Error()
+}
+
+// Unwrap returns the original error.
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is [ErrTimeout].
+func (e *TimeoutError) Is(target error) bool {
+	return target == ErrTimeout //nolint:errorlint // sentinel comparison
+}
+
+// Retryable reports whether rerunning the transaction might succeed. This is the case for lock
+// timeouts, as the lock might be released in the meantime. Statements and transactions exceeding
+// their timeouts are likely to exceed them again.
+func (e *TimeoutError) Retryable() bool {
+	return e.Kind == LockTimeout
+}
+
+// ClassifyTimeoutError wraps err in a [TimeoutError] if it was returned by the database because a
+// statement, lock or idle in transaction timeout elapsed. Other errors are returned unchanged.
+// Postgres and CockroachDB errors are recognized by their SQLSTATE if the driver error implements
+// SQLState() string (eg lib/pq and pgx), MySQL errors by their message.
+func ClassifyTimeoutError(err error) error {
+	if err == nil || errors.Is(err, ErrTimeout) {
+		return err
+	}
+
+	kind := timeoutKind(err)
+	if kind == 0 {
+		return err
+	}
+
+	return &TimeoutError{Kind: kind, Err: err}
+}
+
+func timeoutKind(err error) TimeoutKind {
+	var stateErr sqlStateError
+	if errors.As(err, &stateErr) {
+		switch stateErr.SQLState() {
+		case "57014":
+			// query_canceled is also returned for statements cancelled by the client
+			if strings.Contains(err.Error(), "statement timeout") {
+				return StatementTimeout
+			}
+		case "55P03":
+			return LockTimeout
+		case "25P03":
+			return IdleInTransactionTimeout
+		}
+
+		return 0
+	}
+
+	msg := err.Error()
+
+	switch {
+	case strings.Contains(msg, "Lock wait timeout exceeded"):
+		return LockTimeout
+	case strings.Contains(msg, "maximum statement execution time exceeded"):
+		return StatementTimeout
+	}
+
+	return 0
+}